@@ -2,17 +2,90 @@ package analyzer
 
 import (
 	"database/sql"
-	"log"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
+
+	"github.com/lib/pq"
+	"github.com/pgsql-analyzer/backend/parser"
 )
 
+// StatusDefinition describes a thread status the classifier can assign
+type StatusDefinition struct {
+	Status      string `json:"status"`
+	Description string `json:"description"`
+}
+
+// Statuses lists every status ClassifyThread can return, in precedence order
+var Statuses = []StatusDefinition{
+	{"stalled-patch", "Has a patch but no review activity and no messages for more than 14 days"},
+	{"in-progress", "Has a patch with review activity or more than 3 messages"},
+	{"has-patch", "Has a patch awaiting further discussion"},
+	{"abandoned", "No patch, fewer than 5 messages, and no messages for more than 30 days"},
+	{"stalled", "No patch and no messages for more than 7 days"},
+	{"discussion", "Active discussion without a patch"},
+}
+
+// dormantStatuses are statuses a thread leaves when it is revived
+var dormantStatuses = map[string]bool{
+	"abandoned": true,
+	"stalled":   true,
+}
+
+// IsRevival reports whether a reclassification moved a thread from a dormant
+// status back to an active one
+func IsRevival(before, after string) bool {
+	return dormantStatuses[before] && !dormantStatuses[after]
+}
+
 type ThreadAnalyzer struct {
 	db *sql.DB
+
+	// Announcements decides which threads are release announcements
+	Announcements *AnnouncementDetector
+
+	// Benchmarks decides which messages contain performance results
+	Benchmarks *BenchmarkDetector
+
+	// Consensus decides which replies voice agreement
+	Consensus *ConsensusDetector
+
+	// IgnoreAuthorBumps measures staleness from the last message by someone other
+	// than the thread's author, so an author bumping their own patch doesn't keep
+	// an unreviewed thread looking active
+	IgnoreAuthorBumps bool
+
+	// BotSenders are addresses (compared case-insensitively) whose messages are
+	// kept but don't count as participants: they're left out of unique_authors,
+	// reviewer roles and the other-participant signals
+	BotSenders []string
+
+	// HeatDecay is the time constant of the heat score's exponential decay
+	HeatDecay time.Duration
 }
 
+// DefaultHeatDecay is the heat decay constant used when none is configured
+const DefaultHeatDecay = 48 * time.Hour
+
+// HeatWindow bounds which messages contribute to a thread's heat
+const HeatWindow = 7 * 24 * time.Hour
+
 func NewThreadAnalyzer(db *sql.DB) *ThreadAnalyzer {
-	return &ThreadAnalyzer{db: db}
+	return &ThreadAnalyzer{db: db, Announcements: defaultAnnouncementDetector, Benchmarks: defaultBenchmarkDetector,
+		Consensus: defaultConsensusDetector, HeatDecay: DefaultHeatDecay}
+}
+
+// BotSendersArg returns BotSenders as a lowercased text[] query argument for
+// LOWER(author_email) <> ALL(...). It's never NULL, so an empty list excludes nothing.
+func (ta *ThreadAnalyzer) BotSendersArg() interface{} {
+	senders := make([]string, 0, len(ta.BotSenders))
+	for _, s := range ta.BotSenders {
+		senders = append(senders, strings.ToLower(s))
+	}
+	return pq.Array(senders)
 }
 
 // ClassifyThread determines the status of a thread based on activity metrics
@@ -21,17 +94,24 @@ func (ta *ThreadAnalyzer) ClassifyThread(threadID string) (string, error) {
 	var messageCount int
 	var uniqueAuthors int
 
+	// Header-only messages aren't substantive discussion, so they don't count here.
+	// With IgnoreAuthorBumps, a thread nobody else has answered is aged from its start.
 	err := ta.db.QueryRow(`
 		SELECT 
-			COALESCE(last_message_at, created_at),
-			message_count,
+			CASE WHEN $2 THEN COALESCE((
+				SELECT MAX(m.created_at) FROM messages m
+				WHERE m.thread_id = t.id AND LOWER(m.author_email) <> LOWER(t.first_author_email)
+				  AND LOWER(m.author_email) <> ALL($3::text[])
+			), t.created_at)
+			ELSE COALESCE(last_message_at, created_at) END,
+			(SELECT COUNT(*) FROM messages m WHERE m.thread_id = t.id AND NOT m.empty_body),
 			unique_authors
-		FROM threads
+		FROM threads t
 		WHERE id = $1
-	`, threadID).Scan(&lastMessageAt, &messageCount, &uniqueAuthors)
+	`, threadID, ta.IgnoreAuthorBumps, ta.BotSendersArg()).Scan(&lastMessageAt, &messageCount, &uniqueAuthors)
 
 	if err != nil {
-		log.Printf("Error querying thread: %v", err)
+		slog.Error("Error querying thread", "thread_id", threadID, "error", err)
 		return "unknown", err
 	}
 
@@ -75,7 +155,7 @@ func (ta *ThreadAnalyzer) ClassifyThread(threadID string) (string, error) {
 
 func (ta *ThreadAnalyzer) checkForPatchKeywords(threadID string) (bool, bool) {
 	rows, err := ta.db.Query(`
-		SELECT body FROM messages WHERE thread_id = $1
+		SELECT body FROM messages WHERE thread_id = $1 AND NOT empty_body
 	`, threadID)
 	if err != nil {
 		return false, false
@@ -93,7 +173,8 @@ func (ta *ThreadAnalyzer) checkForPatchKeywords(threadID string) (bool, bool) {
 			continue
 		}
 
-		bodyLower := strings.ToLower(body)
+		// Forwarded chains are quoted context, not new activity in this thread
+		bodyLower := strings.ToLower(parser.StripForwarded(body))
 		for _, keyword := range patchKeywords {
 			if strings.Contains(bodyLower, strings.ToLower(keyword)) {
 				hasPatch = true
@@ -115,17 +196,23 @@ func (ta *ThreadAnalyzer) checkForPatchKeywords(threadID string) (bool, bool) {
 func (ta *ThreadAnalyzer) UpdateThreadActivity(threadID string) error {
 	var messageCount int
 	var uniqueAuthors int
+	var patchCount int
 	var lastMessageAt sql.NullTime
+	var currentPatchStatus string
 
-	// Get message count and unique authors (MAX(created_at) is NULL when thread has no messages)
+	// Get message count and unique authors (MAX(created_at) is NULL when thread has no messages).
+	// The current patch status is that of the latest message carrying one.
 	err := ta.db.QueryRow(`
 		SELECT 
 			COUNT(*),
-			COUNT(DISTINCT author_email),
-			MAX(created_at)
+			COUNT(DISTINCT author_email) FILTER (WHERE LOWER(author_email) <> ALL($2::text[])),
+			COUNT(*) FILTER (WHERE has_patch),
+			MAX(created_at),
+			COALESCE((ARRAY_AGG(patch_status ORDER BY created_at DESC, message_id DESC)
+				FILTER (WHERE patch_status <> ''))[1], '')
 		FROM messages
 		WHERE thread_id = $1
-	`, threadID).Scan(&messageCount, &uniqueAuthors, &lastMessageAt)
+	`, threadID, ta.BotSendersArg()).Scan(&messageCount, &uniqueAuthors, &patchCount, &lastMessageAt, &currentPatchStatus)
 
 	if err != nil && err != sql.ErrNoRows {
 		return err
@@ -152,9 +239,11 @@ func (ta *ThreadAnalyzer) UpdateThreadActivity(threadID string) error {
 			message_count = $1,
 			unique_authors = $2,
 			last_message_at = $3,
+			patch_count = $4,
+			current_patch_status = $5,
 			updated_at = NOW()
-		WHERE id = $4
-	`, messageCount, uniqueAuthors, lastAtArg, threadID)
+		WHERE id = $6
+	`, messageCount, uniqueAuthors, lastAtArg, patchCount, currentPatchStatus, threadID)
 
 	if err != nil {
 		return err
@@ -174,6 +263,396 @@ func (ta *ThreadAnalyzer) UpdateThreadActivity(threadID string) error {
 			days_since_last_message = $7,
 			updated_at = NOW()
 	`, threadID, threadID, messageCount, uniqueAuthors, hasPatch, hasReview, daysSince)
+	if err != nil {
+		return err
+	}
+
+	return ta.RefreshHeat([]string{threadID})
+}
+
+// heatScore is a thread's heat: each message from the last HeatWindow
+// contributes exp(-age/HeatDecay), with $1 the decay and $2 the window in
+// seconds. Messages dated in the future count as brand new rather than making
+// the exponent positive.
+const heatScore = `COALESCE((
+	SELECT SUM(EXP(-GREATEST(EXTRACT(EPOCH FROM (NOW() - m.created_at)), 0) / $1))
+	FROM messages m
+	WHERE m.thread_id = t.id AND m.created_at > NOW() - make_interval(secs => $2)
+), 0)`
+
+// RefreshHeat recomputes the heat score of the given threads (all threads when
+// ids is nil), so a burst of recent replies outranks a single late reply to an
+// old thread. Threads not given keep their score until CoolHeat.
+func (ta *ThreadAnalyzer) RefreshHeat(ids []string) error {
+	_, err := ta.db.Exec(`
+		UPDATE threads t SET heat = `+heatScore+`
+		WHERE $3::text[] IS NULL OR t.id = ANY($3::text[])
+	`, ta.heatDecay().Seconds(), HeatWindow.Seconds(), pq.Array(ids))
+	return err
+}
+
+// CoolHeat recomputes every thread that still has heat, so quiet threads cool
+// down instead of keeping the score of their last message. It touches all hot
+// threads, so call it once per batch or sync rather than per thread.
+func (ta *ThreadAnalyzer) CoolHeat() error {
+	_, err := ta.db.Exec(`
+		UPDATE threads t SET heat = `+heatScore+`
+		WHERE t.heat > 0
+	`, ta.heatDecay().Seconds(), HeatWindow.Seconds())
+	return err
+}
+
+func (ta *ThreadAnalyzer) heatDecay() time.Duration {
+	if ta.HeatDecay <= 0 {
+		return DefaultHeatDecay
+	}
+	return ta.HeatDecay
+}
+
+// CommitURLFormat links a commit hash to the PostgreSQL git browser
+const CommitURLFormat = "https://git.postgresql.org/cgit/postgresql.git/commit/?id=%s"
+
+// commitHashPatterns only match hashes in commit-announcement context: a link to
+// the postgres git browser, or a hash directly following committed/pushed/applied.
+var commitHashPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`git\.postgresql\.org/\S*?(?:commitdiff/|commit/|[;?&]h=|[?&]id=)([0-9a-f]{7,40})\b`),
+	regexp.MustCompile(`(?i)\b(?:committed|pushed|applied)(?: as| in| to \S+ as)?(?: commit)?:?\s+([0-9a-f]{7,40})\b`),
+}
+
+// CommitURL returns the git browser URL for a commit hash, or "" if hash is empty
+func CommitURL(hash string) string {
+	if hash == "" {
+		return ""
+	}
+	return fmt.Sprintf(CommitURLFormat, hash)
+}
+
+// extractCommitHash returns the first commit hash found in commit-announcement
+// context within body, or "" if none
+func extractCommitHash(body string) string {
+	for _, re := range commitHashPatterns {
+		for _, m := range re.FindAllStringSubmatch(body, -1) {
+			// Require a digit so plain hex-looking words ("defaced") don't match
+			if strings.ContainsAny(m[1], "0123456789") {
+				return m[1]
+			}
+		}
+	}
+	return ""
+}
+
+// ExtractCommitHash scans a thread's messages, newest first, for the commit that
+// resolved it. Returns "" when no commit announcement is found.
+func (ta *ThreadAnalyzer) ExtractCommitHash(threadID string) (string, error) {
+	rows, err := ta.db.Query(`
+		SELECT body FROM messages
+		WHERE thread_id = $1
+		ORDER BY created_at DESC, message_id DESC
+	`, threadID)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var body string
+		if err := rows.Scan(&body); err != nil {
+			continue
+		}
+		if hash := extractCommitHash(parser.StripForwarded(body)); hash != "" {
+			return hash, nil
+		}
+	}
+	return "", rows.Err()
+}
+
+// Patch maturity levels, as flagged by the patch author
+const (
+	MaturityWIP         = "wip"
+	MaturityRFC         = "rfc"
+	MaturityReviewReady = "review-ready"
+)
+
+// maturitySubjectPatterns match WIP/RFC markers in a subject tag ("[PATCH RFC v2]")
+// or as a leading prefix ("WIP: ..."), but not bare mentions like "RFC 5322"
+var maturitySubjectPatterns = map[string]*regexp.Regexp{
+	MaturityWIP: regexp.MustCompile(`(?i)\[[^\]]*\bwip\b[^\]]*\]|^\s*wip\s*:`),
+	MaturityRFC: regexp.MustCompile(`(?i)\[[^\]]*\brfc\b[^\]]*\]|^\s*rfc\s*:`),
+}
+
+// maturityBodyPhrases are author statements that mark a patch as not review-ready
+var maturityBodyPhrases = map[string][]string{
+	MaturityWIP: {"work in progress", "not ready for commit", "wip patch"},
+	MaturityRFC: {"request for comments", "rfc patch", "looking for feedback on the approach"},
+}
+
+// ownText returns the lowercased text the sender wrote, dropping forwarded
+// chains and ">"-quoted lines
+func ownText(body string) string {
+	var own strings.Builder
+	for _, line := range strings.Split(parser.StripForwarded(body), "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), ">") {
+			own.WriteString(strings.ToLower(line))
+			own.WriteString("\n")
+		}
+	}
+	return own.String()
+}
 
+// detectMaturity classifies a patch message as wip, rfc, or review-ready.
+// Quoted lines are ignored so a reply doesn't inherit its parent's marker.
+func detectMaturity(subject, body string) string {
+	for _, level := range []string{MaturityWIP, MaturityRFC} {
+		if maturitySubjectPatterns[level].MatchString(subject) {
+			return level
+		}
+	}
+
+	text := ownText(body)
+	for _, level := range []string{MaturityWIP, MaturityRFC} {
+		for _, phrase := range maturityBodyPhrases[level] {
+			if strings.Contains(text, phrase) {
+				return level
+			}
+		}
+	}
+	return MaturityReviewReady
+}
+
+// ClassifyMaturity determines a thread's patch maturity from its most recent patch
+// message, since authors drop the WIP/RFC marker once a version is ready for review.
+// Threads without patches fall back to the thread subject.
+func (ta *ThreadAnalyzer) ClassifyMaturity(threadID string) (string, error) {
+	var subject, body string
+	err := ta.db.QueryRow(`
+		SELECT subject, COALESCE(body, '') FROM messages
+		WHERE thread_id = $1 AND has_patch = TRUE
+		ORDER BY created_at DESC, message_id DESC
+		LIMIT 1
+	`, threadID).Scan(&subject, &body)
+	if err == sql.ErrNoRows {
+		err = ta.db.QueryRow("SELECT subject FROM threads WHERE id = $1", threadID).Scan(&subject)
+	}
+	if err != nil {
+		return MaturityReviewReady, err
+	}
+	return detectMaturity(subject, body), nil
+}
+
+// changesRequestedPhrases are reviewer requests for the patch author to act.
+// The list is deliberately narrow: general words like "comments" or "issue"
+// appear in too many approvals to be useful.
+var changesRequestedPhrases = []string{
+	"needs rebase",
+	"needs a rebase",
+	"needs to be rebased",
+	"please rebase",
+	"could you rebase",
+	"no longer applies",
+	"does not apply",
+	"doesn't apply",
+	"please add tests",
+	"please add a test",
+	"needs tests",
+	"a few comments below",
+	"some comments below",
+	"comments inline",
+	"waiting on author",
+}
+
+// detectChangesRequested reports whether a review message asks the author for changes
+func detectChangesRequested(body string) bool {
+	text := ownText(body)
+	for _, phrase := range changesRequestedPhrases {
+		if strings.Contains(text, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsAuthorAction reports whether the thread's latest reviewer message, sent
+// after the author last posted, requests changes. Only patch threads qualify.
+func (ta *ThreadAnalyzer) NeedsAuthorAction(threadID string) (bool, error) {
+	var body string
+	err := ta.db.QueryRow(`
+		SELECT COALESCE(m.body, '')
+		FROM messages m
+		JOIN threads t ON t.id = m.thread_id
+		WHERE m.thread_id = $1
+		  AND t.patch_count > 0
+		  AND LOWER(m.author_email) <> LOWER(t.first_author_email)
+		  AND LOWER(m.author_email) <> ALL($2::text[])
+		  AND m.created_at > COALESCE((
+			SELECT MAX(a.created_at) FROM messages a
+			WHERE a.thread_id = t.id AND LOWER(a.author_email) = LOWER(t.first_author_email)
+		  ), '-infinity')
+		ORDER BY m.created_at DESC, m.message_id DESC
+		LIMIT 1
+	`, threadID, ta.BotSendersArg()).Scan(&body)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return detectChangesRequested(body), nil
+}
+
+// ReadyForCommitter reports whether the thread's latest patch verdict is
+// "accepted" (ready for committer) and no commit has been recorded since
+func (ta *ThreadAnalyzer) ReadyForCommitter(threadID string) (bool, error) {
+	var verdict, commitHash string
+	err := ta.db.QueryRow(`
+		SELECT m.patch_status, t.commit_hash
+		FROM messages m
+		JOIN threads t ON t.id = m.thread_id
+		WHERE m.thread_id = $1 AND m.patch_status IN ('accepted', 'committed', 'rejected')
+		ORDER BY m.created_at DESC, m.message_id DESC
+		LIMIT 1
+	`, threadID).Scan(&verdict, &commitHash)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return verdict == "accepted" && commitHash == "", nil
+}
+
+// NeedsCommitterAttention reports whether a ready-for-committer thread has had
+// no activity for at least idleAfter as of now
+func NeedsCommitterAttention(ready bool, lastActivity *time.Time, idleAfter time.Duration, now time.Time) bool {
+	if !ready || lastActivity == nil {
+		return false
+	}
+	return now.Sub(*lastActivity) >= idleAfter
+}
+
+// Participant roles within a thread
+const (
+	RoleAuthor    = "author"
+	RoleReviewer  = "reviewer"
+	RoleCommenter = "commenter"
+)
+
+// reviewLanguage matches wording typical of a review, as whole words so that
+// e.g. "ack" doesn't fire on "back"
+var reviewLanguage = regexp.MustCompile(`\b(review|reviewed|reviewing|lgtm|approved|looks good|ack|acked-by|reviewed-by|tested-by|nitpick|nit)\b`)
+
+// Participant is a thread participant with their inferred role
+type Participant struct {
+	Author       string `json:"author"`
+	AuthorEmail  string `json:"author_email"`
+	Role         string `json:"role"`
+	MessageCount int    `json:"message_count"`
+	ReviewCount  int    `json:"review_count"`
+}
+
+// isReviewComment reports whether the sender's own (unquoted) text is
+// substantive and reads like a review
+func isReviewComment(body string) bool {
+	text := ownText(body)
+	if strings.TrimSpace(text) == "" {
+		return false
+	}
+	return reviewLanguage.MatchString(text) || detectChangesRequested(body)
+}
+
+// ClassifyParticipants assigns each participant a role: the thread starter is the
+// author, others with at least one review comment are reviewers, the rest are
+// commenters. Quote-only replies never count as reviews and bot senders are
+// skipped. Results are ordered author first, then by review count.
+func (ta *ThreadAnalyzer) ClassifyParticipants(threadID string) ([]Participant, error) {
+	var authorEmail string
+	if err := ta.db.QueryRow("SELECT first_author_email FROM threads WHERE id = $1", threadID).Scan(&authorEmail); err != nil {
+		return nil, err
+	}
+
+	rows, err := ta.db.Query(`
+		SELECT author, author_email, COALESCE(body, '')
+		FROM messages
+		WHERE thread_id = $1 AND NOT empty_body
+		  AND LOWER(author_email) <> ALL($2::text[])
+		ORDER BY created_at ASC, message_id ASC
+	`, threadID, ta.BotSendersArg())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byEmail := make(map[string]*Participant)
+	participants := make([]*Participant, 0)
+	for rows.Next() {
+		var author, email, body string
+		if err := rows.Scan(&author, &email, &body); err != nil {
+			continue
+		}
+		p, ok := byEmail[email]
+		if !ok {
+			p = &Participant{Author: author, AuthorEmail: email, Role: RoleCommenter}
+			if email == authorEmail {
+				p.Role = RoleAuthor
+			}
+			byEmail[email] = p
+			participants = append(participants, p)
+		}
+		p.MessageCount++
+		if p.Role != RoleAuthor && isReviewComment(body) {
+			p.ReviewCount++
+			p.Role = RoleReviewer
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rank := map[string]int{RoleAuthor: 0, RoleReviewer: 1, RoleCommenter: 2}
+	sort.SliceStable(participants, func(i, j int) bool {
+		if rank[participants[i].Role] != rank[participants[j].Role] {
+			return rank[participants[i].Role] < rank[participants[j].Role]
+		}
+		return participants[i].ReviewCount > participants[j].ReviewCount
+	})
+
+	result := make([]Participant, 0, len(participants))
+	for _, p := range participants {
+		result = append(result, *p)
+	}
+	return result, nil
+}
+
+// MarkSuperseded records which messages in a thread have been replaced by a newer
+// version. Explicit Supersedes/Replaces headers win; otherwise each patch message
+// is superseded by the same author's next patch message in the thread.
+func (ta *ThreadAnalyzer) MarkSuperseded(threadID string) error {
+	// Header-based: the target may live in any thread
+	_, err := ta.db.Exec(`
+		UPDATE messages old
+		SET superseded_by = n.message_id
+		FROM messages n
+		WHERE n.thread_id = $1
+		  AND n.supersedes <> ''
+		  AND old.message_id = n.supersedes
+	`, threadID)
+	if err != nil {
+		return err
+	}
+
+	// Version heuristic for patches without explicit headers
+	_, err = ta.db.Exec(`
+		UPDATE messages m
+		SET superseded_by = v.next_id
+		FROM (
+			SELECT message_id,
+			       LEAD(message_id) OVER (PARTITION BY author_email ORDER BY created_at, message_id) AS next_id
+			FROM messages
+			WHERE thread_id = $1 AND has_patch
+		) v
+		WHERE m.message_id = v.message_id
+		  AND v.next_id IS NOT NULL
+		  AND m.superseded_by = ''
+		  AND NOT EXISTS (SELECT 1 FROM messages h WHERE h.supersedes = m.message_id)
+	`, threadID)
 	return err
 }
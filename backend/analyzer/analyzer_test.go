@@ -0,0 +1,73 @@
+package analyzer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNeedsCommitterAttention(t *testing.T) {
+	const idleAfter = 14 * 24 * time.Hour
+	readyAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// A patch marked ready is fresh at first and goes stale once idle long enough
+	steps := []struct {
+		now  time.Time
+		want bool
+	}{
+		{readyAt, false},
+		{readyAt.Add(13 * 24 * time.Hour), false},
+		{readyAt.Add(idleAfter), true},
+		{readyAt.Add(30 * 24 * time.Hour), true},
+	}
+	for _, s := range steps {
+		if got := NeedsCommitterAttention(true, &readyAt, idleAfter, s.now); got != s.want {
+			t.Errorf("ready, %v idle: got %v, want %v", s.now.Sub(readyAt), got, s.want)
+		}
+	}
+
+	// New activity on the thread resets the clock
+	replyAt := readyAt.Add(20 * 24 * time.Hour)
+	if NeedsCommitterAttention(true, &replyAt, idleAfter, readyAt.Add(21*24*time.Hour)) {
+		t.Error("flagged a day after new activity")
+	}
+
+	// Threads that aren't ready, or have no activity recorded, are never flagged
+	late := readyAt.Add(365 * 24 * time.Hour)
+	if NeedsCommitterAttention(false, &readyAt, idleAfter, late) {
+		t.Error("flagged a thread that isn't ready for committer")
+	}
+	if NeedsCommitterAttention(true, nil, idleAfter, late) {
+		t.Error("flagged a thread with no activity")
+	}
+}
+
+func TestDetectChangesRequested(t *testing.T) {
+	requested := []string{
+		"The patch needs rebase after 3f2a1c.",
+		"v3 no longer applies on master, please rebase.",
+		"This doesn't apply cleanly anymore.",
+		"Please add tests for the new GUC.",
+		"I have a few comments below.\n\n> +\tif (x)\nThis can't be NULL here.",
+		"Comments inline.",
+		"Marking this Waiting on Author in the CF app.",
+	}
+	for _, body := range requested {
+		if !detectChangesRequested(body) {
+			t.Errorf("missed a request for changes: %q", body)
+		}
+	}
+
+	notRequested := []string{
+		"Looks good to me, marking ready for committer.",
+		"I have some comments on the general approach, but +1 overall.",
+		"Thanks, this fixes the issue I saw.",
+		// Quoted from an earlier review, not asked again
+		"> The patch needs rebase.\nRebased version attached, thanks!",
+		"",
+	}
+	for _, body := range notRequested {
+		if detectChangesRequested(body) {
+			t.Errorf("flagged a message that asks for nothing: %q", body)
+		}
+	}
+}
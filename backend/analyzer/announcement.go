@@ -0,0 +1,83 @@
+package analyzer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Thread kinds
+const (
+	KindThread       = "thread"
+	KindAnnouncement = "announcement"
+)
+
+// DefaultAnnouncementSubjectPatterns match release and news announcements, e.g.
+// "PostgreSQL 16.1, 15.5, 14.10, 13.13, 12.17, and 11.22 Released!" or
+// "PostgreSQL 17 Beta 1 Released!"
+var DefaultAnnouncementSubjectPatterns = []string{
+	`(?i)^postgresql\s+\d+(\.\d+)?\b.*\breleased\b`,
+	`(?i)^postgresql weekly news\b`,
+	`(?i)\b(security|out-of-cycle) (release|update)s?\b`,
+}
+
+// DefaultAnnouncementSenders are addresses that only send announcements
+var DefaultAnnouncementSenders = []string{
+	"noreply@postgresql.org",
+}
+
+// AnnouncementDetector recognizes threads that are release announcements rather
+// than development discussion
+type AnnouncementDetector struct {
+	subjects []*regexp.Regexp
+	senders  map[string]bool
+}
+
+// NewAnnouncementDetector compiles the given subject patterns and sender list.
+// Nil or empty slices fall back to the defaults.
+func NewAnnouncementDetector(subjectPatterns, senders []string) (*AnnouncementDetector, error) {
+	if len(subjectPatterns) == 0 {
+		subjectPatterns = DefaultAnnouncementSubjectPatterns
+	}
+	if len(senders) == 0 {
+		senders = DefaultAnnouncementSenders
+	}
+
+	d := &AnnouncementDetector{senders: make(map[string]bool)}
+	for _, pattern := range subjectPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid announcement pattern %q: %w", pattern, err)
+		}
+		d.subjects = append(d.subjects, re)
+	}
+	for _, sender := range senders {
+		d.senders[strings.ToLower(strings.TrimSpace(sender))] = true
+	}
+	return d, nil
+}
+
+var defaultAnnouncementDetector, _ = NewAnnouncementDetector(nil, nil)
+
+// Kind classifies a thread from its subject and starter's address
+func (d *AnnouncementDetector) Kind(subject, authorEmail string) string {
+	if d.senders[strings.ToLower(authorEmail)] {
+		return KindAnnouncement
+	}
+	for _, re := range d.subjects {
+		if re.MatchString(subject) {
+			return KindAnnouncement
+		}
+	}
+	return KindThread
+}
+
+// ClassifyKind determines whether a thread is an announcement or a regular thread
+func (ta *ThreadAnalyzer) ClassifyKind(threadID string) (string, error) {
+	var subject, authorEmail string
+	err := ta.db.QueryRow("SELECT subject, first_author_email FROM threads WHERE id = $1", threadID).Scan(&subject, &authorEmail)
+	if err != nil {
+		return KindThread, err
+	}
+	return ta.Announcements.Kind(subject, authorEmail), nil
+}
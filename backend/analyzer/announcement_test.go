@@ -0,0 +1,61 @@
+package analyzer
+
+import "testing"
+
+func TestAnnouncementKind(t *testing.T) {
+	d, err := NewAnnouncementDetector(nil, nil)
+	if err != nil {
+		t.Fatalf("NewAnnouncementDetector: %v", err)
+	}
+
+	announcements := []string{
+		"PostgreSQL 16.1, 15.5, 14.10, 13.13, 12.17, and 11.22 Released!",
+		"PostgreSQL 17 Beta 1 Released!",
+		"PostgreSQL 15 Released!",
+		"PostgreSQL 9.6.24 released",
+		"PostgreSQL Weekly News - March 3, 2024",
+		"Out-of-cycle release scheduled for February 8, 2024",
+		"Upcoming security update for PostgreSQL",
+	}
+	for _, subject := range announcements {
+		if got := d.Kind(subject, "someone@example.com"); got != KindAnnouncement {
+			t.Errorf("%q: kind %q, want %q", subject, got, KindAnnouncement)
+		}
+	}
+
+	threads := []string{
+		"Re: PostgreSQL 16.1 released",
+		"[PATCH] Speed up release of buffer pins",
+		"Should the release notes mention this?",
+		"pg_upgrade fails after PostgreSQL 16 was released",
+	}
+	for _, subject := range threads {
+		if got := d.Kind(subject, "someone@example.com"); got != KindThread {
+			t.Errorf("%q: kind %q, want %q", subject, got, KindThread)
+		}
+	}
+
+	// Announcement-only senders, in any case
+	if got := d.Kind("Minor update", "NoReply@PostgreSQL.org"); got != KindAnnouncement {
+		t.Errorf("announcement sender: kind %q", got)
+	}
+}
+
+func TestAnnouncementDetectorConfigurable(t *testing.T) {
+	d, err := NewAnnouncementDetector([]string{`(?i)^\[ANNOUNCE\]`}, []string{"news@example.org"})
+	if err != nil {
+		t.Fatalf("NewAnnouncementDetector: %v", err)
+	}
+	if d.Kind("[ANNOUNCE] pgbouncer 1.22 released", "a@example.com") != KindAnnouncement {
+		t.Error("custom pattern not applied")
+	}
+	if d.Kind("PostgreSQL 17 Beta 1 Released!", "a@example.com") != KindThread {
+		t.Error("default patterns still applied alongside custom ones")
+	}
+	if d.Kind("anything", "news@example.org") != KindAnnouncement {
+		t.Error("custom sender not applied")
+	}
+	if _, err := NewAnnouncementDetector([]string{"("}, nil); err == nil {
+		t.Error("invalid pattern accepted")
+	}
+}
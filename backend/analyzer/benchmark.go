@@ -0,0 +1,50 @@
+package analyzer
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// DefaultBenchmarkPatterns match performance results as they usually appear on
+// the list: pgbench output, latency figures and percentage comparisons
+var DefaultBenchmarkPatterns = []string{
+	`(?m)^\s*tps = \d`,
+	`(?m)^\s*number of transactions actually processed:`,
+	`(?mi)^\s*latency (average|stddev) = \d`,
+	`(?i)\bpgbench\b.*\s-[cjTtS]\s*\d`,
+	`(?i)\d+(\.\d+)?\s*%\s*(improvement|regression|faster|slower|speedup)\b`,
+}
+
+// BenchmarkDetector recognizes messages that contain benchmark results
+type BenchmarkDetector struct {
+	patterns []*regexp.Regexp
+}
+
+// NewBenchmarkDetector compiles the given body patterns. A nil or empty slice
+// falls back to the defaults.
+func NewBenchmarkDetector(patterns []string) (*BenchmarkDetector, error) {
+	if len(patterns) == 0 {
+		patterns = DefaultBenchmarkPatterns
+	}
+	d := &BenchmarkDetector{}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid benchmark pattern %q: %w", pattern, err)
+		}
+		d.patterns = append(d.patterns, re)
+	}
+	return d, nil
+}
+
+var defaultBenchmarkDetector, _ = NewBenchmarkDetector(nil)
+
+// HasBenchmarks reports whether body contains performance results
+func (d *BenchmarkDetector) HasBenchmarks(body string) bool {
+	for _, re := range d.patterns {
+		if re.MatchString(body) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package analyzer
+
+import "testing"
+
+const pgbenchOutput = `Here are the numbers on my laptop:
+
+$ pgbench -c 16 -j 4 -T 60 bench
+transaction type: <builtin: TPC-B (sort of)>
+scaling factor: 100
+query mode: simple
+number of clients: 16
+number of threads: 4
+duration: 60 s
+number of transactions actually processed: 612345
+latency average = 1.568 ms
+tps = 10205.751234 (without initial connection time)
+`
+
+func TestHasBenchmarks(t *testing.T) {
+	d, err := NewBenchmarkDetector(nil)
+	if err != nil {
+		t.Fatalf("NewBenchmarkDetector: %v", err)
+	}
+
+	results := []string{
+		pgbenchOutput,
+		"patched:\n  tps = 10205.75\nmaster:\n  tps = 9876.10\n",
+		"With the patch I see a 12.5% improvement on the sort-heavy queries.",
+		"That's about 3% slower for short transactions.",
+		"I ran pgbench -S -c 32 for ten minutes on each build.",
+	}
+	for _, body := range results {
+		if !d.HasBenchmarks(body) {
+			t.Errorf("benchmark results not detected in %q", body)
+		}
+	}
+
+	discussion := []string{
+		"Did anyone run pgbench against this yet?",
+		"The tps counter in pg_stat_database looks wrong to me.",
+		"This would improve things by a lot, I think.",
+		"diff --git a/src/bin/pgbench/pgbench.c b/src/bin/pgbench/pgbench.c\n",
+	}
+	for _, body := range discussion {
+		if d.HasBenchmarks(body) {
+			t.Errorf("benchmark results detected in %q", body)
+		}
+	}
+}
+
+func TestBenchmarkDetectorConfigurable(t *testing.T) {
+	d, err := NewBenchmarkDetector([]string{`(?m)^Execution Time: \d`})
+	if err != nil {
+		t.Fatalf("NewBenchmarkDetector: %v", err)
+	}
+	if !d.HasBenchmarks("Planning Time: 0.1 ms\nExecution Time: 42.0 ms\n") {
+		t.Error("custom pattern not applied")
+	}
+	if d.HasBenchmarks(pgbenchOutput) {
+		t.Error("default patterns still applied alongside custom ones")
+	}
+
+	if _, err := NewBenchmarkDetector([]string{"("}); err == nil {
+		t.Error("invalid pattern accepted")
+	}
+}
@@ -0,0 +1,91 @@
+package analyzer
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// ConsensusParticipants is how many distinct participants other than the
+// thread's author must voice agreement for a thread to count as settled
+const ConsensusParticipants = 2
+
+// DefaultConsensusPatterns match the usual ways agreement is voiced on the
+// list. Most are anchored to the start of a line so that e.g. "I don't agree"
+// doesn't count.
+var DefaultConsensusPatterns = []string{
+	`(?m)^\s*\+1\b`,
+	`(?m)^\s*(i )?(agreed|agree)\b`,
+	`\bsounds good to me\b|\bsgtm\b`,
+	`(?m)^\s*(works for me|no objections?|seems reasonable)\b`,
+}
+
+// ConsensusDetector recognizes messages whose sender agrees with the proposal
+type ConsensusDetector struct {
+	patterns []*regexp.Regexp
+}
+
+// NewConsensusDetector compiles the given patterns. A nil or empty slice falls
+// back to the defaults. Patterns are matched against the sender's own text
+// (quotes and forwards removed), lowercased.
+func NewConsensusDetector(patterns []string) (*ConsensusDetector, error) {
+	if len(patterns) == 0 {
+		patterns = DefaultConsensusPatterns
+	}
+	d := &ConsensusDetector{}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid consensus pattern %q: %w", pattern, err)
+		}
+		d.patterns = append(d.patterns, re)
+	}
+	return d, nil
+}
+
+var defaultConsensusDetector, _ = NewConsensusDetector(nil)
+
+// Agrees reports whether the sender's own text in body voices agreement
+func (d *ConsensusDetector) Agrees(body string) bool {
+	text := ownText(body)
+	for _, re := range d.patterns {
+		if re.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReachedConsensus reports whether at least ConsensusParticipants distinct
+// participants other than the thread's author agreed in a reply. Bot senders
+// don't count.
+func (ta *ThreadAnalyzer) ReachedConsensus(threadID string) (bool, error) {
+	rows, err := ta.db.Query(`
+		SELECT LOWER(m.author_email), COALESCE(m.body, '')
+		FROM messages m
+		JOIN threads t ON t.id = m.thread_id
+		WHERE m.thread_id = $1
+		  AND m.message_id <> t.first_message_id
+		  AND NOT m.empty_body
+		  AND LOWER(m.author_email) <> LOWER(t.first_author_email)
+		  AND LOWER(m.author_email) <> ALL($2::text[])
+	`, threadID, ta.BotSendersArg())
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	agreed := make(map[string]bool)
+	for rows.Next() {
+		var email, body string
+		if err := rows.Scan(&email, &body); err != nil {
+			return false, err
+		}
+		if !agreed[email] && ta.Consensus.Agrees(body) {
+			agreed[email] = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	return len(agreed) >= ConsensusParticipants, nil
+}
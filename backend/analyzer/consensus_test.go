@@ -0,0 +1,57 @@
+package analyzer
+
+import "testing"
+
+func TestConsensusAgrees(t *testing.T) {
+	d, err := NewConsensusDetector(nil)
+	if err != nil {
+		t.Fatalf("NewConsensusDetector: %v", err)
+	}
+
+	agreeing := []string{
+		"+1",
+		"+1 from me, this is a clear improvement.",
+		"Agreed. Let's go with the second approach.",
+		"I agree with Tom here.",
+		"> Shall we just drop the GUC?\n\nSounds good to me.",
+		"SGTM",
+		"Works for me.",
+		"No objections.",
+		"Seems reasonable; I'll review the next version.",
+	}
+	for _, body := range agreeing {
+		if !d.Agrees(body) {
+			t.Errorf("agreement not detected in %q", body)
+		}
+	}
+
+	disagreeing := []string{
+		"I don't agree that this is worth the complexity.",
+		"-1, this breaks pg_upgrade.",
+		"> +1\n\nI'm not so sure, see below.",
+		"It seems reasonable at first, but the locking is wrong.",
+		"Tom's counter went from 1 to +10 after this.",
+	}
+	for _, body := range disagreeing {
+		if d.Agrees(body) {
+			t.Errorf("agreement detected in %q", body)
+		}
+	}
+}
+
+func TestConsensusDetectorConfigurable(t *testing.T) {
+	d, err := NewConsensusDetector([]string{`(?m)^\s*lgtm\b`})
+	if err != nil {
+		t.Fatalf("NewConsensusDetector: %v", err)
+	}
+	if !d.Agrees("LGTM, ship it") {
+		t.Error("custom pattern not applied")
+	}
+	if d.Agrees("+1") {
+		t.Error("default patterns still applied alongside custom ones")
+	}
+
+	if _, err := NewConsensusDetector([]string{"("}); err == nil {
+		t.Error("invalid pattern accepted")
+	}
+}
@@ -0,0 +1,62 @@
+package analyzer
+
+import (
+	"database/sql"
+	"regexp"
+	"strings"
+)
+
+// Subject conventions for a discussion split off from an earlier one
+var (
+	// "new topic (was: old topic)", also with [brackets] or without the colon
+	forkWasBracketed = regexp.MustCompile(`(?i)[(\[]\s*was:?\s+(.+?)\s*[)\]]\s*$`)
+	// "new topic - was: old topic" or "new topic, was: old topic"
+	forkWasTrailing = regexp.MustCompile(`(?i)(?:^|[\s,;-])was:\s*(.+?)\s*$`)
+	// "Re: old topic -> new topic"
+	forkArrow = regexp.MustCompile(`^(.+?)\s+(?:->|=>|→)\s+\S`)
+
+	// replyPrefixes are the Re:/Fwd: markers stripped before comparing subjects
+	replyPrefixes = regexp.MustCompile(`(?i)^(?:(?:re|fwd?|aw)(?:\[\d+\])?:\s*)+`)
+)
+
+// ForkedSubject returns the earlier subject a subject says it forked from, with
+// reply prefixes removed, or "" when it follows none of the conventions
+func ForkedSubject(subject string) string {
+	subject = strings.TrimSpace(subject)
+	var prior string
+	for _, re := range []*regexp.Regexp{forkWasBracketed, forkWasTrailing, forkArrow} {
+		if m := re.FindStringSubmatch(subject); m != nil {
+			prior = m[1]
+			break
+		}
+	}
+	return strings.TrimSpace(replyPrefixes.ReplaceAllString(strings.TrimSpace(prior), ""))
+}
+
+// ForkedFrom finds the thread this thread forked from: the most recent thread
+// started before it whose subject, ignoring reply prefixes and case, is the
+// prior subject named in this thread's subject. Returns "" when the subject
+// names no prior topic or no such thread exists.
+func (ta *ThreadAnalyzer) ForkedFrom(threadID string) (string, error) {
+	var subject string
+	if err := ta.db.QueryRow("SELECT subject FROM threads WHERE id = $1", threadID).Scan(&subject); err != nil {
+		return "", err
+	}
+	prior := ForkedSubject(subject)
+	if prior == "" {
+		return "", nil
+	}
+
+	var forkedFrom string
+	err := ta.db.QueryRow(`
+		SELECT f.id FROM threads f, threads t
+		WHERE t.id = $1 AND f.id <> t.id AND f.created_at <= t.created_at
+		  AND LOWER(TRIM(REGEXP_REPLACE(f.subject, '^((re|fwd?|aw)(\[\d+\])?:\s*)+', '', 'i'))) = LOWER($2)
+		ORDER BY f.created_at DESC, f.id ASC
+		LIMIT 1
+	`, threadID, prior).Scan(&forkedFrom)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return forkedFrom, err
+}
@@ -0,0 +1,30 @@
+package analyzer
+
+import "testing"
+
+func TestForkedSubject(t *testing.T) {
+	cases := map[string]string{
+		"Faster COPY (was: Re: COPY performance)":         "COPY performance",
+		"Faster COPY (was COPY performance)":              "COPY performance",
+		"Faster COPY [was: COPY performance]":             "COPY performance",
+		"Re: Faster COPY (was: Re: Re: COPY performance)": "COPY performance",
+		"Faster COPY - was: COPY performance":             "COPY performance",
+		"Faster COPY, was: Fwd: COPY performance":         "COPY performance",
+		"Re: COPY performance -> Faster COPY":             "COPY performance",
+		"Re: COPY performance => Faster COPY":             "COPY performance",
+		"COPY performance → Faster COPY":                  "COPY performance",
+		"  Faster COPY (WAS: COPY performance)  ":         "COPY performance",
+
+		// No prior subject
+		"Re: COPY performance":       "",
+		"Why was this committed?":    "",
+		"Wasted space in heap pages": "",
+		"":                           "",
+		"Faster COPY (was: Re: )":    "",
+	}
+	for subject, want := range cases {
+		if got := ForkedSubject(subject); got != want {
+			t.Errorf("ForkedSubject(%q) = %q, want %q", subject, got, want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package analyzer
+
+import (
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+// PatchTransitions derives a thread's patch lifecycle (proposed -> accepted ->
+// committed, or rejected) from its messages' patch_status values in time order.
+// A message repeating the current status is not a transition.
+func (ta *ThreadAnalyzer) PatchTransitions(threadID string) ([]models.PatchTransition, error) {
+	rows, err := ta.db.Query(`
+		SELECT message_id, patch_status, created_at
+		FROM messages
+		WHERE thread_id = $1 AND patch_status <> ''
+		ORDER BY created_at ASC, message_id ASC
+	`, threadID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transitions := make([]models.PatchTransition, 0)
+	current := ""
+	for rows.Next() {
+		var t models.PatchTransition
+		if err := rows.Scan(&t.MessageID, &t.To, &t.ChangedAt); err != nil {
+			return nil, err
+		}
+		if t.To == current {
+			continue
+		}
+		t.From = current
+		current = t.To
+		transitions = append(transitions, t)
+	}
+	return transitions, rows.Err()
+}
+
+// RecordPatchHistory replaces the thread's stored patch_status_history with the
+// transitions derived from its current messages, so late-arriving or regrouped
+// messages are reflected
+func (ta *ThreadAnalyzer) RecordPatchHistory(threadID string) error {
+	transitions, err := ta.PatchTransitions(threadID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := ta.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Concurrent stores can touch the same thread; locking its row keeps two
+	// rewrites from interleaving and duplicating the history
+	if _, err := tx.Exec("SELECT 1 FROM threads WHERE id = $1 FOR UPDATE", threadID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM patch_status_history WHERE thread_id = $1", threadID); err != nil {
+		return err
+	}
+	for _, t := range transitions {
+		if _, err := tx.Exec(`
+			INSERT INTO patch_status_history (thread_id, message_id, from_status, to_status, changed_at)
+			VALUES ($1, $2, $3, $4, $5)
+		`, threadID, t.MessageID, t.From, t.To, t.ChangedAt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
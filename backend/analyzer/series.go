@@ -0,0 +1,151 @@
+package analyzer
+
+import (
+	"regexp"
+	"strconv"
+
+	"github.com/lib/pq"
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+var (
+	// seriesSubject matches the bracketed tag of a series message, e.g.
+	// "[PATCH v3 2/7]" or "[PATCH 03/10]", capturing version, part and total
+	seriesSubject = regexp.MustCompile(`(?i)\[[^\]]*\bpatch\b(?:[^\]]*?\bv(\d+)\b)?[^\]]*?\b(\d+)/(\d+)\s*\]`)
+	// seriesVersion finds a bare version in a patch tag, e.g. "[PATCH v3]"
+	seriesVersion = regexp.MustCompile(`(?i)\[[^\]]*\bpatch\b[^\]]*?\bv(\d+)\b[^\]]*\]`)
+	// seriesFile matches git-format-patch file names, e.g. "v3-0002-Add-foo.patch"
+	seriesFile = regexp.MustCompile(`(?i)^(?:v(\d+)-)?(\d{4})-.*\.(?:patch|diff)$`)
+)
+
+// seriesPart is one numbered piece of a patch series seen in a thread
+type seriesPart struct {
+	version, number, total int
+}
+
+// seriesParts extracts the series parts a single message carries: from its
+// subject when it is itself one part of a mailed series, and from numbered
+// patch attachments. A total of 0 means the message didn't say.
+func seriesParts(subject string, attachments []string) []seriesPart {
+	var parts []seriesPart
+
+	version := 1
+	if m := seriesVersion.FindStringSubmatch(subject); m != nil {
+		version, _ = strconv.Atoi(m[1])
+	}
+
+	if m := seriesSubject.FindStringSubmatch(subject); m != nil {
+		number, _ := strconv.Atoi(m[2])
+		total, _ := strconv.Atoi(m[3])
+		// 0/N is the cover letter
+		if number > 0 && number <= total {
+			parts = append(parts, seriesPart{version: version, number: number, total: total})
+		}
+	}
+
+	for _, name := range attachments {
+		m := seriesFile.FindStringSubmatch(name)
+		if m == nil {
+			continue
+		}
+		v := version
+		if m[1] != "" {
+			v, _ = strconv.Atoi(m[1])
+		}
+		number, _ := strconv.Atoi(m[2])
+		if number > 0 {
+			parts = append(parts, seriesPart{version: v, number: number})
+		}
+	}
+	return parts
+}
+
+// PatchVersion returns the highest patch version a message states, from a
+// "[PATCH vN]" subject tag or "vN-0001-..." attachment names, or 0 when it
+// states none
+func PatchVersion(subject string, attachments []string) int {
+	version := 0
+	if m := seriesVersion.FindStringSubmatch(subject); m != nil {
+		version, _ = strconv.Atoi(m[1])
+	}
+	for _, name := range attachments {
+		if m := seriesFile.FindStringSubmatch(name); m != nil && m[1] != "" {
+			if v, _ := strconv.Atoi(m[1]); v > version {
+				version = v
+			}
+		}
+	}
+	return version
+}
+
+// buildSeries summarizes the latest version among parts. Without an explicit
+// N/M total, the highest part number seen is taken as the total.
+func buildSeries(parts []seriesPart) *models.PatchSeries {
+	if len(parts) == 0 {
+		return nil
+	}
+
+	latest := 0
+	for _, p := range parts {
+		if p.version > latest {
+			latest = p.version
+		}
+	}
+
+	present := make(map[int]bool)
+	total, highest := 0, 0
+	for _, p := range parts {
+		if p.version != latest {
+			continue
+		}
+		present[p.number] = true
+		if p.total > total {
+			total = p.total
+		}
+		if p.number > highest {
+			highest = p.number
+		}
+	}
+	if total < highest {
+		total = highest
+	}
+
+	series := &models.PatchSeries{Version: latest, Present: len(present), Total: total}
+	for n := 1; n <= total; n++ {
+		if !present[n] {
+			series.Missing = append(series.Missing, int64(n))
+		}
+	}
+	series.Complete = len(series.Missing) == 0
+	return series
+}
+
+// PatchSeries groups the thread's numbered patches (from "[PATCH n/m]" subjects
+// and 0001-style attachment names) into its latest series. It returns nil when
+// the thread has no numbered patches.
+func (ta *ThreadAnalyzer) PatchSeries(threadID string) (*models.PatchSeries, error) {
+	rows, err := ta.db.Query(`
+		SELECT subject, COALESCE(attachments, '{}')
+		FROM messages
+		WHERE thread_id = $1
+		ORDER BY created_at ASC, message_id ASC
+	`, threadID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var parts []seriesPart
+	for rows.Next() {
+		var subject string
+		var attachments []string
+		if err := rows.Scan(&subject, pq.Array(&attachments)); err != nil {
+			continue
+		}
+		parts = append(parts, seriesParts(subject, attachments)...)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return buildSeries(parts), nil
+}
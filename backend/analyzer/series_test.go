@@ -0,0 +1,113 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSeriesParts(t *testing.T) {
+	cases := []struct {
+		subject     string
+		attachments []string
+		want        []seriesPart
+	}{
+		{"[PATCH v3 2/7] Add foo", nil, []seriesPart{{3, 2, 7}}},
+		{"[PATCH 03/10] Add foo", nil, []seriesPart{{1, 3, 10}}},
+		{"Re: [PATCH v2 1/2] Add foo", nil, []seriesPart{{2, 1, 2}}},
+		// The cover letter isn't a part
+		{"[PATCH v2 0/3] Foo series", nil, nil},
+		{"[PATCH 4/3] bogus", nil, nil},
+		{"Re: foo", []string{"v4-0001-Add-foo.patch", "v4-0002-Use-foo.patch", "notes.txt"},
+			[]seriesPart{{4, 1, 0}, {4, 2, 0}}},
+		// Unversioned file names take the subject's version
+		{"[PATCH v5] foo", []string{"0001-Add-foo.diff"}, []seriesPart{{5, 1, 0}}},
+		{"Re: foo", []string{"0000-cover.patch", "foo.patch", "1-foo.patch"}, nil},
+		{"No patch here", nil, nil},
+	}
+	for _, c := range cases {
+		if got := seriesParts(c.subject, c.attachments); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("seriesParts(%q, %v) = %v, want %v", c.subject, c.attachments, got, c.want)
+		}
+	}
+}
+
+func TestBuildSeries(t *testing.T) {
+	type message struct {
+		subject     string
+		attachments []string
+	}
+	cases := []struct {
+		name     string
+		messages []message
+		version  int
+		present  int
+		total    int
+		missing  []int64
+	}{
+		{
+			name: "complete series",
+			messages: []message{
+				{"[PATCH v2 0/3] Foo", nil},
+				{"[PATCH v2 1/3] Add foo", nil},
+				{"[PATCH v2 2/3] Use foo", nil},
+				{"[PATCH v2 3/3] Document foo", nil},
+			},
+			version: 2, present: 3, total: 3,
+		},
+		{
+			name: "missing middle part",
+			messages: []message{
+				{"[PATCH 1/4] a", nil},
+				{"[PATCH 2/4] b", nil},
+				{"[PATCH 4/4] d", nil},
+			},
+			version: 1, present: 3, total: 4, missing: []int64{3},
+		},
+		{
+			// The subject says 5 parts though only two were attached
+			name: "total from the subject",
+			messages: []message{
+				{"[PATCH v3 1/5] a", nil},
+				{"Re: [PATCH v3 1/5] a", []string{"v3-0002-b.patch"}},
+			},
+			version: 3, present: 2, total: 5, missing: []int64{3, 4, 5},
+		},
+		{
+			// Without an N/M subject the highest attachment number is the total
+			name: "total from the attachments",
+			messages: []message{
+				{"Re: foo", []string{"v1-0001-a.patch", "v1-0003-c.patch"}},
+			},
+			version: 1, present: 2, total: 3, missing: []int64{2},
+		},
+		{
+			// Only the latest version counts; v1's missing part no longer matters
+			name: "version bump",
+			messages: []message{
+				{"[PATCH 1/3] a", nil},
+				{"[PATCH 3/3] c", nil},
+				{"Re: foo", []string{"v2-0001-a.patch", "v2-0002-b.patch"}},
+			},
+			version: 2, present: 2, total: 2,
+		},
+	}
+	for _, c := range cases {
+		var parts []seriesPart
+		for _, m := range c.messages {
+			parts = append(parts, seriesParts(m.subject, m.attachments)...)
+		}
+		series := buildSeries(parts)
+		if series == nil {
+			t.Errorf("%s: no series", c.name)
+			continue
+		}
+		if series.Version != c.version || series.Present != c.present || series.Total != c.total ||
+			!reflect.DeepEqual(series.Missing, c.missing) || series.Complete != (len(c.missing) == 0) {
+			t.Errorf("%s: got %+v, want v%d %d/%d missing %v", c.name, series, c.version, c.present, c.total, c.missing)
+		}
+	}
+
+	if series := buildSeries(seriesParts("Re: plain discussion", nil)); series != nil {
+		t.Errorf("thread without numbered patches got series %+v", series)
+	}
+}
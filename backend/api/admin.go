@@ -0,0 +1,167 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/lib/pq"
+	"github.com/pgsql-analyzer/backend/config"
+	"github.com/pgsql-analyzer/backend/db"
+)
+
+// getMigrationsHandler reports which schema migrations the database has applied
+// and which this build expects but hasn't run yet
+func getMigrationsHandler(database *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		applied, pending, err := db.MigrationStatuses(database)
+		if err != nil {
+			log.Printf("Error querying schema migrations: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch migrations"})
+			return
+		}
+
+		// The current version is the highest one applied with nothing missing below it
+		current := 0
+		for _, m := range applied {
+			if len(pending) > 0 && pending[0].Version < m.Version {
+				break
+			}
+			current = m.Version
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"current_version":  current,
+			"expected_version": db.SchemaVersion(),
+			"applied":          applied,
+			"pending":          pending,
+		})
+	}
+}
+
+// tableStats is one table's exact row count and on-disk size (including indexes and TOAST)
+type tableStats struct {
+	Name      string `json:"name"`
+	Rows      int64  `json:"rows"`
+	SizeBytes int64  `json:"size_bytes"`
+	Size      string `json:"size"`
+}
+
+// formatBytes renders a byte count with a binary unit, e.g. "1.5 GiB"
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// dataDirUsage sums the sizes of regular files under dir. A missing directory
+// is reported as empty rather than an error.
+func dataDirUsage(dir string) (int64, int, error) {
+	var total int64
+	files := 0
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		total += info.Size()
+		files++
+		return nil
+	})
+	return total, files, err
+}
+
+// getAdminStatsHandler reports storage usage for capacity planning: database
+// size, per-table row counts and sizes, and DataDir disk usage. Content-level
+// statistics are served by /api/stats.
+func getAdminStatsHandler(database *sql.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		var dbSize int64
+		if err := database.QueryRow("SELECT pg_database_size(current_database())").Scan(&dbSize); err != nil {
+			log.Printf("Error querying database size: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch storage stats"})
+			return
+		}
+
+		// Tables in the configured schema (search_path), largest first
+		rows, err := database.Query(`
+			SELECT c.relname, pg_total_relation_size(c.oid)
+			FROM pg_class c
+			WHERE c.relkind = 'r' AND c.relnamespace = current_schema()::regnamespace
+			ORDER BY pg_total_relation_size(c.oid) DESC, c.relname ASC
+		`)
+		if err != nil {
+			log.Printf("Error querying table sizes: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch storage stats"})
+			return
+		}
+		tables := make([]tableStats, 0)
+		for rows.Next() {
+			var t tableStats
+			if err := rows.Scan(&t.Name, &t.SizeBytes); err != nil {
+				log.Printf("Error scanning table size: %v", err)
+				continue
+			}
+			t.Size = formatBytes(t.SizeBytes)
+			tables = append(tables, t)
+		}
+		rows.Close()
+
+		for i := range tables {
+			query := "SELECT COUNT(*) FROM " + pq.QuoteIdentifier(tables[i].Name)
+			if err := database.QueryRow(query).Scan(&tables[i].Rows); err != nil {
+				log.Printf("Error counting rows in %s: %v", tables[i].Name, err)
+			}
+		}
+
+		dataBytes, dataFiles, err := dataDirUsage(cfg.DataDir)
+		if err != nil {
+			log.Printf("Error walking data directory %s: %v", cfg.DataDir, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch storage stats"})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"database": map[string]interface{}{
+				"size_bytes": dbSize,
+				"size":       formatBytes(dbSize),
+				"tables":     tables,
+			},
+			"data_dir": map[string]interface{}{
+				"path":       cfg.DataDir,
+				"files":      dataFiles,
+				"size_bytes": dataBytes,
+				"size":       formatBytes(dataBytes),
+			},
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pgsql-analyzer/backend/analyzer"
+	"github.com/pgsql-analyzer/backend/parser"
+)
+
+// maxAnalyzeBody caps POST /api/analyze input; a large patch is well under this
+const maxAnalyzeBody = 10 << 20
+
+// analyzeRequest is the text to run patch detection on. Attachments are the
+// file names of attached patches, if the caller has them.
+type analyzeRequest struct {
+	Subject     string   `json:"subject"`
+	Body        string   `json:"body"`
+	Attachments []string `json:"attachments"`
+}
+
+// analyzeResult is the verdict ingest would store for the same message
+type analyzeResult struct {
+	HasPatch     bool             `json:"has_patch"`
+	PatchStatus  string           `json:"patch_status"`
+	CommitFestID string           `json:"commitfest_id"`
+	PatchVersion int              `json:"patch_version"` // 0 without a patch; 1 for a patch that states no version
+	Diffstat     *parser.DiffStat `json:"diffstat"`      // null when the body has no inline diff
+}
+
+// analyzeHandler runs the ingest-time patch detection on ad-hoc text without
+// storing anything
+func analyzeHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req analyzeRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAnalyzeBody)).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	if req.Subject == "" && req.Body == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "subject or body is required"})
+		return
+	}
+
+	result := analyzeResult{
+		HasPatch:     parser.DetectPatch(req.Body, req.Subject),
+		CommitFestID: parser.DetectCommitFestID(req.Body, req.Subject),
+		Diffstat:     parser.ComputeDiffStat(req.Body),
+	}
+	if result.HasPatch {
+		result.PatchStatus = parser.DetectPatchStatus(req.Body, req.Subject)
+		result.PatchVersion = max(analyzer.PatchVersion(req.Subject, req.Attachments), 1)
+	}
+
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/pgsql-analyzer/backend/config"
+)
+
+// requireAdmin gates a mutating handler behind the configured ADMIN_TOKEN.
+// When no token is configured the handler is returned unchanged.
+func requireAdmin(cfg *config.Config, next http.HandlerFunc) http.HandlerFunc {
+	if cfg.AdminToken == "" {
+		return next
+	}
+	expected := []byte(cfg.AdminToken)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		token := strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+		if !strings.HasPrefix(auth, "Bearer ") || subtle.ConstantTimeCompare([]byte(token), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="pgsql-hackers-viewer"`)
+			writeErrorCode(w, http.StatusUnauthorized, "unauthorized", "Missing or invalid admin token")
+			return
+		}
+		next(w, r)
+	}
+}
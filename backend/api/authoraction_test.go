@@ -0,0 +1,57 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/config"
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+func TestNeedsAuthorActionIgnoresAddressCase(t *testing.T) {
+	database := testDB(t)
+	cfg := config.LoadConfig()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	patch := func(root string) *models.Message {
+		return &models.Message{MessageID: root, Subject: "[PATCH] " + root, Author: "Alice", AuthorEmail: "alice@example.com",
+			Body: "diff --git a/x.c b/x.c\n", HasPatch: true, PatchStatus: "proposed", CreatedAt: now.Add(-3 * time.Hour)}
+	}
+	reply := func(id, root, email, body string, hoursAgo int) *models.Message {
+		return &models.Message{MessageID: id, InReplyTo: root, RefersTo: "<" + root + ">", Subject: "Re: [PATCH] " + root,
+			Author: email, AuthorEmail: email, Body: body, CreatedAt: now.Add(-time.Duration(hoursAgo) * time.Hour)}
+	}
+	storeMessagesInDB(database, cfg, []*models.Message{
+		// The author answered the review under another address casing
+		patch("answered@example.com"),
+		reply("review1@example.com", "answered@example.com", "bob@example.com", "This needs a rebase.", 2),
+		reply("rebased@example.com", "answered@example.com", "Alice@Example.COM", "Rebased, thanks.", 1),
+		// The author's own note isn't a reviewer's request
+		patch("selfnote@example.com"),
+		reply("note@example.com", "selfnote@example.com", "ALICE@example.com", "Still needs tests, I know.", 1),
+		// Still waiting on the author
+		patch("waiting@example.com"),
+		reply("review2@example.com", "waiting@example.com", "bob@example.com", "Please add a test.", 1),
+	})
+
+	rows, err := database.Query("SELECT first_message_id, needs_author_action FROM threads")
+	if err != nil {
+		t.Fatalf("query threads: %v", err)
+	}
+	defer rows.Close()
+	got := make(map[string]bool)
+	for rows.Next() {
+		var root string
+		var needs bool
+		if err := rows.Scan(&root, &needs); err != nil {
+			t.Fatalf("scan thread: %v", err)
+		}
+		got[root] = needs
+	}
+	want := map[string]bool{"answered@example.com": false, "selfnote@example.com": false, "waiting@example.com": true}
+	for root, needs := range want {
+		if got[root] != needs {
+			t.Errorf("%s: needs_author_action = %v, want %v", root, got[root], needs)
+		}
+	}
+}
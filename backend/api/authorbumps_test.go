@@ -0,0 +1,72 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/config"
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+func TestIgnoreAuthorBumps(t *testing.T) {
+	database := testDB(t)
+	cfg := config.LoadConfig()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	daysAgo := func(d int) time.Time { return now.Add(-time.Duration(d) * 24 * time.Hour) }
+	msg := func(id, parent, author string, at time.Time) *models.Message {
+		m := &models.Message{
+			MessageID: id, Subject: "idea: faster vacuum", Author: author, AuthorEmail: author + "@example.com",
+			Body: "any thoughts?", CreatedAt: at,
+		}
+		if parent != "" {
+			m.InReplyTo, m.RefersTo, m.Subject = parent, "<"+parent+">", "Re: idea: faster vacuum"
+		}
+		return m
+	}
+	storeMessagesInDB(database, cfg, []*models.Message{
+		// Answered once, 20 days ago, then only bumped by its author
+		msg("answered@example.com", "", "alice", daysAgo(21)),
+		msg("answer@example.com", "answered@example.com", "bob", daysAgo(20)),
+		msg("bump1@example.com", "answer@example.com", "alice", daysAgo(3)),
+		// The same author under another address casing is still a bump
+		msg("bump2@example.com", "bump1@example.com", "Alice", daysAgo(1)),
+		// Never answered by anyone else, started 10 days ago
+		msg("lonely@example.com", "", "carol", daysAgo(10)),
+		msg("lonely-bump@example.com", "lonely@example.com", "CAROL", daysAgo(1)),
+	})
+
+	threadIDs := make(map[string]string)
+	rows, err := database.Query("SELECT first_message_id, id FROM threads")
+	if err != nil {
+		t.Fatalf("query threads: %v", err)
+	}
+	for rows.Next() {
+		var root, id string
+		rows.Scan(&root, &id)
+		threadIDs[root] = id
+	}
+	rows.Close()
+
+	cases := []struct {
+		root        string
+		ignoreBumps bool
+		want        string
+	}{
+		{"answered@example.com", false, "discussion"},
+		{"answered@example.com", true, "stalled"},
+		{"lonely@example.com", false, "discussion"},
+		{"lonely@example.com", true, "stalled"},
+	}
+	for _, c := range cases {
+		ta := newThreadAnalyzer(database, cfg)
+		ta.IgnoreAuthorBumps = c.ignoreBumps
+		got, err := ta.ClassifyThread(threadIDs[c.root])
+		if err != nil {
+			t.Fatalf("ClassifyThread(%s): %v", c.root, err)
+		}
+		if got != c.want {
+			t.Errorf("%s, ignore bumps %v: status %q, want %q", c.root, c.ignoreBumps, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/config"
+)
+
+// authorActivity is one author's message volume within a reporting window
+type authorActivity struct {
+	Author         string    `json:"author"`
+	AuthorEmail    string    `json:"author_email"`
+	MessageCount   int       `json:"message_count"`
+	ThreadCount    int       `json:"thread_count"`
+	PatchCount     int       `json:"patch_count"`
+	FirstMessageAt time.Time `json:"first_message_at"`
+	LastMessageAt  time.Time `json:"last_message_at"`
+}
+
+// authorStatsOrderBy is whitelisted since it is interpolated into the query
+var authorStatsOrderBy = map[string]string{
+	"messages": "COUNT(*) DESC",
+	"threads":  "COUNT(DISTINCT thread_id) DESC",
+	"patches":  "COUNT(*) FILTER (WHERE has_patch) DESC",
+	"first":    "MIN(created_at) ASC",
+	"last":     "MAX(created_at) DESC",
+}
+
+// parseRangeBound parses a since/until value given as a date (2006-01-02) or
+// an RFC 3339 timestamp. A bare date used as an upper bound covers the whole day.
+func parseRangeBound(value string, upper bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// getAuthorStatsHandler counts messages per author between ?since= and ?until=
+// (either may be omitted). Addresses are compared case-insensitively and bot
+// senders are left out. ?sort= is messages (default), threads, patches, first or last.
+func getAuthorStatsHandler(db *sql.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		var since, until interface{}
+		for _, bound := range []struct {
+			name  string
+			upper bool
+			dest  *interface{}
+		}{{"since", false, &since}, {"until", true, &until}} {
+			value := r.URL.Query().Get(bound.name)
+			if value == "" {
+				continue
+			}
+			t, err := parseRangeBound(value, bound.upper)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": fmt.Sprintf("%s must be a date (YYYY-MM-DD) or RFC 3339 timestamp", bound.name),
+				})
+				return
+			}
+			*bound.dest = t
+		}
+
+		sortKey := r.URL.Query().Get("sort")
+		if sortKey == "" {
+			sortKey = "messages"
+		}
+		orderBy, ok := authorStatsOrderBy[sortKey]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "sort must be one of messages, threads, patches, first, last"})
+			return
+		}
+
+		limit, offset := pagination(r, cfg)
+		setPageHeaders(w, limit, offset)
+
+		authors, err := queryAuthorActivity(db, cfg, since, until, orderBy, limit, offset)
+		if err != nil {
+			log.Printf("Error querying author stats: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch author stats"})
+			return
+		}
+
+		json.NewEncoder(w).Encode(authors)
+	}
+}
+
+// queryAuthorActivity aggregates messages per author between since and until
+// (nil for no bound), one row per case-insensitive address, bot senders
+// excluded. orderBy must come from authorStatsOrderBy.
+func queryAuthorActivity(db *sql.DB, cfg *config.Config, since, until interface{}, orderBy string, limit, offset int) ([]authorActivity, error) {
+	// Display name is taken from the author's latest message in the range
+	rows, err := db.Query(`
+		SELECT (ARRAY_AGG(author ORDER BY created_at DESC, message_id DESC))[1], LOWER(author_email),
+		       COUNT(*), COUNT(DISTINCT thread_id), COUNT(*) FILTER (WHERE has_patch),
+		       MIN(created_at), MAX(created_at)
+		FROM messages
+		WHERE ($1::timestamp IS NULL OR created_at >= $1)
+		  AND ($2::timestamp IS NULL OR created_at < $2)
+		  AND LOWER(author_email) <> ALL($3::text[])
+		GROUP BY LOWER(author_email)
+		ORDER BY `+orderBy+`, LOWER(author_email) ASC
+		LIMIT $4 OFFSET $5
+	`, since, until, newThreadAnalyzer(db, cfg).BotSendersArg(), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	authors := make([]authorActivity, 0)
+	for rows.Next() {
+		var a authorActivity
+		if err := rows.Scan(&a.Author, &a.AuthorEmail, &a.MessageCount, &a.ThreadCount,
+			&a.PatchCount, &a.FirstMessageAt, &a.LastMessageAt); err != nil {
+			log.Printf("Error scanning author stats: %v", err)
+			continue
+		}
+		authors = append(authors, a)
+	}
+	return authors, rows.Err()
+}
+
+// contributor is one author's all-time activity on the list
+type contributor struct {
+	Author       string    `json:"author"`
+	AuthorEmail  string    `json:"author_email"`
+	MessageCount int       `json:"message_count"`
+	ThreadCount  int       `json:"thread_count"`
+	FirstSeen    time.Time `json:"first_seen"`
+	LastSeen     time.Time `json:"last_seen"`
+}
+
+// getAuthorsHandler lists the most active contributors over the whole archive,
+// by message count. It is /api/stats/authors without a date range; the same
+// person under several display names is one entry per address.
+func getAuthorsHandler(db *sql.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		limit, offset := pagination(r, cfg)
+		setPageHeaders(w, limit, offset)
+
+		authors, err := queryAuthorActivity(db, cfg, nil, nil, authorStatsOrderBy["messages"], limit, offset)
+		if err != nil {
+			log.Printf("Error querying authors: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch authors"})
+			return
+		}
+
+		contributors := make([]contributor, 0, len(authors))
+		for _, a := range authors {
+			contributors = append(contributors, contributor{
+				Author:       a.Author,
+				AuthorEmail:  a.AuthorEmail,
+				MessageCount: a.MessageCount,
+				ThreadCount:  a.ThreadCount,
+				FirstSeen:    a.FirstMessageAt,
+				LastSeen:     a.LastMessageAt,
+			})
+		}
+		json.NewEncoder(w).Encode(contributors)
+	}
+}
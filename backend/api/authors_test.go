@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/config"
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+func TestAuthorsCountsAndOrdering(t *testing.T) {
+	database := testDB(t)
+	cfg := config.LoadConfig()
+
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	msg := func(id, root, author, email string, hours int) *models.Message {
+		m := &models.Message{MessageID: id, Subject: "topic " + root, Author: author, AuthorEmail: email,
+			Body: "text", CreatedAt: base.Add(time.Duration(hours) * time.Hour)}
+		if id != root {
+			m.InReplyTo, m.RefersTo = root, "<"+root+">"
+		}
+		return m
+	}
+	storeMessagesInDB(database, cfg, []*models.Message{
+		msg("a@example.com", "a@example.com", "Alice", "alice@example.com", 0),
+		msg("b@example.com", "b@example.com", "Bob", "bob@example.com", 1),
+		msg("a.1@example.com", "a@example.com", "Bob", "bob@example.com", 2),
+		// Same person, another display name and address case
+		msg("b.1@example.com", "b@example.com", "A. Smith", "Alice@Example.COM", 3),
+		msg("a.2@example.com", "a@example.com", "Alice Smith", "alice@example.com", 4),
+		msg("a.3@example.com", "a@example.com", "Carol", "carol@example.com", 5),
+	})
+
+	list := func(query string) []contributor {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		getAuthorsHandler(database, cfg)(rec, httptest.NewRequest("GET", "/api/authors"+query, nil))
+		var authors []contributor
+		if err := json.NewDecoder(rec.Body).Decode(&authors); err != nil {
+			t.Fatalf("decode authors (status %d): %v", rec.Code, err)
+		}
+		return authors
+	}
+
+	authors := list("")
+	want := []struct {
+		name, email       string
+		messages, threads int
+		first, last       int
+	}{
+		{"Alice Smith", "alice@example.com", 3, 2, 0, 4},
+		{"Bob", "bob@example.com", 2, 2, 1, 2},
+		{"Carol", "carol@example.com", 1, 1, 5, 5},
+	}
+	if len(authors) != len(want) {
+		t.Fatalf("got %d authors, want %d: %+v", len(authors), len(want), authors)
+	}
+	for i, w := range want {
+		a := authors[i]
+		if a.Author != w.name || a.AuthorEmail != w.email || a.MessageCount != w.messages || a.ThreadCount != w.threads {
+			t.Errorf("author %d = %+v, want %s <%s> with %d messages in %d threads", i, a, w.name, w.email, w.messages, w.threads)
+		}
+		if !a.FirstSeen.Equal(base.Add(time.Duration(w.first)*time.Hour)) || !a.LastSeen.Equal(base.Add(time.Duration(w.last)*time.Hour)) {
+			t.Errorf("%s seen %s to %s, want hours %d to %d", w.email, a.FirstSeen, a.LastSeen, w.first, w.last)
+		}
+	}
+
+	if page := list("?limit=1&offset=1"); len(page) != 1 || page[0].AuthorEmail != "bob@example.com" {
+		t.Errorf("limit=1&offset=1 returned %+v, want only bob", page)
+	}
+}
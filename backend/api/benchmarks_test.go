@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/config"
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+func TestHasBenchmarksFilter(t *testing.T) {
+	database := testDB(t)
+	cfg := config.LoadConfig()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	storeMessagesInDB(database, cfg, []*models.Message{
+		{MessageID: "perf@example.com", Subject: "Faster hash joins", Author: "Alice", AuthorEmail: "alice@example.com",
+			Body: "Idea below.", CreatedAt: now.Add(-2 * time.Hour)},
+		{MessageID: "numbers@example.com", InReplyTo: "perf@example.com", RefersTo: "<perf@example.com>",
+			Subject: "Re: Faster hash joins", Author: "Bob", AuthorEmail: "bob@example.com",
+			Body: "pgbench -c 8 -T 60:\ntps = 4321.5 (without initial connection time)\n", CreatedAt: now.Add(-time.Hour)},
+		{MessageID: "docs@example.com", Subject: "Typo in the docs", Author: "Carol", AuthorEmail: "carol@example.com",
+			Body: "s/teh/the/", CreatedAt: now.Add(-time.Hour)},
+	})
+
+	var flagged int
+	if err := database.QueryRow("SELECT COUNT(*) FROM messages WHERE has_benchmarks").Scan(&flagged); err != nil {
+		t.Fatalf("query messages: %v", err)
+	}
+	if flagged != 1 {
+		t.Errorf("%d messages flagged, want only the pgbench reply", flagged)
+	}
+
+	threads := decodeThreads(t, func(w *httptest.ResponseRecorder) {
+		getThreadsHandler(database, cfg)(w, httptest.NewRequest("GET", "/api/threads?has_benchmarks=true", nil))
+	})
+	if len(threads) != 1 || threads[0].Subject != "Faster hash joins" || !threads[0].HasBenchmarks {
+		t.Errorf("has_benchmarks=true returned %+v, want only the hash join thread", threads)
+	}
+}
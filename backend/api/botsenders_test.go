@@ -0,0 +1,69 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/config"
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+func TestBotSenderIsNotAParticipant(t *testing.T) {
+	database := testDB(t)
+	cfg := config.LoadConfig()
+	cfg.BotSenders = []string{"CFBot@Example.org"}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	storeMessagesInDB(database, cfg, []*models.Message{
+		{MessageID: "patch@example.com", Subject: "[PATCH] tweak", Author: "Alice", AuthorEmail: "alice@example.com",
+			Body: "diff --git a/x.c b/x.c\n", HasPatch: true, PatchStatus: "proposed", CreatedAt: now.Add(-3 * time.Hour)},
+		{MessageID: "review@example.com", InReplyTo: "patch@example.com", RefersTo: "<patch@example.com>",
+			Subject: "Re: [PATCH] tweak", Author: "Bob", AuthorEmail: "bob@example.com",
+			Body: "I tested this and it works.", CreatedAt: now.Add(-2 * time.Hour)},
+		// The bot's automated request for changes mustn't put the thread on the author
+		{MessageID: "cfbot@example.org", InReplyTo: "review@example.com", RefersTo: "<patch@example.com> <review@example.com>",
+			Subject: "Re: [PATCH] tweak", Author: "cfbot", AuthorEmail: "cfbot@example.org",
+			Body: "The patch no longer applies, please rebase.", CreatedAt: now.Add(-time.Hour)},
+	})
+
+	var threadID string
+	var messageCount, uniqueAuthors int
+	var needsAction bool
+	err := database.QueryRow("SELECT id, message_count, unique_authors, needs_author_action FROM threads").
+		Scan(&threadID, &messageCount, &uniqueAuthors, &needsAction)
+	if err != nil {
+		t.Fatalf("query thread: %v", err)
+	}
+	if messageCount != 3 {
+		t.Errorf("message_count = %d, want the bot's message kept in the thread", messageCount)
+	}
+	if uniqueAuthors != 2 {
+		t.Errorf("unique_authors = %d, want 2 (bot excluded)", uniqueAuthors)
+	}
+	if needsAction {
+		t.Error("needs_author_action set by a bot's message")
+	}
+
+	participants, err := newThreadAnalyzer(database, cfg).ClassifyParticipants(threadID)
+	if err != nil {
+		t.Fatalf("ClassifyParticipants: %v", err)
+	}
+	for _, p := range participants {
+		if p.AuthorEmail == "cfbot@example.org" {
+			t.Errorf("bot listed as a participant: %+v", p)
+		}
+	}
+
+	authors, err := queryAuthorActivity(database, cfg, nil, nil, "COUNT(*) DESC", 10, 0)
+	if err != nil {
+		t.Fatalf("queryAuthorActivity: %v", err)
+	}
+	if len(authors) != 2 {
+		t.Errorf("author stats list %d authors, want 2", len(authors))
+	}
+	for _, a := range authors {
+		if a.AuthorEmail == "cfbot@example.org" {
+			t.Error("bot in the author stats")
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// changeFeedCursor is the position of the last thread a change-feed client has
+// seen. updated_at alone isn't unique (a refresh stamps many threads with the
+// same NOW()), so the thread id breaks ties.
+type changeFeedCursor struct {
+	UpdatedAt time.Time `json:"u"`
+	ID        string    `json:"i"`
+}
+
+func (c changeFeedCursor) encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// parseChangeFeed reads ?cursor= (from a previous page's X-Next-Cursor) or
+// ?updated_since=<rfc3339>. It returns nil when neither is given.
+func parseChangeFeed(r *http.Request) (*changeFeedCursor, error) {
+	if v := r.URL.Query().Get("cursor"); v != "" {
+		var c changeFeedCursor
+		b, err := base64.RawURLEncoding.DecodeString(v)
+		if err != nil || json.Unmarshal(b, &c) != nil || c.ID == "" {
+			return nil, errors.New("invalid cursor")
+		}
+		return &c, nil
+	}
+	if v := r.URL.Query().Get("updated_since"); v != "" {
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return nil, errors.New("updated_since must be an RFC 3339 timestamp")
+		}
+		// Timestamps are stored without a zone, in UTC
+		return &changeFeedCursor{UpdatedAt: t.UTC()}, nil
+	}
+	return nil, nil
+}
+
+// where returns the condition selecting threads changed after the cursor, using
+// placeholders from argCount. The casts compare wall-clock values, matching
+// the zone-less updated_at column.
+func (c *changeFeedCursor) where(argCount int) (string, []interface{}) {
+	if c.ID == "" {
+		return fmt.Sprintf(" AND updated_at > $%d::timestamp", argCount), []interface{}{c.UpdatedAt}
+	}
+	return fmt.Sprintf(" AND (updated_at, id) > ($%d::timestamp, $%d)", argCount, argCount+1),
+		[]interface{}{c.UpdatedAt, c.ID}
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/config"
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+// decodeThreads decodes the thread list a threads handler writes
+func decodeThreads(t *testing.T, h func(w *httptest.ResponseRecorder)) []*models.Thread {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h(rec)
+	var threads []*models.Thread
+	if err := json.NewDecoder(rec.Body).Decode(&threads); err != nil {
+		t.Fatalf("decode threads (status %d): %v", rec.Code, err)
+	}
+	return threads
+}
+
+func TestCommitterAttentionReadyThenStale(t *testing.T) {
+	database := testDB(t)
+	cfg := config.LoadConfig()
+	cfg.CommitterAttentionDays = 14
+	list := func() []*models.Thread {
+		return decodeThreads(t, func(w *httptest.ResponseRecorder) {
+			getThreadsHandler(database, cfg)(w, httptest.NewRequest("GET", "/api/threads?committer_attention=true", nil))
+		})
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	patch := &models.Message{
+		MessageID: "patch@example.com", Subject: "[PATCH] Speed up sorting", Author: "Alice", AuthorEmail: "alice@example.com",
+		Body: "diff --git a/sort.c b/sort.c\n", HasPatch: true, CreatedAt: now.Add(-3 * 24 * time.Hour),
+	}
+	ready := &models.Message{
+		MessageID: "ready@example.com", InReplyTo: patch.MessageID, RefersTo: "<patch@example.com>",
+		Subject: "Re: [PATCH] Speed up sorting", Author: "Bob", AuthorEmail: "bob@example.com",
+		Body: "Marked as ready for committer.", PatchStatus: "accepted", CreatedAt: now.Add(-2 * 24 * time.Hour),
+	}
+	storeMessagesInDB(database, cfg, []*models.Message{patch, ready})
+
+	var threadID string
+	var isReady bool
+	if err := database.QueryRow("SELECT id, ready_for_committer FROM threads").Scan(&threadID, &isReady); err != nil {
+		t.Fatalf("query thread: %v", err)
+	}
+	if !isReady {
+		t.Fatal("thread not ready for committer after the accepted review")
+	}
+	if got := list(); len(got) != 0 {
+		t.Errorf("freshly ready thread flagged for committer attention: %d threads", len(got))
+	}
+
+	// Two idle days pass the threshold once it is lowered to one day
+	cfg.CommitterAttentionDays = 1
+	got := list()
+	if len(got) != 1 || got[0].ID != threadID || !got[0].CommitterAttention {
+		t.Fatalf("stale ready thread not flagged: %+v", got)
+	}
+
+	// Committing the patch clears it
+	committed := &models.Message{
+		MessageID: "committed@example.com", InReplyTo: ready.MessageID, RefersTo: "<patch@example.com> <ready@example.com>",
+		Subject: "Re: [PATCH] Speed up sorting", Author: "Carol", AuthorEmail: "carol@example.com",
+		Body: "Committed, thanks.", PatchStatus: "committed", CreatedAt: now.Add(-1 * 24 * time.Hour).Add(-time.Hour),
+	}
+	storeMessagesInDB(database, cfg, []*models.Message{committed})
+	if got := list(); len(got) != 0 {
+		t.Errorf("committed thread still flagged: %+v", got)
+	}
+}
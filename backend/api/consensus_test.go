@@ -0,0 +1,66 @@
+package api
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/config"
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+// discussion is a thread started by alice with one reply per (sender, body)
+func discussion(root string, at time.Time, replies ...[2]string) []*models.Message {
+	msgs := []*models.Message{{
+		MessageID: root, Subject: "Proposal: " + root, Author: "Alice", AuthorEmail: "alice@example.com",
+		Body: "Shall we drop the old syntax?", CreatedAt: at,
+	}}
+	for i, reply := range replies {
+		msgs = append(msgs, &models.Message{
+			MessageID: fmt.Sprintf("%d.%s", i, root), InReplyTo: root, RefersTo: "<" + root + ">",
+			Subject: "Re: Proposal: " + root, Author: reply[0], AuthorEmail: reply[0] + "@example.com",
+			Body: reply[1], CreatedAt: at.Add(time.Duration(i+1) * time.Minute),
+		})
+	}
+	return msgs
+}
+
+func TestConsensusNeedsTwoOtherParticipants(t *testing.T) {
+	database := testDB(t)
+	cfg := config.LoadConfig()
+	cfg.BotSenders = []string{"cfbot@example.com"}
+
+	now := time.Now().UTC().Truncate(time.Hour).Add(-24 * time.Hour)
+	var msgs []*models.Message
+	msgs = append(msgs, discussion("settled@example.com", now,
+		[2]string{"bob", "+1"}, [2]string{"carol", "Sounds good to me."})...)
+	// The same participant agreeing twice is still one voice
+	msgs = append(msgs, discussion("onevoice@example.com", now,
+		[2]string{"bob", "+1"}, [2]string{"bob", "Agreed, again."})...)
+	// The author's own agreement and a bot's don't count
+	msgs = append(msgs, discussion("selfvote@example.com", now,
+		[2]string{"bob", "+1"}, [2]string{"alice", "Agreed."}, [2]string{"cfbot", "+1"})...)
+	msgs = append(msgs, discussion("dissent@example.com", now,
+		[2]string{"bob", "+1"}, [2]string{"carol", "I don't agree, this breaks dumps."})...)
+	storeMessagesInDB(database, cfg, msgs)
+
+	list := func(query string) map[string]bool {
+		threads := decodeThreads(t, func(w *httptest.ResponseRecorder) {
+			getThreadsHandler(database, cfg)(w, httptest.NewRequest("GET", "/api/threads?"+query, nil))
+		})
+		found := map[string]bool{}
+		for _, thread := range threads {
+			found[thread.FirstMessageID] = thread.Consensus
+		}
+		return found
+	}
+
+	if got := list("consensus=true"); len(got) != 1 || !got["settled@example.com"] {
+		t.Errorf("consensus=true returned %v, want only the settled thread", got)
+	}
+	got := list("consensus=false")
+	if len(got) != 3 || got["onevoice@example.com"] || got["selfvote@example.com"] || got["dissent@example.com"] {
+		t.Errorf("consensus=false returned %v, want the other three threads unflagged", got)
+	}
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/pgsql-analyzer/backend/config"
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+// groupingDecision is how one message's thread root was resolved
+type groupingDecision struct {
+	MessageID      string   `json:"message_id"`
+	Subject        string   `json:"subject"`
+	InReplyTo      string   `json:"in_reply_to,omitempty"`
+	References     []string `json:"references"`
+	FirstReference string   `json:"first_reference,omitempty"`
+	Root           string   `json:"root"`
+	MatchesThread  bool     `json:"matches_thread"` // root equals the thread's first_message_id
+}
+
+// threadGroupingHandler re-runs the RFC 5256 root resolution over a thread's
+// stored messages and reports each message's parsed references and resolved
+// root. Only the thread's own messages are considered, so a root that differs
+// from first_message_id points at a message that would split off on its own.
+func threadGroupingHandler(db *sql.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		threadID := mux.Vars(r)["id"]
+
+		var firstMessageID string
+		err := db.QueryRow("SELECT first_message_id FROM threads WHERE id = $1", threadID).Scan(&firstMessageID)
+		if err == sql.ErrNoRows {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Thread not found"})
+			return
+		}
+		if err != nil {
+			log.Printf("Error querying thread: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch thread"})
+			return
+		}
+
+		rows, err := db.Query(`
+			SELECT message_id, subject, in_reply_to, refers_to
+			FROM messages
+			WHERE thread_id = $1
+			ORDER BY created_at ASC, message_id ASC
+		`, threadID)
+		if err != nil {
+			log.Printf("Error querying thread messages: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch messages"})
+			return
+		}
+		defer rows.Close()
+
+		var messages []*models.Message
+		for rows.Next() {
+			msg := &models.Message{}
+			if err := rows.Scan(&msg.MessageID, &msg.Subject, &msg.InReplyTo, &msg.RefersTo); err != nil {
+				log.Printf("Error scanning message: %v", err)
+				continue
+			}
+			messages = append(messages, msg)
+		}
+
+		roots := threadRoots(messages, cfg.ThreadMaxDepth)
+		decisions := make([]groupingDecision, 0, len(messages))
+		rootCounts := make(map[string]int)
+		for _, msg := range messages {
+			refs := parseReferences(msg.RefersTo)
+			if refs == nil {
+				refs = []string{}
+			}
+			root := roots[msg.MessageID]
+			rootCounts[root]++
+			decisions = append(decisions, groupingDecision{
+				MessageID:      msg.MessageID,
+				Subject:        msg.Subject,
+				InReplyTo:      msg.InReplyTo,
+				References:     refs,
+				FirstReference: firstReference(msg),
+				Root:           root,
+				MatchesThread:  root == firstMessageID,
+			})
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"thread_id":        threadID,
+			"first_message_id": firstMessageID,
+			"roots":            rootCounts,
+			"messages":         decisions,
+		})
+	}
+}
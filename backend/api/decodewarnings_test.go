@@ -0,0 +1,45 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/config"
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+func TestDecodeWarningsQueryable(t *testing.T) {
+	database := testDB(t)
+	cfg := config.LoadConfig()
+
+	at := time.Date(2024, 2, 2, 12, 0, 0, 0, time.UTC)
+	storeMessagesInDB(database, cfg, []*models.Message{
+		{MessageID: "clean@example.com", Subject: "clean", Author: "A", AuthorEmail: "a@example.com", Body: "ok", CreatedAt: at},
+		{MessageID: "garbled@example.com", Subject: "garbled", Author: "B", AuthorEmail: "b@example.com", Body: "--XYZ",
+			DecodeWarning: "multipart boundary not found", CreatedAt: at.Add(time.Minute)},
+		{MessageID: "binary@example.com", Subject: "binary", Author: "C", AuthorEmail: "c@example.com", Body: "x",
+			DecodeWarning: "no text parts in multipart body", CreatedAt: at.Add(2 * time.Minute)},
+	})
+
+	query := func(path string) []string {
+		rec := httptest.NewRecorder()
+		getDecodeWarningsHandler(database, cfg)(rec, httptest.NewRequest("GET", path, nil))
+		var messages []*models.Message
+		if err := json.NewDecoder(rec.Body).Decode(&messages); err != nil {
+			t.Fatalf("GET %s: decode: %v", path, err)
+		}
+		var ids []string
+		for _, m := range messages {
+			ids = append(ids, m.MessageID)
+		}
+		return ids
+	}
+	if got := query("/api/stats/decode-warnings"); len(got) != 2 || got[0] != "binary@example.com" || got[1] != "garbled@example.com" {
+		t.Errorf("all warnings: %v, want the two flagged messages, newest first", got)
+	}
+	if got := query("/api/stats/decode-warnings?warning=boundary"); len(got) != 1 || got[0] != "garbled@example.com" {
+		t.Errorf("warning=boundary: %v, want garbled@example.com", got)
+	}
+}
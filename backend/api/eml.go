@@ -0,0 +1,120 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"mime/quotedprintable"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+// maxEMLLine is the longest line RFC 5322 allows before re-encoding is needed
+const maxEMLLine = 998
+
+// emlFileNameUnsafe matches characters replaced in a download file name
+var emlFileNameUnsafe = regexp.MustCompile(`[^A-Za-z0-9._@-]+`)
+
+// needsQuotedPrintable reports whether body can't be sent as 7bit: it has
+// non-ASCII bytes or a line too long for RFC 5322
+func needsQuotedPrintable(body string) bool {
+	for i := 0; i < len(body); i++ {
+		if body[i] >= 0x80 {
+			return true
+		}
+	}
+	for _, line := range strings.Split(body, "\n") {
+		if len(line) > maxEMLLine {
+			return true
+		}
+	}
+	return false
+}
+
+// foldIDs writes a list of message-ids one per folded line so long
+// References headers stay within line limits
+func foldIDs(ids []string) string {
+	for i, id := range ids {
+		ids[i] = "<" + strings.Trim(id, "<>") + ">"
+	}
+	return strings.Join(ids, "\r\n ")
+}
+
+// buildEML reconstructs msg as an RFC 5322 message with CRLF line endings.
+// Non-ASCII names and subjects are RFC 2047 encoded and the body is sent
+// quoted-printable when it isn't plain 7bit text.
+func buildEML(msg *models.Message) []byte {
+	var b bytes.Buffer
+	from := mail.Address{Name: msg.Author, Address: msg.AuthorEmail}
+	if msg.Author == msg.AuthorEmail {
+		from.Name = ""
+	}
+	fmt.Fprintf(&b, "Message-ID: <%s>\r\n", msg.MessageID)
+	fmt.Fprintf(&b, "Date: %s\r\n", msg.CreatedAt.Format("Mon, 02 Jan 2006 15:04:05 -0700"))
+	fmt.Fprintf(&b, "From: %s\r\n", from.String())
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	if msg.InReplyTo != "" {
+		fmt.Fprintf(&b, "In-Reply-To: <%s>\r\n", strings.Trim(msg.InReplyTo, "<>"))
+	}
+	if refs := strings.Fields(msg.RefersTo); len(refs) > 0 {
+		fmt.Fprintf(&b, "References: %s\r\n", foldIDs(refs))
+	}
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+
+	body := strings.ReplaceAll(msg.Body, "\r\n", "\n")
+	if needsQuotedPrintable(body) {
+		b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		qp := quotedprintable.NewWriter(&b)
+		qp.Write([]byte(body))
+		qp.Close()
+	} else {
+		b.WriteString("Content-Transfer-Encoding: 7bit\r\n\r\n")
+		b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	}
+	if !bytes.HasSuffix(b.Bytes(), []byte("\r\n")) {
+		b.WriteString("\r\n")
+	}
+	return b.Bytes()
+}
+
+// downloadMessageHandler serves a stored message as an .eml attachment
+func downloadMessageHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id := vars["id"]
+
+		msg := &models.Message{}
+		err := db.QueryRow(`
+			SELECT message_id, in_reply_to, refers_to, subject, author, author_email, body, created_at
+			FROM messages
+			WHERE id = $1
+		`, id).Scan(&msg.MessageID, &msg.InReplyTo, &msg.RefersTo, &msg.Subject,
+			&msg.Author, &msg.AuthorEmail, &msg.Body, &msg.CreatedAt)
+		if err == sql.ErrNoRows {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Message not found"})
+			return
+		} else if err != nil {
+			log.Printf("Error querying message: %v", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch message"})
+			return
+		}
+
+		fileName := emlFileNameUnsafe.ReplaceAllString(msg.MessageID, "_") + ".eml"
+		w.Header().Set("Content-Type", "message/rfc822")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileName))
+		w.Write(buildEML(msg))
+	}
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/config"
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+func TestHeaderOnlyMessagesStoredButNotDiscussion(t *testing.T) {
+	database := testDB(t)
+	cfg := config.LoadConfig()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	msgs := []*models.Message{{
+		MessageID: "patch@example.com", Subject: "[PATCH] tweak", Author: "Alice", AuthorEmail: "alice@example.com",
+		Body: "diff --git a/x.c b/x.c\n", HasPatch: true, PatchStatus: "proposed", CreatedAt: now.Add(-time.Hour),
+	}}
+	// Enough header-only replies to make the thread look in-progress if they counted
+	for i := 0; i < 4; i++ {
+		msgs = append(msgs, &models.Message{
+			MessageID: fmt.Sprintf("notice.%d@example.com", i), InReplyTo: "patch@example.com", RefersTo: "<patch@example.com>",
+			Subject: "Re: [PATCH] tweak", Author: "Notifier", AuthorEmail: "notices@example.com",
+			EmptyBody: true, CreatedAt: now.Add(time.Duration(i-30) * time.Minute),
+		})
+	}
+	storeMessagesInDB(database, cfg, msgs)
+
+	var threadID, status string
+	var stored, empty int
+	err := database.QueryRow(`
+		SELECT t.id, t.status, COUNT(m.id), COUNT(m.id) FILTER (WHERE m.empty_body)
+		FROM threads t JOIN messages m ON m.thread_id = t.id
+		GROUP BY t.id, t.status
+	`).Scan(&threadID, &status, &stored, &empty)
+	if err != nil {
+		t.Fatalf("query thread: %v", err)
+	}
+	if stored != 5 || empty != 4 {
+		t.Errorf("stored %d messages, %d flagged empty; want 5 and 4", stored, empty)
+	}
+	if status != "has-patch" {
+		t.Errorf("status = %q, want has-patch: header-only replies aren't discussion", status)
+	}
+}
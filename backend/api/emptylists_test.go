@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/pgsql-analyzer/backend/config"
+)
+
+func TestEmptyListsEncodeAsArrays(t *testing.T) {
+	database := testDB(t)
+	router := mux.NewRouter()
+	RegisterRoutes(router, database, config.LoadConfig())
+
+	// A thread with no messages, for the per-thread lists
+	_, err := database.Exec(`
+		INSERT INTO threads (id, subject, first_message_id, first_author, first_author_email, created_at)
+		VALUES ('empty', 'empty', 'empty@example.com', 'A', 'a@example.com', NOW())
+	`)
+	if err != nil {
+		t.Fatalf("insert thread: %v", err)
+	}
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s %s: status %d: %s", method, path, rec.Code, rec.Body)
+		}
+		return rec
+	}
+
+	// Endpoints answering with a bare list
+	for _, path := range []string{
+		"/api/threads",
+		"/api/threads?status=no-such-status",
+		"/api/threads/empty/messages",
+		"/api/threads/empty/authors-over-time",
+		"/api/threads/empty/patch-history",
+		"/api/stats/largest-messages",
+		"/api/stats/decode-warnings",
+		"/api/stats/authors",
+		"/api/authors",
+	} {
+		if got := strings.TrimSpace(serve("GET", path, "").Body.String()); got != "[]" {
+			t.Errorf("GET %s = %s, want []", path, got)
+		}
+	}
+	if got := strings.TrimSpace(serve("POST", "/api/threads/search", `{}`).Body.String()); got != "[]" {
+		t.Errorf("POST /api/threads/search = %s, want []", got)
+	}
+
+	// Endpoints wrapping their lists in an object
+	for _, c := range []struct {
+		method, path, body string
+		keys               []string
+	}{
+		{"GET", "/api/threads/empty/timeline", "", []string{"buckets"}},
+		{"GET", "/api/stats/timezones", "", []string{"offsets"}},
+		{"GET", "/api/stats/by-list", "", []string{"lists"}},
+		{"GET", "/api/sync/history", "", []string{"runs"}},
+		{"POST", "/api/messages/exists", `["nobody@example.com"]`, []string{"existing"}},
+	} {
+		var body map[string]json.RawMessage
+		if err := json.NewDecoder(serve(c.method, c.path, c.body).Body).Decode(&body); err != nil {
+			t.Fatalf("%s %s: decode: %v", c.method, c.path, err)
+		}
+		for _, key := range c.keys {
+			if got := string(body[key]); got != "[]" {
+				t.Errorf("%s %s: %q = %s, want []", c.method, c.path, key, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/pgsql-analyzer/backend/parser"
+)
+
+// maxExistsIDs caps one existence check; callers reconcile larger sets in batches
+const maxExistsIDs = 1000
+
+// messageExistence splits the requested ids, as the caller sent them, by
+// whether a message with that id is stored
+type messageExistence struct {
+	Existing []string `json:"existing"`
+	Missing  []string `json:"missing"`
+}
+
+// messagesExistHandler takes a JSON array of message-ids and reports which are
+// stored. Ids match with or without angle brackets and regardless of the
+// domain's case, as Message-ID headers are normalized on ingest.
+func messagesExistHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		var ids []string
+		if err := json.NewDecoder(r.Body).Decode(&ids); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Body must be a JSON array of message-ids"})
+			return
+		}
+		if len(ids) > maxExistsIDs {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": fmt.Sprintf("At most %d message-ids can be checked at once", maxExistsIDs),
+			})
+			return
+		}
+
+		// Legacy rows may hold an id stored before normalization, so both forms are looked up
+		lookup := make([]string, 0, 2*len(ids))
+		for _, id := range ids {
+			raw := strings.Trim(strings.TrimSpace(id), "<>")
+			lookup = append(lookup, raw, parser.NormalizeMessageID(raw))
+		}
+
+		rows, err := db.Query("SELECT message_id FROM messages WHERE message_id = ANY($1)", pq.Array(lookup))
+		if err != nil {
+			log.Printf("Error checking message existence: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to check messages"})
+			return
+		}
+		defer rows.Close()
+
+		stored := make(map[string]bool)
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				log.Printf("Error scanning message id: %v", err)
+				continue
+			}
+			stored[id] = true
+		}
+
+		result := messageExistence{Existing: make([]string, 0), Missing: make([]string, 0)}
+		for i, id := range ids {
+			if stored[lookup[2*i]] || stored[lookup[2*i+1]] {
+				result.Existing = append(result.Existing, id)
+			} else {
+				result.Missing = append(result.Missing, id)
+			}
+		}
+
+		json.NewEncoder(w).Encode(result)
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/config"
+)
+
+// exportThreadsCSVHandler streams the thread listing as CSV. It accepts the
+// same filters as /api/threads but is not paginated; rows are written as they
+// are read from the cursor so large exports are never buffered in memory.
+func exportThreadsCSVHandler(db *sql.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		where, args := threadFilters(r, cfg)
+		query := `SELECT ` + threadColumns + ` FROM threads WHERE 1=1` + where +
+			` ORDER BY ` + threadOrderBy(r)
+
+		rows, err := db.QueryContext(r.Context(), query, args...)
+		if err != nil {
+			if queryTimedOut(w, r, cfg) {
+				return
+			}
+			log.Printf("Error querying threads for export: %v", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to export threads"})
+			return
+		}
+		defer rows.Close()
+
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="threads.csv"`)
+
+		cw := csv.NewWriter(w)
+		cw.Write([]string{
+			"id", "subject", "first_author", "first_author_email", "message_count",
+			"unique_authors", "status", "created_at", "last_message_at",
+		})
+
+		for rows.Next() {
+			thread, err := scanThread(rows)
+			if err != nil {
+				log.Printf("Error scanning thread: %v", err)
+				continue
+			}
+
+			lastMessageAt := ""
+			if thread.LastMessageAt != nil {
+				lastMessageAt = thread.LastMessageAt.Format(time.RFC3339)
+			}
+
+			if err := cw.Write([]string{
+				thread.ID,
+				thread.Subject,
+				thread.FirstAuthor,
+				thread.FirstAuthorEmail,
+				strconv.Itoa(thread.MessageCount),
+				strconv.Itoa(thread.UniqueAuthors),
+				thread.Status,
+				thread.CreatedAt.Format(time.RFC3339),
+				lastMessageAt,
+			}); err != nil {
+				// Client went away; nothing useful left to do
+				log.Printf("Error writing CSV export: %v", err)
+				return
+			}
+		}
+
+		// The CSV header has already been sent, so a deadline hit mid-export
+		// can only cut the file short
+		if err := rows.Err(); err != nil {
+			log.Printf("Error reading threads for export: %v", err)
+		}
+
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			log.Printf("Error flushing CSV export: %v", err)
+		}
+	}
+}
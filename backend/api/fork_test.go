@@ -0,0 +1,58 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/config"
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+func TestForkedThreadLinked(t *testing.T) {
+	database := testDB(t)
+	cfg := config.LoadConfig()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	start := func(id, subject string, hoursAgo int) *models.Message {
+		return &models.Message{MessageID: id, Subject: subject, Author: "Alice", AuthorEmail: "alice@example.com",
+			Body: "text", CreatedAt: now.Add(-time.Duration(hoursAgo) * time.Hour)}
+	}
+	storeMessagesInDB(database, cfg, []*models.Message{
+		start("old@example.com", "COPY performance", 5),
+		start("fork@example.com", "Faster COPY (was: Re: copy PERFORMANCE)", 3),
+		start("arrow@example.com", "Re: COPY performance -> COPY FREEZE", 2),
+		// Names a prior topic that was never discussed
+		start("unknown@example.com", "Faster COPY (was: COPY on Windows)", 1),
+		start("plain@example.com", "Unrelated", 1),
+	})
+
+	rows, err := database.Query(`
+		SELECT t.first_message_id, COALESCE(f.first_message_id, '')
+		FROM threads t LEFT JOIN threads f ON f.id = t.forked_from
+	`)
+	if err != nil {
+		t.Fatalf("query threads: %v", err)
+	}
+	defer rows.Close()
+	got := make(map[string]string)
+	for rows.Next() {
+		var root, parent string
+		if err := rows.Scan(&root, &parent); err != nil {
+			t.Fatalf("scan thread: %v", err)
+		}
+		got[root] = parent
+	}
+
+	want := map[string]string{
+		"old@example.com":     "",
+		"fork@example.com":    "old@example.com",
+		"arrow@example.com":   "old@example.com",
+		"unknown@example.com": "",
+		"plain@example.com":   "",
+	}
+	for root, parent := range want {
+		if got[root] != parent {
+			t.Errorf("%s forked from %q, want %q", root, got[root], parent)
+		}
+	}
+}
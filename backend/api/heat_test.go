@@ -0,0 +1,91 @@
+package api
+
+import (
+	"database/sql"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/config"
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+func TestHeatWithFutureDatedMessage(t *testing.T) {
+	database := testDB(t)
+	cfg := config.LoadConfig()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	storeMessagesInDB(database, cfg, []*models.Message{
+		// Far enough ahead that exp(-age/decay) would overflow a double
+		{MessageID: "future@example.com", Subject: "Clock skew", Author: "Alice", AuthorEmail: "alice@example.com",
+			Body: "sent from the future", CreatedAt: now.AddDate(4, 0, 0)},
+		{MessageID: "recent@example.com", Subject: "Recent", Author: "Bob", AuthorEmail: "bob@example.com",
+			Body: "sent an hour ago", CreatedAt: now.Add(-time.Hour)},
+	})
+
+	if err := newThreadAnalyzer(database, cfg).RefreshHeat(nil); err != nil {
+		t.Fatalf("RefreshHeat: %v", err)
+	}
+	heat := threadHeat(t, database)
+	// A future message counts as brand new
+	if got := heat["future@example.com"]; math.Abs(got-1) > 0.01 {
+		t.Errorf("future-dated thread heat = %v, want about 1", got)
+	}
+	if got := heat["recent@example.com"]; got <= 0 || got >= 1 {
+		t.Errorf("recent thread heat = %v, want between 0 and 1", got)
+	}
+}
+
+func TestRefreshHeatOnlyTouchesGivenThreads(t *testing.T) {
+	database := testDB(t)
+	cfg := config.LoadConfig()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	storeMessagesInDB(database, cfg, []*models.Message{
+		{MessageID: "a@example.com", Subject: "A", Author: "Alice", AuthorEmail: "alice@example.com", Body: "a", CreatedAt: now.Add(-time.Hour)},
+		{MessageID: "b@example.com", Subject: "B", Author: "Bob", AuthorEmail: "bob@example.com", Body: "b", CreatedAt: now.Add(-time.Hour)},
+	})
+	// A stale score on B, as if it had cooled since it was last refreshed
+	if _, err := database.Exec("UPDATE threads SET heat = 99 WHERE first_message_id = 'b@example.com'"); err != nil {
+		t.Fatalf("set heat: %v", err)
+	}
+	var threadA string
+	if err := database.QueryRow("SELECT id FROM threads WHERE first_message_id = 'a@example.com'").Scan(&threadA); err != nil {
+		t.Fatalf("query thread: %v", err)
+	}
+
+	ta := newThreadAnalyzer(database, cfg)
+	if err := ta.UpdateThreadActivity(threadA); err != nil {
+		t.Fatalf("UpdateThreadActivity: %v", err)
+	}
+	if got := threadHeat(t, database)["b@example.com"]; got != 99 {
+		t.Errorf("refreshing A changed B's heat to %v", got)
+	}
+
+	if err := ta.CoolHeat(); err != nil {
+		t.Fatalf("CoolHeat: %v", err)
+	}
+	if got := threadHeat(t, database)["b@example.com"]; got >= 1 {
+		t.Errorf("B's heat = %v after CoolHeat, want it recomputed", got)
+	}
+}
+
+// threadHeat maps each thread's first message-id to its heat
+func threadHeat(t *testing.T, database *sql.DB) map[string]float64 {
+	t.Helper()
+	rows, err := database.Query("SELECT first_message_id, heat FROM threads")
+	if err != nil {
+		t.Fatalf("query heat: %v", err)
+	}
+	defer rows.Close()
+	heat := make(map[string]float64)
+	for rows.Next() {
+		var id string
+		var h float64
+		if err := rows.Scan(&id, &h); err != nil {
+			t.Fatalf("scan heat: %v", err)
+		}
+		heat[id] = h
+	}
+	return heat
+}
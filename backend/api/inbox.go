@@ -0,0 +1,91 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/pgsql-analyzer/backend/config"
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+// inboxCategory is one group of threads in a participant's inbox
+type inboxCategory struct {
+	Category    string           `json:"category"`
+	Description string           `json:"description"`
+	Threads     []*models.Thread `json:"threads"`
+}
+
+// inboxQueries select the threads for each category; $1 is the lowercased email
+var inboxQueries = []struct {
+	category, description, where string
+}{
+	{
+		category:    "changes_requested",
+		description: "Threads you started where a reviewer has asked for changes since your last message",
+		where:       `LOWER(first_author_email) = $1 AND needs_author_action`,
+	},
+	{
+		category:    "new_version_to_review",
+		description: "Threads you took part in where the author posted a new patch after your last message",
+		where: `LOWER(first_author_email) <> $1 AND EXISTS (
+			SELECT 1 FROM messages p
+			WHERE p.thread_id = threads.id AND p.has_patch
+			  AND LOWER(p.author_email) = LOWER(threads.first_author_email)
+			  AND p.created_at > (
+				SELECT MAX(m.created_at) FROM messages m
+				WHERE m.thread_id = threads.id AND LOWER(m.author_email) = $1
+			  )
+		)`,
+	},
+}
+
+// getInboxHandler answers "what needs me?" for ?email=, combining the
+// changes-requested signal on the participant's own threads with new patch
+// versions on threads they reviewed. Each category is paginated independently.
+func getInboxHandler(db *sql.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		email := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("email")))
+		if email == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "email is required"})
+			return
+		}
+		limit, offset := pagination(r, cfg)
+		setPageHeaders(w, limit, offset)
+
+		categories := make([]inboxCategory, 0, len(inboxQueries))
+		for _, q := range inboxQueries {
+			rows, err := db.Query(`SELECT `+threadColumns+` FROM threads WHERE `+q.where+`
+				ORDER BY last_message_at DESC LIMIT $2 OFFSET $3`, email, limit, offset)
+			if err != nil {
+				log.Printf("Error querying inbox (%s): %v", q.category, err)
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch inbox"})
+				return
+			}
+
+			category := inboxCategory{Category: q.category, Description: q.description, Threads: make([]*models.Thread, 0)}
+			for rows.Next() {
+				thread, err := scanThread(rows)
+				if err != nil {
+					log.Printf("Error scanning thread: %v", err)
+					continue
+				}
+				flagCommitterAttention(thread, cfg)
+				category.Threads = append(category.Threads, thread)
+			}
+			rows.Close()
+			categories = append(categories, category)
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"email":      email,
+			"categories": categories,
+		})
+	}
+}
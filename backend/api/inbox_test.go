@@ -0,0 +1,48 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/config"
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+func TestInboxNewVersionIgnoresAddressCase(t *testing.T) {
+	database := testDB(t)
+	cfg := config.LoadConfig()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	storeMessagesInDB(database, cfg, []*models.Message{
+		{MessageID: "v1@example.com", Subject: "[PATCH] tweak", Author: "Alice", AuthorEmail: "alice@example.com",
+			Body: "diff --git a/x.c b/x.c\n", HasPatch: true, PatchStatus: "proposed", CreatedAt: now.Add(-3 * time.Hour)},
+		{MessageID: "review@example.com", InReplyTo: "v1@example.com", RefersTo: "<v1@example.com>",
+			Subject: "Re: [PATCH] tweak", Author: "Bob", AuthorEmail: "bob@example.com",
+			Body: "A few comments below.", CreatedAt: now.Add(-2 * time.Hour)},
+		// The new version comes from the author's address in another casing
+		{MessageID: "v2@example.com", InReplyTo: "review@example.com", RefersTo: "<v1@example.com> <review@example.com>",
+			Subject: "Re: [PATCH] tweak", Author: "Alice", AuthorEmail: "Alice@Example.COM",
+			Body: "v2 attached.\n\ndiff --git a/x.c b/x.c\n", HasPatch: true, PatchStatus: "proposed", CreatedAt: now.Add(-time.Hour)},
+	})
+
+	rec := httptest.NewRecorder()
+	getInboxHandler(database, cfg)(rec, httptest.NewRequest("GET", "/api/inbox?email=Bob@example.com", nil))
+	var inbox struct {
+		Categories []inboxCategory `json:"categories"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&inbox); err != nil {
+		t.Fatalf("decode inbox (status %d): %v", rec.Code, err)
+	}
+	for _, category := range inbox.Categories {
+		if category.Category != "new_version_to_review" {
+			continue
+		}
+		if len(category.Threads) != 1 || category.Threads[0].FirstMessageID != "v1@example.com" {
+			t.Errorf("new_version_to_review = %+v, want the tweak thread", category.Threads)
+		}
+		return
+	}
+	t.Fatal("no new_version_to_review category")
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/config"
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+// threadMessages returns a root and its replies for one synthetic thread
+func threadMessages(root string, replies int, at time.Time) []*models.Message {
+	msgs := []*models.Message{{
+		MessageID: root, Subject: "thread " + root, Author: "Alice", AuthorEmail: "alice@example.com",
+		Body: "proposal", CreatedAt: at,
+	}}
+	for i := 0; i < replies; i++ {
+		msgs = append(msgs, &models.Message{
+			MessageID: fmt.Sprintf("%d.%s", i, root), InReplyTo: root, RefersTo: "<" + root + ">",
+			Subject: "Re: thread " + root, Author: "Bob", AuthorEmail: "bob@example.com",
+			Body: "reply", CreatedAt: at.Add(time.Duration(i+1) * time.Minute),
+		})
+	}
+	return msgs
+}
+
+// seedThreads stores n threads of a past month, as earlier syncs would have
+func seedThreads(database *sql.DB, cfg *config.Config, n int) {
+	at := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	var msgs []*models.Message
+	for i := 0; i < n; i++ {
+		msgs = append(msgs, threadMessages(fmt.Sprintf("seed.%d@example.com", i), 1, at.Add(time.Duration(i)*time.Hour))...)
+	}
+	storeMessagesInDB(database, cfg, msgs)
+}
+
+func TestIncrementalStoreLeavesUntouchedThreadsAlone(t *testing.T) {
+	database := testDB(t)
+	cfg := config.LoadConfig()
+	seedThreads(database, cfg, 20)
+
+	var before time.Time
+	if err := database.QueryRow("SELECT MAX(updated_at) FROM threads").Scan(&before); err != nil {
+		t.Fatalf("query threads: %v", err)
+	}
+
+	// A new month touching one existing thread and starting one more
+	at := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	batch := threadMessages("new@example.com", 2, at)
+	batch = append(batch, &models.Message{
+		MessageID: "late@example.com", InReplyTo: "seed.3@example.com", RefersTo: "<seed.3@example.com>",
+		Subject: "Re: thread seed.3@example.com", Author: "Carol", AuthorEmail: "carol@example.com",
+		Body: "late reply", CreatedAt: at,
+	})
+	storeMessagesInDB(database, cfg, batch)
+
+	rows, err := database.Query("SELECT first_message_id FROM threads WHERE updated_at > $1 ORDER BY first_message_id", before)
+	if err != nil {
+		t.Fatalf("query updated threads: %v", err)
+	}
+	defer rows.Close()
+	var updated []string
+	for rows.Next() {
+		var id string
+		rows.Scan(&id)
+		updated = append(updated, id)
+	}
+	if want := []string{"new@example.com", "seed.3@example.com"}; fmt.Sprint(updated) != fmt.Sprint(want) {
+		t.Errorf("threads updated by the batch: %v, want %v", updated, want)
+	}
+}
+
+func BenchmarkStoreIncrementalMonth(b *testing.B) {
+	database := testDB(b)
+	cfg := config.LoadConfig()
+	seedThreads(database, cfg, 500)
+
+	// A quiet month: a few replies to existing threads and a couple of new ones
+	at := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var batch []*models.Message
+		for j := 0; j < 2; j++ {
+			batch = append(batch, threadMessages(fmt.Sprintf("new.%d.%d@example.com", i, j), 3, at)...)
+		}
+		for j := 0; j < 5; j++ {
+			root := fmt.Sprintf("seed.%d@example.com", (i*5+j)%500)
+			batch = append(batch, &models.Message{
+				MessageID: fmt.Sprintf("late.%d.%d@example.com", i, j), InReplyTo: root, RefersTo: "<" + root + ">",
+				Subject: "Re: thread " + root, Author: "Carol", AuthorEmail: "carol@example.com",
+				Body: "late reply", CreatedAt: at,
+			})
+		}
+		storeMessagesInDB(database, cfg, batch)
+	}
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/config"
+)
+
+// ingestDirRequest names a directory under DataDir; empty means DataDir itself
+type ingestDirRequest struct {
+	Dir string `json:"dir"`
+}
+
+// resolveDataSubdir joins sub onto dataDir, rejecting absolute paths and any
+// path that would escape dataDir
+func resolveDataSubdir(dataDir, sub string) (string, error) {
+	if filepath.IsAbs(sub) {
+		return "", fmt.Errorf("dir must be relative to the data directory")
+	}
+	dir := filepath.Join(dataDir, sub)
+	rel, err := filepath.Rel(dataDir, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("dir must be inside the data directory")
+	}
+	return dir, nil
+}
+
+// ingestDirHandler parses and stores every mbox file in a directory under
+// DataDir. It runs in the background as a sync, so progress is reported by
+// /api/sync/progress (one step per file) and the run is recorded in sync history.
+func ingestDirHandler(db *sql.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// An empty body ingests DataDir itself
+		var req ingestDirRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON body"})
+			return
+		}
+		dir, err := resolveDataSubdir(cfg.DataDir, req.Dir)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Directory not found"})
+			return
+		}
+
+		ctx, ok := GlobalSyncState.TryStartSync()
+		if !ok {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{"error": "A sync is already in progress"})
+			return
+		}
+		go performDirIngest(ctx, db, cfg, dir)
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"status":    "Directory ingest started",
+			"dir":       dir,
+			"timestamp": time.Now().Format(time.RFC3339),
+		})
+	}
+}
+
+// performDirIngest stores the mbox files in dir one at a time. Files are left
+// in place: they belong to the operator, so CLEANUP_MBOX_FILES doesn't apply.
+// Callers must claim the sync with GlobalSyncState.TryStartSync first; a
+// cancelled ctx stops it before the next file.
+func performDirIngest(ctx context.Context, db *sql.DB, cfg *config.Config, dir string) {
+	defer GlobalSyncState.SetSyncing(false)
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("PANIC in performDirIngest", "panic", r)
+		}
+	}()
+
+	mboxParser := newMboxParserIn(cfg, dir)
+	files, err := mboxParser.ListMboxFiles()
+	if err != nil {
+		slog.Error("Error listing mbox files", "dir", dir, "error", err)
+		return
+	}
+	slog.Info("Ingesting mbox directory", "dir", dir, "files", len(files))
+	GlobalSyncState.Update(0, len(files), "")
+
+	var processed, totalStored int
+	completed := false
+	runID := startSyncRun(db, len(files))
+	defer func() {
+		finishSyncRun(db, runID, processed, totalStored, !completed)
+	}()
+
+	start := time.Now()
+	for _, file := range files {
+		if ctx.Err() != nil {
+			slog.Info("Directory ingest cancelled", "dir", dir, "processed", processed, "stored", totalStored)
+			return
+		}
+		processed++
+		GlobalSyncState.Update(processed, len(files), filepath.Base(file))
+
+		messages, _, err := mboxParser.ParseMboxFile(file)
+		if err != nil {
+			slog.Warn("Error parsing mbox file", "file", file, "error", err)
+			continue
+		}
+		if len(messages) == 0 {
+			continue
+		}
+		n := storeMessagesInDB(db, cfg, messages)
+		totalStored += n
+		GlobalSyncState.SetLatestMessageDate(messages[len(messages)-1].CreatedAt)
+		slog.Info("Stored messages from file", "file", file, "stored", n)
+	}
+	completed = true
+
+	GlobalSyncState.Update(len(files), len(files), "")
+	slog.Info("Directory ingest completed", "dir", dir, "stored", totalStored, "duration", time.Since(start))
+}
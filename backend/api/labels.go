@@ -0,0 +1,210 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// maxLabelLength matches thread_labels.label
+const maxLabelLength = 100
+
+// threadLabels returns a thread's labels in alphabetical order
+func threadLabels(db *sql.DB, threadID string) ([]string, error) {
+	labels := make([]string, 0)
+	err := db.QueryRow(`
+		SELECT COALESCE(ARRAY_AGG(label ORDER BY label), '{}') FROM thread_labels WHERE thread_id = $1
+	`, threadID).Scan(pq.Array(&labels))
+	return labels, err
+}
+
+// addThreadLabelsHandler attaches one or more labels to a thread. The body is
+// {"labels": ["needs-docs", "performance"]}; labels already present are kept.
+// Responds with the thread's full label list.
+func addThreadLabelsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		threadID := mux.Vars(r)["id"]
+
+		var req struct {
+			Labels []string `json:"labels"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON body"})
+			return
+		}
+
+		labels := make([]string, 0, len(req.Labels))
+		for _, label := range req.Labels {
+			label = strings.TrimSpace(label)
+			if label == "" || len(label) > maxLabelLength {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "Labels must be 1-100 characters"})
+				return
+			}
+			labels = append(labels, label)
+		}
+		if len(labels) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "No labels given"})
+			return
+		}
+
+		exists, err := threadExists(db, threadID)
+		if err != nil {
+			log.Printf("Error querying thread: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to add labels"})
+			return
+		}
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Thread not found"})
+			return
+		}
+
+		if _, err := db.Exec(`
+			INSERT INTO thread_labels (thread_id, label)
+			SELECT $1, UNNEST($2::text[])
+			ON CONFLICT (thread_id, label) DO NOTHING
+		`, threadID, pq.Array(labels)); err != nil {
+			log.Printf("Error adding thread labels: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to add labels"})
+			return
+		}
+
+		current, err := threadLabels(db, threadID)
+		if err != nil {
+			log.Printf("Error querying thread labels: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch labels"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"thread_id": threadID, "labels": current})
+	}
+}
+
+// removeThreadLabelHandler detaches a label from a thread. Removing a label the
+// thread doesn't have is not an error. Responds with the remaining labels.
+func removeThreadLabelHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		vars := mux.Vars(r)
+		threadID := vars["id"]
+
+		exists, err := threadExists(db, threadID)
+		if err != nil {
+			log.Printf("Error querying thread: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to remove label"})
+			return
+		}
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Thread not found"})
+			return
+		}
+
+		if _, err := db.Exec(`DELETE FROM thread_labels WHERE thread_id = $1 AND label = $2`, threadID, vars["label"]); err != nil {
+			log.Printf("Error removing thread label: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to remove label"})
+			return
+		}
+
+		current, err := threadLabels(db, threadID)
+		if err != nil {
+			log.Printf("Error querying thread labels: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch labels"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"thread_id": threadID, "labels": current})
+	}
+}
+
+// maxBulkLabelThreads bounds the thread_ids accepted by one bulk label request
+const maxBulkLabelThreads = 1000
+
+// bulkLabelHandler adds (POST) or removes (DELETE) a label on many threads at
+// once. The body is {"thread_ids": ["<id>", ...]}. Responds with the threads
+// that were updated and any ids that don't match a thread.
+func bulkLabelHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		label := strings.TrimSpace(mux.Vars(r)["label"])
+		if label == "" || len(label) > maxLabelLength {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Labels must be 1-100 characters"})
+			return
+		}
+
+		var req struct {
+			ThreadIDs []string `json:"thread_ids"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON body"})
+			return
+		}
+		if len(req.ThreadIDs) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "No thread_ids given"})
+			return
+		}
+		if len(req.ThreadIDs) > maxBulkLabelThreads {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "At most 1000 thread_ids per request"})
+			return
+		}
+
+		var query string
+		if r.Method == http.MethodDelete {
+			query = `
+				WITH targets AS (SELECT id FROM threads WHERE id = ANY($1::text[])),
+				removed AS (DELETE FROM thread_labels WHERE label = $2 AND thread_id IN (SELECT id FROM targets))
+				SELECT COALESCE(ARRAY_AGG(id ORDER BY id), '{}') FROM targets`
+		} else {
+			query = `
+				WITH targets AS (SELECT id FROM threads WHERE id = ANY($1::text[])),
+				added AS (
+					INSERT INTO thread_labels (thread_id, label)
+					SELECT id, $2 FROM targets
+					ON CONFLICT (thread_id, label) DO NOTHING
+				)
+				SELECT COALESCE(ARRAY_AGG(id ORDER BY id), '{}') FROM targets`
+		}
+
+		updated := make([]string, 0)
+		if err := db.QueryRow(query, pq.Array(req.ThreadIDs), label).Scan(pq.Array(&updated)); err != nil {
+			log.Printf("Error applying bulk label: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update labels"})
+			return
+		}
+
+		found := make(map[string]bool, len(updated))
+		for _, id := range updated {
+			found[id] = true
+		}
+		notFound := make([]string, 0)
+		for _, id := range req.ThreadIDs {
+			if !found[id] {
+				notFound = append(notFound, id)
+				found[id] = true
+			}
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{"label": label, "thread_ids": updated, "not_found": notFound})
+	}
+}
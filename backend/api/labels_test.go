@@ -0,0 +1,101 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pgsql-analyzer/backend/config"
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+func TestBulkLabel(t *testing.T) {
+	database := testDB(t)
+	cfg := config.LoadConfig()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	storeMessagesInDB(database, cfg, []*models.Message{
+		{MessageID: "a@example.com", Subject: "Faster sorting", Author: "Alice", AuthorEmail: "alice@example.com", CreatedAt: now.Add(-2 * time.Hour)},
+		{MessageID: "b@example.com", Subject: "Smaller indexes", Author: "Bob", AuthorEmail: "bob@example.com", CreatedAt: now.Add(-time.Hour)},
+	})
+	var a, b string
+	if err := database.QueryRow("SELECT thread_id FROM messages WHERE message_id = 'a@example.com'").Scan(&a); err != nil {
+		t.Fatalf("query thread: %v", err)
+	}
+	if err := database.QueryRow("SELECT thread_id FROM messages WHERE message_id = 'b@example.com'").Scan(&b); err != nil {
+		t.Fatalf("query thread: %v", err)
+	}
+
+	bulk := func(method string, ids ...string) (updated, notFound []string) {
+		body, _ := json.Marshal(map[string][]string{"thread_ids": ids})
+		req := mux.SetURLVars(httptest.NewRequest(method, "/api/labels/my-queue", strings.NewReader(string(body))), map[string]string{"label": "my-queue"})
+		rec := httptest.NewRecorder()
+		bulkLabelHandler(database)(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s status %d: %s", method, rec.Code, rec.Body)
+		}
+		var resp struct {
+			ThreadIDs []string `json:"thread_ids"`
+			NotFound  []string `json:"not_found"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return resp.ThreadIDs, resp.NotFound
+	}
+	labels := func(id string) []string {
+		l, err := threadLabels(database, id)
+		if err != nil {
+			t.Fatalf("threadLabels: %v", err)
+		}
+		return l
+	}
+
+	// Applying twice is harmless
+	bulk("POST", a)
+	_, notFound := bulk("POST", a, b, "missing")
+	if !reflect.DeepEqual(notFound, []string{"missing"}) {
+		t.Errorf("not_found = %v, want [missing]", notFound)
+	}
+	for _, id := range []string{a, b} {
+		if got := labels(id); !reflect.DeepEqual(got, []string{"my-queue"}) {
+			t.Errorf("labels(%s) = %v after bulk add, want [my-queue]", id, got)
+		}
+	}
+
+	if updated, _ := bulk("DELETE", b); !reflect.DeepEqual(updated, []string{b}) {
+		t.Errorf("thread_ids = %v after bulk remove, want [%s]", updated, b)
+	}
+	if got := labels(a); !reflect.DeepEqual(got, []string{"my-queue"}) {
+		t.Errorf("labels(a) = %v, want [my-queue]", got)
+	}
+	if got := labels(b); len(got) != 0 {
+		t.Errorf("labels(b) = %v after bulk remove, want none", got)
+	}
+}
+
+func TestBulkLabelRejectsEmptyRequest(t *testing.T) {
+	cases := []struct {
+		label string
+		body  string
+	}{
+		{"my-queue", `{"thread_ids": []}`},
+		{"my-queue", `not json`},
+		{" ", `{"thread_ids": ["x"]}`},
+		{strings.Repeat("x", maxLabelLength+1), `{"thread_ids": ["x"]}`},
+		{"my-queue", `{"thread_ids": [` + strings.TrimSuffix(strings.Repeat(`"x",`, maxBulkLabelThreads+1), ",") + `]}`},
+	}
+	for _, c := range cases {
+		req := mux.SetURLVars(httptest.NewRequest("POST", "/api/labels/x", strings.NewReader(c.body)), map[string]string{"label": c.label})
+		rec := httptest.NewRecorder()
+		bulkLabelHandler(nil)(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("label %.10q body %.20q: status %d, want 400", c.label, c.body, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// listStat counts the messages and threads seen on one mailing list
+type listStat struct {
+	List     string `json:"list"`
+	Messages int    `json:"messages"`
+	Threads  int    `json:"threads"`
+}
+
+// getListStatsHandler counts messages per mailing list. A message cross-posted
+// to several lists is stored once, so it counts once in total_messages but is
+// attributed to every list it was seen on; cross_posted counts those messages.
+// Messages with no known list are counted as untagged.
+func getListStatsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		rows, err := db.Query(`
+			SELECT list, COUNT(*), COUNT(DISTINCT m.thread_id)
+			FROM messages m, unnest(m.lists) AS list
+			GROUP BY list
+			ORDER BY COUNT(*) DESC, list ASC
+		`)
+		if err != nil {
+			log.Printf("Error querying list stats: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch list stats"})
+			return
+		}
+		defer rows.Close()
+
+		lists := make([]listStat, 0)
+		for rows.Next() {
+			var s listStat
+			if err := rows.Scan(&s.List, &s.Messages, &s.Threads); err != nil {
+				log.Printf("Error scanning list stats: %v", err)
+				continue
+			}
+			lists = append(lists, s)
+		}
+
+		var total, crossPosted, untagged int
+		if err := db.QueryRow(`
+			SELECT COUNT(*),
+			       COUNT(*) FILTER (WHERE cardinality(lists) > 1),
+			       COUNT(*) FILTER (WHERE COALESCE(cardinality(lists), 0) = 0)
+			FROM messages
+		`).Scan(&total, &crossPosted, &untagged); err != nil {
+			log.Printf("Error counting messages by list: %v", err)
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"lists":          lists,
+			"total_messages": total,
+			"cross_posted":   crossPosted,
+			"untagged":       untagged,
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pgsql-analyzer/backend/config"
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+func TestCrossPostCountedOncePerList(t *testing.T) {
+	database := testDB(t)
+	cfg := config.LoadConfig()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	crossPost := func(list string) *models.Message {
+		return &models.Message{MessageID: "crosspost@example.com", Subject: "Upgrade question", Author: "Alice",
+			AuthorEmail: "alice@example.com", Body: "Asked on both lists.", CreatedAt: now.Add(-time.Hour), Lists: []string{list}}
+	}
+	// The same message arrives once from each list's archive
+	storeMessagesInDB(database, cfg, []*models.Message{
+		crossPost("pgsql-hackers"),
+		{MessageID: "hackers-only@example.com", Subject: "Planner idea", Author: "Bob", AuthorEmail: "bob@example.com",
+			Body: "Only on hackers.", CreatedAt: now, Lists: []string{"pgsql-hackers"}},
+	})
+	storeMessagesInDB(database, cfg, []*models.Message{crossPost("pgsql-general")})
+	// Seeing it again on a list it's already tagged with changes nothing
+	storeMessagesInDB(database, cfg, []*models.Message{crossPost("pgsql-hackers")})
+
+	var lists []string
+	if err := database.QueryRow("SELECT lists FROM messages WHERE message_id = 'crosspost@example.com'").Scan(pq.Array(&lists)); err != nil {
+		t.Fatalf("query lists: %v", err)
+	}
+	if len(lists) != 2 || lists[0] != "pgsql-general" || lists[1] != "pgsql-hackers" {
+		t.Errorf("cross-post lists = %v, want [pgsql-general pgsql-hackers]", lists)
+	}
+
+	rec := httptest.NewRecorder()
+	getListStatsHandler(database)(rec, httptest.NewRequest("GET", "/api/stats/by-list", nil))
+	var stats struct {
+		Lists         []listStat `json:"lists"`
+		TotalMessages int        `json:"total_messages"`
+		CrossPosted   int        `json:"cross_posted"`
+		Untagged      int        `json:"untagged"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatalf("decode stats (status %d): %v", rec.Code, err)
+	}
+	if stats.TotalMessages != 2 || stats.CrossPosted != 1 || stats.Untagged != 0 {
+		t.Errorf("total %d, cross-posted %d, untagged %d; want 2, 1, 0", stats.TotalMessages, stats.CrossPosted, stats.Untagged)
+	}
+	want := []listStat{{"pgsql-hackers", 2, 2}, {"pgsql-general", 1, 1}}
+	if len(stats.Lists) != len(want) {
+		t.Fatalf("lists = %+v, want %+v", stats.Lists, want)
+	}
+	for i := range want {
+		if stats.Lists[i] != want[i] {
+			t.Errorf("lists = %+v, want %+v", stats.Lists, want)
+			break
+		}
+	}
+}
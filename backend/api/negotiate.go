@@ -0,0 +1,47 @@
+package api
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+// prefersPlainText reports whether the Accept header ranks text/plain above
+// application/json. A missing header or */* keeps the JSON default.
+func prefersPlainText(r *http.Request) bool {
+	var textQ, jsonQ float64
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		switch mediaType {
+		case "text/plain":
+			textQ = q
+		case "application/json":
+			jsonQ = q
+		}
+	}
+	return textQ > 0 && textQ > jsonQ
+}
+
+// writeMessageText writes msg as a short header block followed by its body
+func writeMessageText(w http.ResponseWriter, msg *models.Message) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "From: %s <%s>\n", msg.Author, msg.AuthorEmail)
+	fmt.Fprintf(w, "Date: %s\n", msg.CreatedAt.Format(time.RFC1123Z))
+	fmt.Fprintf(w, "Subject: %s\n", msg.Subject)
+	fmt.Fprintf(w, "Message-ID: <%s>\n", msg.MessageID)
+	fmt.Fprintf(w, "\n%s\n", msg.Body)
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pgsql-analyzer/backend/config"
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+func TestEqualTimestampsOrderedByMessageID(t *testing.T) {
+	database := testDB(t)
+	cfg := config.LoadConfig()
+
+	// Replies to a root we don't have, all sent in the same second and
+	// stored in reverse message-id order
+	at := time.Date(2024, 2, 2, 12, 0, 0, 0, time.UTC)
+	var msgs []*models.Message
+	for _, who := range []string{"zoe", "mia", "ann"} {
+		msgs = append(msgs, &models.Message{
+			MessageID: who + "@example.com", InReplyTo: "missing@example.com", RefersTo: "<missing@example.com>",
+			Subject: "Re: same second", Author: who, AuthorEmail: who + "@example.com", Body: "hi", CreatedAt: at,
+		})
+	}
+	storeMessagesInDB(database, cfg, msgs)
+
+	var threadID, firstAuthor string
+	if err := database.QueryRow("SELECT id, first_author FROM threads").Scan(&threadID, &firstAuthor); err != nil {
+		t.Fatalf("query thread: %v", err)
+	}
+	if firstAuthor != "ann" {
+		t.Errorf("first_author = %q, want ann (lowest message-id)", firstAuthor)
+	}
+
+	rec := httptest.NewRecorder()
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/api/threads/"+threadID+"/messages", nil), map[string]string{"id": threadID})
+	getThreadMessagesHandler(database)(rec, req)
+	var got []*models.Message
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode messages: %v", err)
+	}
+	want := []string{"ann@example.com", "mia@example.com", "zoe@example.com"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d messages, want %d", len(got), len(want))
+	}
+	for i, m := range got {
+		if m.MessageID != want[i] {
+			t.Errorf("message %d = %s, want %s", i, m.MessageID, want[i])
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/models"
+	"github.com/pgsql-analyzer/backend/parser"
+)
+
+// outlookIndex builds a Thread-Index for conversation seed, replies deep
+func outlookIndex(seed byte, replies int) string {
+	raw := append(bytes.Repeat([]byte{seed}, 22), bytes.Repeat([]byte{0x80}, 5*replies)...)
+	return base64.StdEncoding.EncodeToString(raw)
+}
+
+func TestOutlookRepliesThreadByThreadIndex(t *testing.T) {
+	at := time.Date(2024, 2, 2, 12, 0, 0, 0, time.UTC)
+	messages := []*models.Message{
+		{MessageID: "start@example.com", ThreadIndex: outlookIndex(1, 0), CreatedAt: at},
+		// Outlook replies with no References or In-Reply-To
+		{MessageID: "reply1@example.com", ThreadIndex: outlookIndex(1, 1), CreatedAt: at.Add(time.Hour)},
+		{MessageID: "reply2@example.com", ThreadIndex: outlookIndex(1, 2), CreatedAt: at.Add(2 * time.Hour)},
+		// References still win over the Thread-Index
+		{MessageID: "referenced@example.com", ThreadIndex: outlookIndex(1, 1), RefersTo: "<elsewhere@example.com>", CreatedAt: at.Add(3 * time.Hour)},
+		// A conversation whose start is neither in the batch nor stored: the
+		// shallowest reply present starts the thread
+		{MessageID: "orphan1@example.com", ThreadIndex: outlookIndex(2, 1), CreatedAt: at},
+		{MessageID: "orphan2@example.com", ThreadIndex: outlookIndex(2, 2), CreatedAt: at.Add(time.Hour)},
+		// No threading headers at all
+		{MessageID: "plain@example.com", CreatedAt: at},
+	}
+	resolveOutlookParents(messages, nil)
+
+	threads := groupByThread(messages, 100)
+	want := map[string]int{"start@example.com": 3, "elsewhere@example.com": 1, "orphan1@example.com": 2, "plain@example.com": 1}
+	if len(threads) != len(want) {
+		t.Fatalf("got %d threads, want %d: %v", len(threads), len(want), threads)
+	}
+	for root, count := range want {
+		if got := len(threads[root]); got != count {
+			t.Errorf("thread %s has %d messages, want %d", root, got, count)
+		}
+	}
+}
+
+func TestOutlookReplyJoinsStoredConversation(t *testing.T) {
+	conv, _ := parser.ThreadConversation(outlookIndex(3, 0))
+	messages := []*models.Message{
+		{MessageID: "later@example.com", ThreadIndex: outlookIndex(3, 1), CreatedAt: time.Now()},
+	}
+	resolveOutlookParents(messages, map[string]string{conv: "earlier@example.com"})
+
+	if got := messages[0].OutlookParent; got != "earlier@example.com" {
+		t.Errorf("OutlookParent = %q, want the stored start of the conversation", got)
+	}
+	if got := firstReference(messages[0]); got != "earlier@example.com" {
+		t.Errorf("firstReference = %q, want the Thread-Index parent", got)
+	}
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/pgsql-analyzer/backend/config"
+)
+
+// pagination resolves the limit/offset query params against the configured page
+// sizes. Missing or invalid values fall back to the defaults; limits above
+// MaxPageSize are clamped to it.
+func pagination(r *http.Request, cfg *config.Config) (limit, offset int) {
+	limit = cfg.DefaultPageSize
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 {
+		limit = v
+	}
+	if cfg.MaxPageSize > 0 && limit > cfg.MaxPageSize {
+		limit = cfg.MaxPageSize
+	}
+	if v, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && v > 0 {
+		offset = v
+	}
+	return limit, offset
+}
+
+// setPageHeaders reports the effective pagination for endpoints whose body is a
+// bare array. The headers are the documented contract; bodies stay arrays for
+// compatibility with existing clients.
+func setPageHeaders(w http.ResponseWriter, limit, offset int) {
+	w.Header().Set("X-Page-Limit", strconv.Itoa(limit))
+	w.Header().Set("X-Page-Offset", strconv.Itoa(offset))
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/config"
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+func TestPatchHistorySkipsRepeatedStatus(t *testing.T) {
+	database := testDB(t)
+	cfg := config.LoadConfig()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	storeMessagesInDB(database, cfg, []*models.Message{
+		{MessageID: "v1@example.com", Subject: "[PATCH] tweak", Author: "Alice", AuthorEmail: "alice@example.com",
+			Body: "diff --git a/x.c b/x.c\n", HasPatch: true, PatchStatus: "proposed", CreatedAt: now.Add(-3 * time.Hour)},
+		// A second version is still just proposed
+		{MessageID: "v2@example.com", InReplyTo: "v1@example.com", RefersTo: "<v1@example.com>",
+			Subject: "Re: [PATCH] tweak", Author: "Alice", AuthorEmail: "alice@example.com",
+			Body: "v2\n\ndiff --git a/x.c b/x.c\n", HasPatch: true, PatchStatus: "proposed", CreatedAt: now.Add(-2 * time.Hour)},
+		{MessageID: "lgtm@example.com", InReplyTo: "v2@example.com", RefersTo: "<v1@example.com> <v2@example.com>",
+			Subject: "Re: [PATCH] tweak", Author: "Bob", AuthorEmail: "bob@example.com",
+			Body: "Looks good, marking ready for committer.", PatchStatus: "accepted", CreatedAt: now.Add(-time.Hour)},
+	})
+
+	var threadID string
+	if err := database.QueryRow("SELECT id FROM threads").Scan(&threadID); err != nil {
+		t.Fatalf("query thread: %v", err)
+	}
+	history := func() [][3]string {
+		t.Helper()
+		rows, err := database.Query(`
+			SELECT message_id, from_status, to_status FROM patch_status_history
+			WHERE thread_id = $1 ORDER BY changed_at, message_id
+		`, threadID)
+		if err != nil {
+			t.Fatalf("query history: %v", err)
+		}
+		defer rows.Close()
+		var entries [][3]string
+		for rows.Next() {
+			var e [3]string
+			if err := rows.Scan(&e[0], &e[1], &e[2]); err != nil {
+				t.Fatalf("scan history: %v", err)
+			}
+			entries = append(entries, e)
+		}
+		return entries
+	}
+
+	// The patch is proposed once, then moves to accepted exactly once
+	want := [][3]string{
+		{"v1@example.com", "", "proposed"},
+		{"lgtm@example.com", "proposed", "accepted"},
+	}
+	check := func(when string) {
+		t.Helper()
+		got := history()
+		if len(got) != len(want) {
+			t.Fatalf("%s: history %v, want %v", when, got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%s: history %v, want %v", when, got, want)
+				break
+			}
+		}
+	}
+	check("after ingest")
+
+	ta := newThreadAnalyzer(database, cfg)
+	refreshThreads(database, ta, []string{threadID})
+	refreshThreads(database, ta, []string{threadID})
+	check("after refreshing twice")
+}
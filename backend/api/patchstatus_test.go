@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pgsql-analyzer/backend/config"
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+func TestCurrentPatchStatusFollowsLatestMessage(t *testing.T) {
+	database := testDB(t)
+	cfg := config.LoadConfig()
+
+	currentStatus := func(threadID string) string {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/api/threads/"+threadID, nil), map[string]string{"id": threadID})
+		getThreadHandler(database, cfg)(rec, req)
+		var thread models.Thread
+		if err := json.NewDecoder(rec.Body).Decode(&thread); err != nil {
+			t.Fatalf("decode thread (status %d): %v", rec.Code, err)
+		}
+		return thread.CurrentPatchStatus
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	storeMessagesInDB(database, cfg, []*models.Message{
+		{MessageID: "v1@example.com", Subject: "[PATCH] tweak", Author: "Alice", AuthorEmail: "alice@example.com",
+			Body: "diff --git a/x.c b/x.c\n", HasPatch: true, PatchStatus: "proposed", CreatedAt: now.Add(-3 * time.Hour)},
+		{MessageID: "question@example.com", InReplyTo: "v1@example.com", RefersTo: "<v1@example.com>",
+			Subject: "Re: [PATCH] tweak", Author: "Bob", AuthorEmail: "bob@example.com",
+			Body: "Why not do it in the planner?", CreatedAt: now.Add(-2 * time.Hour)},
+	})
+
+	var threadID string
+	if err := database.QueryRow("SELECT id FROM threads").Scan(&threadID); err != nil {
+		t.Fatalf("query thread: %v", err)
+	}
+	// A later message without a status doesn't reset it
+	if got := currentStatus(threadID); got != "proposed" {
+		t.Errorf("current_patch_status = %q, want proposed", got)
+	}
+
+	storeMessagesInDB(database, cfg, []*models.Message{
+		{MessageID: "pushed@example.com", InReplyTo: "question@example.com", RefersTo: "<v1@example.com> <question@example.com>",
+			Subject: "Re: [PATCH] tweak", Author: "Carol", AuthorEmail: "carol@example.com",
+			Body: "Pushed, thanks.", PatchStatus: "committed", CreatedAt: now.Add(-time.Hour)},
+	})
+	if got := currentStatus(threadID); got != "committed" {
+		t.Errorf("current_patch_status = %q after the commit, want committed", got)
+	}
+
+	// UpdateThreadActivity recomputes it from the messages alone
+	if _, err := database.Exec("UPDATE threads SET current_patch_status = '' WHERE id = $1", threadID); err != nil {
+		t.Fatalf("reset status: %v", err)
+	}
+	if err := newThreadAnalyzer(database, cfg).UpdateThreadActivity(threadID); err != nil {
+		t.Fatalf("UpdateThreadActivity: %v", err)
+	}
+	if got := currentStatus(threadID); got != "committed" {
+		t.Errorf("current_patch_status = %q after UpdateThreadActivity, want committed", got)
+	}
+}
@@ -0,0 +1,188 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/config"
+	"github.com/pgsql-analyzer/backend/parser"
+)
+
+// reanalyzeBatchSize is how many stored messages are read per query
+const reanalyzeBatchSize = 1000
+
+// patchReanalysis reports the progress of the current or last patch reanalysis
+type patchReanalysis struct {
+	Running    bool       `json:"running"`
+	Processed  int        `json:"processed"`
+	Total      int        `json:"total"`
+	Changed    int        `json:"changed"` // messages whose has_patch or patch_status changed
+	Threads    int        `json:"threads"` // threads refreshed because of those changes
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// patchReanalysisState guards the progress shared with GET /api/reanalyze-patches
+var patchReanalysisState struct {
+	mu       sync.RWMutex
+	progress patchReanalysis
+}
+
+func updatePatchReanalysis(update func(p *patchReanalysis)) {
+	patchReanalysisState.mu.Lock()
+	defer patchReanalysisState.mu.Unlock()
+	update(&patchReanalysisState.progress)
+}
+
+// getPatchReanalysisHandler returns the progress of the current or last run
+func getPatchReanalysisHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	patchReanalysisState.mu.RLock()
+	progress := patchReanalysisState.progress
+	patchReanalysisState.mu.RUnlock()
+	json.NewEncoder(w).Encode(progress)
+}
+
+// reanalyzePatchesHandler starts re-running patch detection over stored
+// message bodies in the background. It holds the sync slot so ingest can't
+// write the same flags meanwhile; progress is at GET /api/reanalyze-patches.
+func reanalyzePatchesHandler(db *sql.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		ctx, ok := GlobalSyncState.TryStartSync()
+		if !ok {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{"error": "A sync or reanalysis is already in progress"})
+			return
+		}
+
+		now := time.Now()
+		updatePatchReanalysis(func(p *patchReanalysis) {
+			*p = patchReanalysis{Running: true, StartedAt: &now}
+		})
+		go func() {
+			defer GlobalSyncState.SetSyncing(false)
+			reanalyzePatches(ctx, db, cfg)
+		}()
+
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{
+			"status":    "Patch reanalysis started",
+			"timestamp": now.Format(time.RFC3339),
+		})
+	}
+}
+
+// reanalyzePatches recomputes has_patch, patch_status and commitfest_id for
+// every stored message the way ingest does, then refreshes the threads whose
+// patch flags changed. It also backfills commitfest_id for messages stored
+// before ingest detected it. As at ingest, header-only messages are never
+// patches and get no CommitFest id. A cancelled ctx stops it after the
+// current batch; threads changed so far are refreshed.
+func reanalyzePatches(ctx context.Context, db *sql.DB, cfg *config.Config) {
+	start := time.Now()
+	defer func() {
+		finished := time.Now()
+		updatePatchReanalysis(func(p *patchReanalysis) {
+			p.Running = false
+			p.FinishedAt = &finished
+		})
+	}()
+
+	var total int
+	if err := db.QueryRow("SELECT COUNT(*) FROM messages").Scan(&total); err != nil {
+		slog.Error("Error counting messages for patch reanalysis", "error", err)
+		return
+	}
+	updatePatchReanalysis(func(p *patchReanalysis) { p.Total = total })
+
+	type stored struct {
+		id, threadID, subject, body string
+		emptyBody, hasPatch         bool
+		patchStatus, commitFestID   string
+	}
+
+	touched := make(map[string]bool)
+	lastID := ""
+	for ctx.Err() == nil {
+		rows, err := db.Query(`
+			SELECT id, thread_id, COALESCE(subject, ''), COALESCE(body, ''), empty_body, has_patch, COALESCE(patch_status, ''),
+			       COALESCE(commitfest_id, '')
+			FROM messages WHERE id > $1 ORDER BY id LIMIT $2
+		`, lastID, reanalyzeBatchSize)
+		if err != nil {
+			slog.Error("Error reading messages for patch reanalysis", "error", err)
+			return
+		}
+		var batch []stored
+		for rows.Next() {
+			var m stored
+			if err := rows.Scan(&m.id, &m.threadID, &m.subject, &m.body, &m.emptyBody, &m.hasPatch, &m.patchStatus, &m.commitFestID); err != nil {
+				slog.Warn("Error scanning message for patch reanalysis", "error", err)
+				continue
+			}
+			batch = append(batch, m)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			slog.Error("Error reading messages for patch reanalysis", "error", err)
+			return
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		changed := 0
+		for _, m := range batch {
+			hasPatch, status, commitFestID := false, "", ""
+			if !m.emptyBody {
+				hasPatch = parser.DetectPatch(m.body, m.subject)
+				if hasPatch {
+					status = parser.DetectPatchStatus(m.body, m.subject)
+				}
+				commitFestID = parser.DetectCommitFestID(m.body, m.subject)
+			}
+			patchChanged := hasPatch != m.hasPatch || status != m.patchStatus
+			if !patchChanged && commitFestID == m.commitFestID {
+				continue
+			}
+			if _, err := db.Exec("UPDATE messages SET has_patch = $1, patch_status = $2, commitfest_id = $3 WHERE id = $4", hasPatch, status, commitFestID, m.id); err != nil {
+				slog.Warn("Error updating message patch flags", "id", m.id, "error", err)
+				continue
+			}
+			changed++
+			if patchChanged {
+				touched[m.threadID] = true
+			}
+		}
+
+		lastID = batch[len(batch)-1].id
+		updatePatchReanalysis(func(p *patchReanalysis) {
+			p.Processed += len(batch)
+			p.Changed += changed
+		})
+	}
+
+	if len(touched) > 0 {
+		threadAnalyzer := newThreadAnalyzer(db, cfg)
+		ids := make([]string, 0, len(touched))
+		for id := range touched {
+			if err := threadAnalyzer.UpdateThreadActivity(id); err != nil {
+				slog.Warn("Error updating thread activity", "thread_id", id, "error", err)
+			}
+			refreshPatchState(db, threadAnalyzer, id)
+			ids = append(ids, id)
+		}
+		refreshThreads(db, threadAnalyzer, ids)
+	}
+	updatePatchReanalysis(func(p *patchReanalysis) { p.Threads = len(touched) })
+
+	slog.Info("Patch reanalysis completed", "messages", total, "threads", len(touched), "duration", time.Since(start))
+}
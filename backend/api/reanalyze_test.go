@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/config"
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+func TestReanalyzePatchesBackfillsCommitFestID(t *testing.T) {
+	database := testDB(t)
+	cfg := config.LoadConfig()
+
+	// Stored before ingest detected CommitFest ids
+	msg := &models.Message{
+		MessageID: "cf@example.com", Subject: "Re: [PATCH] faster sorting", Author: "Alice", AuthorEmail: "alice@example.com",
+		Body: "Registered as https://commitfest.postgresql.org/patch/4567/", CreatedAt: time.Now().Add(-time.Hour),
+	}
+	storeMessagesInDB(database, cfg, []*models.Message{msg})
+	if _, err := database.Exec("UPDATE messages SET commitfest_id = ''"); err != nil {
+		t.Fatalf("clear commitfest_id: %v", err)
+	}
+
+	reanalyzePatches(context.Background(), database, cfg)
+
+	var id string
+	if err := database.QueryRow("SELECT commitfest_id FROM messages WHERE message_id = $1", msg.MessageID).Scan(&id); err != nil {
+		t.Fatalf("query message: %v", err)
+	}
+	if id != "4567" {
+		t.Errorf("commitfest_id = %q after reanalysis, want 4567", id)
+	}
+}
+
+func TestReanalyzePatchesUpdatesFlags(t *testing.T) {
+	database := testDB(t)
+	cfg := config.LoadConfig()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	storeMessagesInDB(database, cfg, []*models.Message{
+		{MessageID: "v1@example.com", Subject: "Faster sorting", Author: "Alice", AuthorEmail: "alice@example.com",
+			Body: "diff --git a/sort.c b/sort.c\n--- a/sort.c\n+++ b/sort.c\n", CreatedAt: now.Add(-2 * time.Hour)},
+		{MessageID: "reply@example.com", InReplyTo: "v1@example.com", RefersTo: "<v1@example.com>",
+			Subject: "Re: Faster sorting", Author: "Bob", AuthorEmail: "bob@example.com",
+			Body: "Nice, will review.", CreatedAt: now.Add(-time.Hour)},
+	})
+	// As if the patch had been stored before detection recognized it
+	if _, err := database.Exec("UPDATE messages SET has_patch = false, patch_status = ''"); err != nil {
+		t.Fatalf("clear flags: %v", err)
+	}
+	if _, err := database.Exec("UPDATE threads SET patch_count = 0, status = 'discussion'"); err != nil {
+		t.Fatalf("clear thread aggregates: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	reanalyzePatchesHandler(database, cfg)(rec, httptest.NewRequest("POST", "/api/reanalyze-patches", nil))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+
+	var progress patchReanalysis
+	for deadline := time.Now().Add(30 * time.Second); ; {
+		rec := httptest.NewRecorder()
+		getPatchReanalysisHandler(rec, httptest.NewRequest("GET", "/api/reanalyze-patches", nil))
+		if err := json.NewDecoder(rec.Body).Decode(&progress); err != nil {
+			t.Fatalf("decode progress: %v", err)
+		}
+		if !progress.Running && progress.FinishedAt != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("reanalysis still running: %+v", progress)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if progress.Total != 2 || progress.Processed != 2 || progress.Changed != 1 || progress.Threads != 1 {
+		t.Errorf("progress = %+v, want 2 processed, 1 changed, 1 thread", progress)
+	}
+
+	var hasPatch bool
+	var patchStatus string
+	if err := database.QueryRow("SELECT has_patch, patch_status FROM messages WHERE message_id = 'v1@example.com'").Scan(&hasPatch, &patchStatus); err != nil {
+		t.Fatalf("query message: %v", err)
+	}
+	if !hasPatch || patchStatus == "" {
+		t.Errorf("has_patch %v, patch_status %q after reanalysis; want the patch detected", hasPatch, patchStatus)
+	}
+	var patchCount int
+	var status string
+	if err := database.QueryRow("SELECT patch_count, status FROM threads").Scan(&patchCount, &status); err != nil {
+		t.Fatalf("query thread: %v", err)
+	}
+	if patchCount != 1 || status == "discussion" {
+		t.Errorf("thread patch_count %d, status %q; want the aggregates refreshed", patchCount, status)
+	}
+}
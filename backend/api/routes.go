@@ -1,18 +1,25 @@
 package api
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
+	"sort"
 	"strings"
+	"sync"
 	"time"
-	"unicode/utf8"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/lib/pq"
 	"github.com/pgsql-analyzer/backend/analyzer"
 	"github.com/pgsql-analyzer/backend/config"
 	"github.com/pgsql-analyzer/backend/fetcher"
@@ -25,23 +32,73 @@ func RegisterRoutes(router *mux.Router, db *sql.DB, cfg *config.Config) {
 	router.HandleFunc("/api/health", healthHandler).Methods("GET")
 
 	// Thread endpoints
-	router.HandleFunc("/api/threads", getThreadsHandler(db)).Methods("GET")
-	router.HandleFunc("/api/threads/{id}", getThreadHandler(db)).Methods("GET")
+	router.HandleFunc("/api/threads", withRequestTimeout(cfg, getThreadsHandler(db, cfg))).Methods("GET")
+	router.HandleFunc("/api/threads.csv", withExportTimeout(cfg, exportThreadsCSVHandler(db, cfg))).Methods("GET")
+	router.HandleFunc("/api/threads/search", withRequestTimeout(cfg, searchThreadsHandler(db, cfg))).Methods("POST")
+	router.HandleFunc("/api/threads/by-message", getThreadByMessageHandler(db, cfg)).Methods("GET")
+	router.HandleFunc("/api/threads/{id}", getThreadHandler(db, cfg)).Methods("GET")
 	router.HandleFunc("/api/threads/{id}/messages", getThreadMessagesHandler(db)).Methods("GET")
+	router.HandleFunc("/api/threads/{id}/timeline", getThreadTimelineHandler(db)).Methods("GET")
+	router.HandleFunc("/api/threads/{id}/authors-over-time", getThreadAuthorsOverTimeHandler(db)).Methods("GET")
+	router.HandleFunc("/api/threads/{id}/patch-history", getPatchHistoryHandler(db)).Methods("GET")
+	router.HandleFunc("/api/threads/{id}/reviewers", getThreadReviewersHandler(db, cfg)).Methods("GET")
+	router.HandleFunc("/api/threads/{id}/labels", requireAdmin(cfg, addThreadLabelsHandler(db))).Methods("POST")
+	router.HandleFunc("/api/threads/{id}/labels/{label}", requireAdmin(cfg, removeThreadLabelHandler(db))).Methods("DELETE")
+	router.HandleFunc("/api/labels/{label}", requireAdmin(cfg, bulkLabelHandler(db))).Methods("POST", "DELETE")
+
+	// Personal view: threads waiting on a participant
+	router.HandleFunc("/api/inbox", getInboxHandler(db, cfg)).Methods("GET")
+	router.HandleFunc("/api/visits", getVisitHandler(db, cfg)).Methods("GET")
+	router.HandleFunc("/api/visits/seen", advanceVisitHandler(db, cfg)).Methods("POST")
 
 	// Message endpoints
+	router.HandleFunc("/api/messages/exists", messagesExistHandler(db)).Methods("POST")
 	router.HandleFunc("/api/messages/{id}", getMessageHandler(db)).Methods("GET")
+	router.HandleFunc("/api/messages/{id}/download", downloadMessageHandler(db)).Methods("GET")
 
 	// Stats endpoint
 	router.HandleFunc("/api/stats", getStatsHandler(db)).Methods("GET")
+	router.HandleFunc("/api/stats/largest-messages", getLargestMessagesHandler(db, cfg)).Methods("GET")
+	router.HandleFunc("/api/stats/decode-warnings", getDecodeWarningsHandler(db, cfg)).Methods("GET")
+	router.HandleFunc("/api/stats/authors", getAuthorStatsHandler(db, cfg)).Methods("GET")
+	router.HandleFunc("/api/authors", getAuthorsHandler(db, cfg)).Methods("GET")
+	router.HandleFunc("/api/stats/timezones", getTimezoneStatsHandler(db)).Methods("GET")
+	router.HandleFunc("/api/stats/thread-sizes", getThreadSizeStatsHandler(db)).Methods("GET")
+	router.HandleFunc("/api/stats/by-list", getListStatsHandler(db)).Methods("GET")
+	router.HandleFunc("/api/statuses", getStatusesHandler(db)).Methods("GET")
 
 	// Sync endpoints
 	router.HandleFunc("/api/sync/progress", getSyncProgressHandler).Methods("GET")
-	router.HandleFunc("/api/sync/mbox", uploadMboxHandler(db, cfg)).Methods("POST")
-	router.HandleFunc("/api/sync/mbox/all", syncMboxHandler(db, cfg)).Methods("POST")
+	router.HandleFunc("/api/sync/progress/stream", getSyncProgressStreamHandler).Methods("GET")
+	router.HandleFunc("/api/sync/history", getSyncHistoryHandler(db, cfg)).Methods("GET")
+	router.HandleFunc("/api/sync/mbox", requireAdmin(cfg, uploadMboxHandler(db, cfg))).Methods("POST")
+	router.HandleFunc("/api/sync/mbox/all", requireAdmin(cfg, syncMboxHandler(db, cfg))).Methods("POST")
+	router.HandleFunc("/api/sync/cancel", requireAdmin(cfg, cancelSyncHandler)).Methods("POST")
+	router.HandleFunc("/api/mbox/ingest-dir", requireAdmin(cfg, ingestDirHandler(db, cfg))).Methods("POST")
+	router.HandleFunc("/api/mbox/preview", previewMboxHandler(cfg)).Methods("POST")
+	router.HandleFunc("/api/analyze", analyzeHandler).Methods("POST")
+
+	// Admin: schema migration status and storage usage
+	router.HandleFunc("/api/admin/migrations", requireAdmin(cfg, getMigrationsHandler(db))).Methods("GET")
+	router.HandleFunc("/api/admin/stats", requireAdmin(cfg, getAdminStatsHandler(db, cfg))).Methods("GET")
+
+	// Debug: explain how a thread's messages were grouped
+	router.HandleFunc("/api/debug/threads/{id}/grouping", requireAdmin(cfg, threadGroupingHandler(db, cfg))).Methods("GET")
 
 	// Reset: clear all threads/messages so next sync re-downloads from scratch
-	router.HandleFunc("/api/reset", resetHandler(db)).Methods("POST")
+	router.HandleFunc("/api/reset", requireAdmin(cfg, resetHandler(db, cfg))).Methods("POST")
+
+	// Reclassify: refresh stats and status for every thread (ingest only refreshes touched threads)
+	router.HandleFunc("/api/reclassify", requireAdmin(cfg, reclassifyHandler(db, cfg))).Methods("POST")
+	router.HandleFunc("/api/threads/{id}/reclassify", requireAdmin(cfg, reclassifyThreadHandler(db, cfg))).Methods("POST")
+
+	// Re-run patch detection on stored bodies, e.g. after the detection rules change
+	router.HandleFunc("/api/reanalyze-patches", requireAdmin(cfg, reanalyzePatchesHandler(db, cfg))).Methods("POST")
+	router.HandleFunc("/api/reanalyze-patches", requireAdmin(cfg, getPatchReanalysisHandler)).Methods("GET")
+
+	// Unmatched paths get a JSON 404 instead of mux's plain-text default.
+	// CORS preflight is answered by corsMiddleware before reaching the router.
+	router.NotFoundHandler = http.HandlerFunc(notFoundHandler)
 }
 
 func healthHandler(w http.ResponseWriter, r *http.Request) {
@@ -49,9 +106,30 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
 
-func resetHandler(db *sql.DB) http.HandlerFunc {
+func notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	writeErrorCode(w, http.StatusNotFound, "not_found", fmt.Sprintf("No route for %s %s", r.Method, r.URL.Path))
+}
+
+// writeErrorCode writes a structured {"error":{"code","message"}} JSON body.
+// Used for router- and middleware-level errors that aren't tied to a handler.
+func writeErrorCode(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]string{
+			"code":    code,
+			"message": message,
+		},
+	})
+}
+
+// resetHandler clears threads and messages. Downloaded mbox files are kept by
+// default, so the next sync re-imports from them (in development) or downloads
+// again; ?purge_files=true also deletes them from DataDir for a clean slate.
+func resetHandler(db *sql.DB, cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
+		purgeFiles := r.URL.Query().Get("purge_files") == "true"
 		// Truncate in FK order: activities and messages reference threads
 		_, err := db.Exec(`
 			TRUNCATE thread_activities CASCADE;
@@ -65,63 +143,241 @@ func resetHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 		log.Println("Database reset: threads, messages, and thread_activities cleared")
-		json.NewEncoder(w).Encode(map[string]string{
-			"status":    "Database cleared. Run Sync mbox files to re-download and re-import.",
-			"timestamp": time.Now().Format(time.RFC3339),
+
+		filesRemoved := 0
+		if purgeFiles {
+			filesRemoved, err = parser.NewMboxParser(cfg.DataDir).PurgeMboxFiles()
+			if err != nil {
+				log.Printf("Error purging mbox files: %v", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(map[string]string{"error": "Database cleared but failed to purge mbox files"})
+				return
+			}
+			log.Printf("Database reset: removed %d mbox files from %s", filesRemoved, cfg.DataDir)
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":        "Database cleared. Run Sync mbox files to re-download and re-import.",
+			"files_removed": filesRemoved,
+			"timestamp":     time.Now().Format(time.RFC3339),
 		})
 	}
 }
 
-func getThreadsHandler(db *sql.DB) http.HandlerFunc {
+// threadColumns is the select list matching scanThread
+const threadColumns = `
+	id, subject, first_message_id, first_author, first_author_email,
+	created_at, updated_at, last_message_at, revived_at, message_count, unique_authors, patch_count,
+	kind, status, maturity, needs_author_action, ready_for_committer, commit_hash,
+	series_version, series_parts_present, series_parts_total, series_missing, heat, has_benchmarks, current_patch_status, consensus,
+	COALESCE(forked_from, ''), COALESCE((SELECT f.subject FROM threads f WHERE f.id = threads.forked_from), ''),
+	COALESCE((SELECT ARRAY_AGG(label ORDER BY label) FROM thread_labels l WHERE l.thread_id = threads.id), '{}')
+`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanThread scans a row selected with threadColumns
+func scanThread(row rowScanner) (*models.Thread, error) {
+	thread := &models.Thread{}
+	var lastMsgAt, revivedAt sql.NullTime
+	var series models.PatchSeries
+	if err := row.Scan(
+		&thread.ID, &thread.Subject, &thread.FirstMessageID, &thread.FirstAuthor,
+		&thread.FirstAuthorEmail, &thread.CreatedAt, &thread.UpdatedAt, &lastMsgAt, &revivedAt,
+		&thread.MessageCount, &thread.UniqueAuthors, &thread.PatchCount, &thread.Kind, &thread.Status,
+		&thread.Maturity, &thread.NeedsAuthorAction, &thread.ReadyForCommitter, &thread.CommitHash,
+		&series.Version, &series.Present, &series.Total, pq.Array(&series.Missing), &thread.Heat, &thread.HasBenchmarks, &thread.CurrentPatchStatus, &thread.Consensus,
+		&thread.ForkedFrom, &thread.ForkedFromSubject, pq.Array(&thread.Labels),
+	); err != nil {
+		return nil, err
+	}
+	if series.Total > 0 {
+		series.Complete = series.Present == series.Total
+		thread.PatchSeries = &series
+	}
+	if lastMsgAt.Valid {
+		thread.LastMessageAt = &lastMsgAt.Time
+	}
+	if revivedAt.Valid {
+		thread.RevivedAt = &revivedAt.Time
+	}
+	thread.CommitURL = analyzer.CommitURL(thread.CommitHash)
+	return thread, nil
+}
+
+// committerAttentionAfter is how long a ready-for-committer thread may sit idle
+// before it is flagged for committer attention
+func committerAttentionAfter(cfg *config.Config) time.Duration {
+	return time.Duration(cfg.CommitterAttentionDays) * 24 * time.Hour
+}
+
+// flagCommitterAttention sets thread.CommitterAttention as of now
+func flagCommitterAttention(thread *models.Thread, cfg *config.Config) {
+	thread.CommitterAttention = analyzer.NeedsCommitterAttention(
+		thread.ReadyForCommitter, thread.LastMessageAt, committerAttentionAfter(cfg), time.Now())
+}
+
+// threadFilters builds the WHERE conditions shared by the thread listing and
+// its CSV export. The returned clause starts with " AND" or is empty.
+func threadFilters(r *http.Request, cfg *config.Config) (string, []interface{}) {
+	status := r.URL.Query().Get("status")
+	maturity := r.URL.Query().Get("maturity")
+	needsAuthorAction := r.URL.Query().Get("needs_author_action")
+	search := r.URL.Query().Get("search")
+	author := strings.TrimSpace(r.URL.Query().Get("author"))
+
+	query := ""
+	args := []interface{}{}
+	argCount := 1
+
+	if status != "" {
+		query += " AND status = $" + fmt.Sprintf("%d", argCount)
+		args = append(args, status)
+		argCount++
+	}
+
+	if kind := r.URL.Query().Get("kind"); kind != "" {
+		query += " AND kind = $" + fmt.Sprintf("%d", argCount)
+		args = append(args, kind)
+		argCount++
+	}
+
+	if maturity != "" {
+		query += " AND maturity = $" + fmt.Sprintf("%d", argCount)
+		args = append(args, maturity)
+		argCount++
+	}
+
+	// Idleness is relative to now, so this is evaluated at query time rather than stored
+	if r.URL.Query().Get("committer_attention") == "true" {
+		query += " AND ready_for_committer AND last_message_at <= $" + fmt.Sprintf("%d", argCount)
+		args = append(args, time.Now().Add(-committerAttentionAfter(cfg)))
+		argCount++
+	}
+
+	// Threads with any message whose References/In-Reply-To chain includes the id
+	if references := strings.Trim(strings.TrimSpace(r.URL.Query().Get("references")), "<>"); references != "" {
+		query += " AND id IN (SELECT thread_id FROM messages WHERE reference_ids @> ARRAY[$" + fmt.Sprintf("%d", argCount) + "]::text[])"
+		args = append(args, parser.NormalizeMessageID(references))
+		argCount++
+	}
+
+	if label := r.URL.Query().Get("label"); label != "" {
+		query += " AND id IN (SELECT thread_id FROM thread_labels WHERE label = $" + fmt.Sprintf("%d", argCount) + ")"
+		args = append(args, label)
+		argCount++
+	}
+
+	if needsAuthorAction != "" {
+		query += " AND needs_author_action = $" + fmt.Sprintf("%d", argCount)
+		args = append(args, needsAuthorAction == "true")
+		argCount++
+	}
+
+	if r.URL.Query().Get("has_benchmarks") == "true" {
+		query += " AND has_benchmarks"
+	}
+
+	switch r.URL.Query().Get("consensus") {
+	case "true":
+		query += " AND consensus"
+	case "false":
+		query += " AND NOT consensus"
+	}
+
+	// Singletons (announcements, unanswered questions) stay reachable unless asked to hide them
+	if r.URL.Query().Get("hide_singletons") == "true" {
+		query += " AND message_count > 1"
+	}
+
+	if search != "" {
+		// Search by message_id first (exact match), then by subject (substring match,
+		// ignoring case and accents so "cafe" finds "café")
+		// Message-ID exact match takes priority
+		query += " AND (id IN (SELECT DISTINCT thread_id FROM messages WHERE message_id = $" + fmt.Sprintf("%d", argCount) + ") OR " +
+			unaccentLike("subject", argCount+1) + ")"
+		args = append(args, parser.NormalizeMessageID(strings.Trim(strings.TrimSpace(search), "<>")))
+		args = append(args, "%"+search+"%")
+		argCount += 2
+	}
+
+	// Thread starter's name, matched like the subject search
+	if author != "" {
+		query += " AND " + unaccentLike("first_author", argCount)
+		args = append(args, "%"+author+"%")
+		argCount++
+	}
+
+	return query, args
+}
+
+// unaccentLike matches column against the LIKE pattern in parameter n,
+// ignoring case and accents. The expression matches the trigram indexes
+// created by the accent_insensitive_search migration.
+func unaccentLike(column string, n int) string {
+	return fmt.Sprintf("immutable_unaccent(LOWER(%s)) LIKE immutable_unaccent(LOWER($%d))", column, n)
+}
+
+// threadOrderBy is whitelisted since it is interpolated into the query
+func threadOrderBy(r *http.Request) string {
+	switch r.URL.Query().Get("sort") {
+	case "patch_count":
+		return "patch_count DESC, last_message_at DESC"
+	case "heat":
+		return "heat DESC, last_message_at DESC"
+	}
+	return "last_message_at DESC"
+}
+
+func getThreadsHandler(db *sql.DB, cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 
-		status := r.URL.Query().Get("status")
-		search := r.URL.Query().Get("search")
-		limit := r.URL.Query().Get("limit")
-		offset := r.URL.Query().Get("offset")
-		if limit == "" {
-			limit = "50"
-		}
-		if offset == "" {
-			offset = "0"
+		token, ok := clientToken(w, r)
+		if !ok {
+			return
 		}
 
-		query := `
-			SELECT 
-				id, subject, first_message_id, first_author, first_author_email,
-				created_at, updated_at, last_message_at, message_count, unique_authors, status
-			FROM threads
-			WHERE 1=1
-		`
-
-		args := []interface{}{}
-		argCount := 1
+		// A change feed pages by cursor in updated_at order instead of by offset
+		feed, err := parseChangeFeed(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
 
-		if status != "" {
-			query += " AND status = $" + fmt.Sprintf("%d", argCount)
-			args = append(args, status)
-			argCount++
+		limit, offset := pagination(r, cfg)
+		orderBy := threadOrderBy(r)
+		if feed != nil {
+			offset = 0
+			orderBy = "updated_at ASC, id ASC"
 		}
+		setPageHeaders(w, limit, offset)
 
-		if search != "" {
-			// Search by message_id first (exact match), then by subject (substring match)
-			// Message-ID exact match takes priority
-			query += " AND (id IN (SELECT DISTINCT thread_id FROM messages WHERE message_id = $" + fmt.Sprintf("%d", argCount) + ") OR LOWER(subject) LIKE LOWER($" + fmt.Sprintf("%d", argCount+1) + "))"
-			args = append(args, search)
-			args = append(args, "%"+search+"%")
-			argCount += 2
+		where, args := threadFilters(r, cfg)
+		if feed != nil {
+			feedWhere, feedArgs := feed.where(len(args) + 1)
+			where += feedWhere
+			args = append(args, feedArgs...)
 		}
+		argCount := len(args) + 1
+		query := `SELECT ` + threadColumns + ` FROM threads WHERE 1=1` + where
 
-		query += " ORDER BY last_message_at DESC LIMIT $" + fmt.Sprintf("%d", argCount)
+		query += " ORDER BY " + orderBy + " LIMIT $" + fmt.Sprintf("%d", argCount)
 		args = append(args, limit)
 		argCount++
 
 		query += " OFFSET $" + fmt.Sprintf("%d", argCount)
 		args = append(args, offset)
 
-		rows, err := db.Query(query, args...)
+		rows, err := db.QueryContext(r.Context(), query, args...)
 		if err != nil {
+			if queryTimedOut(w, r, cfg) {
+				return
+			}
 			log.Printf("Error querying threads: %v", err)
 			w.WriteHeader(http.StatusInternalServerError)
 			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch threads"})
@@ -131,50 +387,50 @@ func getThreadsHandler(db *sql.DB) http.HandlerFunc {
 
 		threads := make([]*models.Thread, 0)
 		for rows.Next() {
-			thread := &models.Thread{}
-			var lastMsgAt sql.NullTime
-			if err := rows.Scan(
-				&thread.ID, &thread.Subject, &thread.FirstMessageID, &thread.FirstAuthor,
-				&thread.FirstAuthorEmail, &thread.CreatedAt, &thread.UpdatedAt, &lastMsgAt,
-				&thread.MessageCount, &thread.UniqueAuthors, &thread.Status,
-			); err != nil {
+			thread, err := scanThread(rows)
+			if err != nil {
 				log.Printf("Error scanning thread: %v", err)
 				continue
 			}
-			if lastMsgAt.Valid {
-				thread.LastMessageAt = &lastMsgAt.Time
-			}
+			flagCommitterAttention(thread, cfg)
 			threads = append(threads, thread)
 		}
+		if err := rows.Err(); err != nil {
+			if queryTimedOut(w, r, cfg) {
+				return
+			}
+			log.Printf("Error reading threads: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch threads"})
+			return
+		}
+
+		if token != "" {
+			seen, err := lastSeenAt(db, cfg, token)
+			if err != nil {
+				log.Printf("Error querying client visit: %v", err)
+			}
+			markNewActivity(threads, seen)
+		}
+
+		// An empty page leaves the client's cursor where it was
+		if feed != nil && len(threads) > 0 {
+			last := threads[len(threads)-1]
+			w.Header().Set("X-Next-Cursor", changeFeedCursor{UpdatedAt: last.UpdatedAt, ID: last.ID}.encode())
+		}
 
 		json.NewEncoder(w).Encode(threads)
 	}
 }
 
-func getThreadHandler(db *sql.DB) http.HandlerFunc {
+func getThreadHandler(db *sql.DB, cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 
 		vars := mux.Vars(r)
 		threadID := vars["id"]
 
-		thread := &models.Thread{}
-		var lastMsgAt sql.NullTime
-		err := db.QueryRow(`
-			SELECT 
-				id, subject, first_message_id, first_author, first_author_email,
-				created_at, updated_at, last_message_at, message_count, unique_authors, status
-			FROM threads
-			WHERE id = $1
-		`, threadID).Scan(
-			&thread.ID, &thread.Subject, &thread.FirstMessageID, &thread.FirstAuthor,
-			&thread.FirstAuthorEmail, &thread.CreatedAt, &thread.UpdatedAt, &lastMsgAt,
-			&thread.MessageCount, &thread.UniqueAuthors, &thread.Status,
-		)
-		if err == nil && lastMsgAt.Valid {
-			thread.LastMessageAt = &lastMsgAt.Time
-		}
-
+		thread, err := scanThread(db.QueryRow(`SELECT `+threadColumns+` FROM threads WHERE id = $1`, threadID))
 		if err != nil {
 			if err == sql.ErrNoRows {
 				w.WriteHeader(http.StatusNotFound)
@@ -186,6 +442,49 @@ func getThreadHandler(db *sql.DB) http.HandlerFunc {
 			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch thread"})
 			return
 		}
+		flagCommitterAttention(thread, cfg)
+
+		json.NewEncoder(w).Encode(thread)
+	}
+}
+
+// getThreadByMessageHandler returns the thread containing ?mid=, which may be
+// any message in it rather than the root. The id may be given with or without
+// angle brackets and is normalized like stored ids; the raw form is also tried
+// for rows stored before normalization.
+func getThreadByMessageHandler(db *sql.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		mid := strings.TrimSpace(r.URL.Query().Get("mid"))
+		// Ids copied from archive URLs may arrive encoded twice. PathUnescape
+		// leaves "+" alone; it is common in Gmail message-ids.
+		if unescaped, err := url.PathUnescape(mid); err == nil {
+			mid = unescaped
+		}
+		mid = strings.Trim(strings.TrimSpace(mid), "<>")
+		if mid == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "mid is required"})
+			return
+		}
+
+		thread, err := scanThread(db.QueryRow(`
+			SELECT `+threadColumns+` FROM threads
+			WHERE id = (SELECT thread_id FROM messages WHERE message_id IN ($1, $2) LIMIT 1)
+		`, parser.NormalizeMessageID(mid), mid))
+		if err == sql.ErrNoRows {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "No thread contains that message"})
+			return
+		}
+		if err != nil {
+			log.Printf("Error querying thread by message: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch thread"})
+			return
+		}
+		flagCommitterAttention(thread, cfg)
 
 		json.NewEncoder(w).Encode(thread)
 	}
@@ -197,13 +496,15 @@ func getThreadMessagesHandler(db *sql.DB) http.HandlerFunc {
 
 		vars := mux.Vars(r)
 		threadID := vars["id"]
+		stripDiffs := r.URL.Query().Get("strip_diffs") == "true"
 
 		rows, err := db.Query(`
-			SELECT id, thread_id, message_id, subject, author, author_email, body, created_at,
-			       has_patch, patch_status, commitfest_id
+			SELECT id, thread_id, message_id, subject, author, author_email, body, body_html, created_at,
+			       has_patch, patch_status, commitfest_id, size_bytes, empty_body, decode_warning,
+			       supersedes, superseded_by, attachments, archived_at, has_benchmarks, sender, reply_to, lists
 			FROM messages
 			WHERE thread_id = $1
-			ORDER BY created_at ASC
+			ORDER BY created_at ASC, message_id ASC
 		`, threadID)
 
 		if err != nil {
@@ -214,17 +515,23 @@ func getThreadMessagesHandler(db *sql.DB) http.HandlerFunc {
 		}
 		defer rows.Close()
 
-		var messages []*models.Message
+		messages := make([]*models.Message, 0)
 		for rows.Next() {
 			msg := &models.Message{}
 			if err := rows.Scan(
 				&msg.ID, &msg.ThreadID, &msg.MessageID, &msg.Subject,
-				&msg.Author, &msg.AuthorEmail, &msg.Body, &msg.CreatedAt,
-				&msg.HasPatch, &msg.PatchStatus, &msg.CommitFestID,
+				&msg.Author, &msg.AuthorEmail, &msg.Body, &msg.BodyHTML, &msg.CreatedAt,
+				&msg.HasPatch, &msg.PatchStatus, &msg.CommitFestID, &msg.SizeBytes, &msg.EmptyBody, &msg.DecodeWarning,
+				&msg.Supersedes, &msg.SupersededBy, pq.Array(&msg.Attachments), &msg.ArchiveURL, &msg.HasBenchmarks,
+				&msg.Sender, &msg.ReplyTo, pq.Array(&msg.Lists),
 			); err != nil {
 				log.Printf("Error scanning message: %v", err)
 				continue
 			}
+			msg.ArchiveURL = parser.ArchiveURL(msg.MessageID, msg.ArchiveURL)
+			if stripDiffs {
+				msg.Body = parser.StripDiffs(msg.Body)
+			}
 			messages = append(messages, msg)
 		}
 
@@ -232,23 +539,259 @@ func getThreadMessagesHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
+// timelineBucket is one slot of a thread's activity timeline
+type timelineBucket struct {
+	Start time.Time `json:"start"`
+	Count int       `json:"count"`
+}
+
+// getThreadTimelineHandler returns message counts bucketed by day or week over the
+// thread's lifespan, with zero-count buckets for quiet periods
+func getThreadTimelineHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		vars := mux.Vars(r)
+		threadID := vars["id"]
+
+		bucket := r.URL.Query().Get("bucket")
+		if bucket == "" {
+			bucket = "day"
+		}
+		if bucket != "day" && bucket != "week" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "bucket must be 'day' or 'week'"})
+			return
+		}
+
+		exists, err := threadExists(db, threadID)
+		if err != nil {
+			log.Printf("Error querying thread: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch timeline"})
+			return
+		}
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Thread not found"})
+			return
+		}
+
+		// generate_series over the thread's span fills in empty buckets
+		rows, err := db.Query(`
+			WITH bounds AS (
+				SELECT date_trunc($2, MIN(created_at)) AS first_bucket,
+				       date_trunc($2, MAX(created_at)) AS last_bucket
+				FROM messages
+				WHERE thread_id = $1
+			),
+			counts AS (
+				SELECT date_trunc($2, created_at) AS bucket, COUNT(*) AS n
+				FROM messages
+				WHERE thread_id = $1
+				GROUP BY 1
+			)
+			SELECT s.bucket, COALESCE(c.n, 0)
+			FROM bounds b
+			CROSS JOIN LATERAL generate_series(b.first_bucket, b.last_bucket, ('1 ' || $2)::interval) AS s(bucket)
+			LEFT JOIN counts c ON c.bucket = s.bucket
+			ORDER BY s.bucket ASC
+		`, threadID, bucket)
+		if err != nil {
+			log.Printf("Error querying timeline: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch timeline"})
+			return
+		}
+		defer rows.Close()
+
+		buckets := make([]timelineBucket, 0)
+		for rows.Next() {
+			var b timelineBucket
+			if err := rows.Scan(&b.Start, &b.Count); err != nil {
+				log.Printf("Error scanning timeline bucket: %v", err)
+				continue
+			}
+			buckets = append(buckets, b)
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"thread_id": threadID,
+			"bucket":    bucket,
+			"buckets":   buckets,
+		})
+	}
+}
+
+// threadExists reports whether a thread with the given id exists
+func threadExists(db *sql.DB, threadID string) (bool, error) {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM threads WHERE id = $1)", threadID).Scan(&exists)
+	return exists, err
+}
+
+// threadParticipant is an author's first appearance in a thread
+type threadParticipant struct {
+	Author         string    `json:"author"`
+	AuthorEmail    string    `json:"author_email"`
+	FirstMessageAt time.Time `json:"first_message_at"`
+	MessageCount   int       `json:"message_count"`
+}
+
+// getThreadAuthorsOverTimeHandler lists each participant with the time of their
+// first message in the thread, ordered by when they joined
+func getThreadAuthorsOverTimeHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		vars := mux.Vars(r)
+		threadID := vars["id"]
+
+		exists, err := threadExists(db, threadID)
+		if err != nil {
+			log.Printf("Error querying thread: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch authors"})
+			return
+		}
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Thread not found"})
+			return
+		}
+
+		// Display name is taken from the author's first message in the thread
+		rows, err := db.Query(`
+			SELECT (ARRAY_AGG(author ORDER BY created_at, message_id))[1], author_email, MIN(created_at), COUNT(*)
+			FROM messages
+			WHERE thread_id = $1
+			GROUP BY author_email
+			ORDER BY MIN(created_at) ASC, author_email ASC
+		`, threadID)
+		if err != nil {
+			log.Printf("Error querying thread authors: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch authors"})
+			return
+		}
+		defer rows.Close()
+
+		participants := make([]threadParticipant, 0)
+		for rows.Next() {
+			var p threadParticipant
+			if err := rows.Scan(&p.Author, &p.AuthorEmail, &p.FirstMessageAt, &p.MessageCount); err != nil {
+				log.Printf("Error scanning thread author: %v", err)
+				continue
+			}
+			participants = append(participants, p)
+		}
+
+		json.NewEncoder(w).Encode(participants)
+	}
+}
+
+// getThreadReviewersHandler lists the thread's participants with their inferred
+// role (author, reviewer, commenter) and review-comment counts
+func getThreadReviewersHandler(db *sql.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		vars := mux.Vars(r)
+		threadID := vars["id"]
+
+		participants, err := newThreadAnalyzer(db, cfg).ClassifyParticipants(threadID)
+		if err == sql.ErrNoRows {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Thread not found"})
+			return
+		}
+		if err != nil {
+			log.Printf("Error classifying thread participants: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch reviewers"})
+			return
+		}
+
+		json.NewEncoder(w).Encode(participants)
+	}
+}
+
+// getPatchHistoryHandler lists the thread's recorded patch status transitions, oldest first
+func getPatchHistoryHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		vars := mux.Vars(r)
+		threadID := vars["id"]
+
+		exists, err := threadExists(db, threadID)
+		if err != nil {
+			log.Printf("Error querying thread: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch patch history"})
+			return
+		}
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Thread not found"})
+			return
+		}
+
+		rows, err := db.Query(`
+			SELECT from_status, to_status, message_id, changed_at
+			FROM patch_status_history
+			WHERE thread_id = $1
+			ORDER BY changed_at ASC, message_id ASC
+		`, threadID)
+		if err != nil {
+			log.Printf("Error querying patch history: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch patch history"})
+			return
+		}
+		defer rows.Close()
+
+		history := make([]models.PatchTransition, 0)
+		for rows.Next() {
+			var t models.PatchTransition
+			if err := rows.Scan(&t.From, &t.To, &t.MessageID, &t.ChangedAt); err != nil {
+				log.Printf("Error scanning patch transition: %v", err)
+				continue
+			}
+			history = append(history, t)
+		}
+
+		json.NewEncoder(w).Encode(history)
+	}
+}
+
 func getMessageHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Vary", "Accept")
 
 		vars := mux.Vars(r)
 		messageID := vars["id"]
 
 		msg := &models.Message{}
+		// Rank within the thread so clients can offer prev/next navigation
 		err := db.QueryRow(`
-			SELECT id, thread_id, message_id, subject, author, author_email, body, created_at,
-			       has_patch, patch_status, commitfest_id
-			FROM messages
+			SELECT id, thread_id, message_id, subject, author, author_email, body, body_html, created_at,
+			       has_patch, patch_status, commitfest_id, size_bytes, empty_body, decode_warning,
+			       supersedes, superseded_by, attachments, archived_at, has_benchmarks, sender, reply_to, lists, position, message_count
+			FROM (
+				SELECT *,
+				       ROW_NUMBER() OVER (PARTITION BY thread_id ORDER BY created_at ASC, message_id ASC) AS position,
+				       COUNT(*) OVER (PARTITION BY thread_id) AS message_count
+				FROM messages
+				WHERE thread_id = (SELECT thread_id FROM messages WHERE id = $1)
+			) ranked
 			WHERE id = $1
 		`, messageID).Scan(
 			&msg.ID, &msg.ThreadID, &msg.MessageID, &msg.Subject,
-			&msg.Author, &msg.AuthorEmail, &msg.Body, &msg.CreatedAt,
-			&msg.HasPatch, &msg.PatchStatus, &msg.CommitFestID,
+			&msg.Author, &msg.AuthorEmail, &msg.Body, &msg.BodyHTML, &msg.CreatedAt,
+			&msg.HasPatch, &msg.PatchStatus, &msg.CommitFestID, &msg.SizeBytes, &msg.EmptyBody, &msg.DecodeWarning,
+			&msg.Supersedes, &msg.SupersededBy, pq.Array(&msg.Attachments), &msg.ArchiveURL, &msg.HasBenchmarks, &msg.Sender, &msg.ReplyTo, pq.Array(&msg.Lists), &msg.Position, &msg.MessageCount,
 		)
 
 		if err == sql.ErrNoRows {
@@ -261,6 +804,17 @@ func getMessageHandler(db *sql.DB) http.HandlerFunc {
 			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch message"})
 			return
 		}
+		msg.ArchiveURL = parser.ArchiveURL(msg.MessageID, msg.ArchiveURL)
+
+		// Readers can drop inline diffs to see just the prose
+		if r.URL.Query().Get("strip_diffs") == "true" {
+			msg.Body = parser.StripDiffs(msg.Body)
+		}
+
+		if prefersPlainText(r) {
+			writeMessageText(w, msg)
+			return
+		}
 
 		json.NewEncoder(w).Encode(msg)
 	}
@@ -272,18 +826,28 @@ func getStatsHandler(db *sql.DB) http.HandlerFunc {
 
 		stats := map[string]interface{}{}
 
+		// Announcements aren't development activity, so they're left out of
+		// thread counts unless asked for
+		kindFilter := " AND kind <> 'announcement'"
+		if r.URL.Query().Get("include_announcements") == "true" {
+			kindFilter = ""
+		}
+
 		// Total threads
 		var totalThreads int
-		db.QueryRow("SELECT COUNT(*) FROM threads").Scan(&totalThreads)
+		db.QueryRow("SELECT COUNT(*) FROM threads WHERE 1=1" + kindFilter).Scan(&totalThreads)
 		stats["total_threads"] = totalThreads
 
+		var announcements int
+		db.QueryRow("SELECT COUNT(*) FROM threads WHERE kind = 'announcement'").Scan(&announcements)
+		stats["announcements"] = announcements
+
 		// Threads by status
-		statuses := []string{"in-progress", "has-patch", "stalled-patch", "discussion", "stalled", "abandoned"}
 		statusCounts := make(map[string]int)
-		for _, status := range statuses {
+		for _, def := range analyzer.Statuses {
 			var count int
-			db.QueryRow("SELECT COUNT(*) FROM threads WHERE status = $1", status).Scan(&count)
-			statusCounts[status] = count
+			db.QueryRow("SELECT COUNT(*) FROM threads WHERE status = $1"+kindFilter, def.Status).Scan(&count)
+			statusCounts[def.Status] = count
 		}
 		stats["by_status"] = statusCounts
 
@@ -305,13 +869,167 @@ func getStatsHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
+// getDecodeWarningsHandler lists messages whose body didn't decode cleanly, newest
+// first, optionally filtered by a warning substring (?warning=base64)
+func getDecodeWarningsHandler(db *sql.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		warning := r.URL.Query().Get("warning")
+		limit, offset := pagination(r, cfg)
+		setPageHeaders(w, limit, offset)
+
+		rows, err := db.Query(`
+			SELECT id, thread_id, message_id, subject, author, author_email, created_at, decode_warning
+			FROM messages
+			WHERE decode_warning <> '' AND decode_warning LIKE '%' || $1 || '%'
+			ORDER BY created_at DESC, message_id
+			LIMIT $2 OFFSET $3
+		`, warning, limit, offset)
+		if err != nil {
+			log.Printf("Error querying decode warnings: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch decode warnings"})
+			return
+		}
+		defer rows.Close()
+
+		messages := make([]*models.Message, 0)
+		for rows.Next() {
+			msg := &models.Message{}
+			if err := rows.Scan(
+				&msg.ID, &msg.ThreadID, &msg.MessageID, &msg.Subject,
+				&msg.Author, &msg.AuthorEmail, &msg.CreatedAt, &msg.DecodeWarning,
+			); err != nil {
+				log.Printf("Error scanning message: %v", err)
+				continue
+			}
+			messages = append(messages, msg)
+		}
+
+		json.NewEncoder(w).Encode(messages)
+	}
+}
+
+// getStatusesHandler lists the statuses the classifier can assign, with descriptions
+// and current thread counts, so clients don't hardcode the vocabulary
+func getStatusesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		rows, err := db.Query("SELECT status, COUNT(*) FROM threads GROUP BY status")
+		if err != nil {
+			log.Printf("Error querying status counts: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch statuses"})
+			return
+		}
+		defer rows.Close()
+
+		counts := make(map[string]int)
+		for rows.Next() {
+			var status string
+			var count int
+			if err := rows.Scan(&status, &count); err != nil {
+				log.Printf("Error scanning status count: %v", err)
+				continue
+			}
+			counts[status] = count
+		}
+
+		type statusInfo struct {
+			analyzer.StatusDefinition
+			Count int `json:"count"`
+		}
+		statuses := make([]statusInfo, 0, len(analyzer.Statuses))
+		for _, def := range analyzer.Statuses {
+			statuses = append(statuses, statusInfo{StatusDefinition: def, Count: counts[def.Status]})
+		}
+
+		json.NewEncoder(w).Encode(statuses)
+	}
+}
+
+// getLargestMessagesHandler lists the biggest messages by raw mbox size, without bodies
+func getLargestMessagesHandler(db *sql.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		limit, offset := pagination(r, cfg)
+		setPageHeaders(w, limit, offset)
+
+		rows, err := db.Query(`
+			SELECT id, thread_id, message_id, subject, author, author_email, created_at,
+			       has_patch, size_bytes
+			FROM messages
+			ORDER BY size_bytes DESC, id
+			LIMIT $1 OFFSET $2
+		`, limit, offset)
+		if err != nil {
+			log.Printf("Error querying largest messages: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch largest messages"})
+			return
+		}
+		defer rows.Close()
+
+		messages := make([]*models.Message, 0)
+		for rows.Next() {
+			msg := &models.Message{}
+			if err := rows.Scan(
+				&msg.ID, &msg.ThreadID, &msg.MessageID, &msg.Subject,
+				&msg.Author, &msg.AuthorEmail, &msg.CreatedAt,
+				&msg.HasPatch, &msg.SizeBytes,
+			); err != nil {
+				log.Printf("Error scanning message: %v", err)
+				continue
+			}
+			messages = append(messages, msg)
+		}
+
+		json.NewEncoder(w).Encode(messages)
+	}
+}
+
 func getSyncProgressHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	progress := GlobalSyncState.Get()
 	json.NewEncoder(w).Encode(progress)
 }
 
-func groupByThread(messages []*models.Message) map[string][]*models.Message {
+// cancelSyncHandler stops the running sync, directory ingest or patch
+// reanalysis. The sync winds down in the background; is_syncing in
+// /api/sync/progress turns false once it has.
+func cancelSyncHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !GlobalSyncState.Cancel() {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "No sync is in progress"})
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":    "Sync cancellation requested",
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+}
+
+func groupByThread(messages []*models.Message, maxDepth int) map[string][]*models.Message {
+	messageToRoot := threadRoots(messages, maxDepth)
+
+	// Group messages by their root
+	threadMap := make(map[string][]*models.Message)
+	for _, msg := range messages {
+		root := messageToRoot[msg.MessageID]
+		threadMap[root] = append(threadMap[root], msg)
+	}
+
+	return threadMap
+}
+
+// threadRoots maps each message-id (and any ancestor resolved along the way)
+// to its thread root
+func threadRoots(messages []*models.Message, maxDepth int) map[string]string {
 	// RFC 5256 THREAD=REFERENCES implementation
 	// Build a map of message-id to message for quick lookups
 	messageMap := make(map[string]*models.Message)
@@ -322,24 +1040,18 @@ func groupByThread(messages []*models.Message) map[string][]*models.Message {
 	// messageToRoot maps each message-id to its thread root
 	messageToRoot := make(map[string]string)
 
-	// First, build the thread structure using References and In-Reply-To
+	// Build the thread structure using References and In-Reply-To
 	for _, msg := range messages {
-		root := findThreadRootRFC5256(msg, messageMap, messageToRoot)
+		root := findThreadRootRFC5256(msg, messageMap, messageToRoot, maxDepth)
 		messageToRoot[msg.MessageID] = root
 	}
-
-	// Group messages by their root
-	threadMap := make(map[string][]*models.Message)
-	for _, msg := range messages {
-		root := messageToRoot[msg.MessageID]
-		threadMap[root] = append(threadMap[root], msg)
-	}
-
-	return threadMap
+	return messageToRoot
 }
 
-// findThreadRootRFC5256 implements RFC 5256 threading algorithm
-func findThreadRootRFC5256(msg *models.Message, messageMap map[string]*models.Message, messageToRoot map[string]string) string {
+// firstReference returns the first valid (normalized) message-id in msg's
+// References, falling back to In-Reply-To, then to the parent its Outlook
+// Thread-Index resolved to, or "" if it has none of these
+func firstReference(msg *models.Message) string {
 	// Extract all references from the References header
 	refs := parseReferences(msg.RefersTo)
 
@@ -349,50 +1061,150 @@ func findThreadRootRFC5256(msg *models.Message, messageMap map[string]*models.Me
 		refs = append(refs, msg.InReplyTo)
 	}
 
-	// If no references, this message is a root
-	if len(refs) == 0 {
-		return msg.MessageID
-	}
-
-	// The first reference in the chain is the real root (or the oldest missing message).
-	// Even if that message doesn't exist in our dataset, we should use it as the thread root
-	// to ensure all messages referencing it get grouped together.
-	// This is important for handling threads with missing intermediate messages.
-
-	// Traverse the reference chain to find the root (first/oldest reference)
-	currentRefID := ""
 	for _, refID := range refs {
 		// Clean up the reference ID
 		refID = strings.Trim(strings.TrimSpace(refID), "<>")
-		if refID == "" {
+		if refID != "" {
+			return parser.NormalizeMessageID(refID)
+		}
+	}
+	return msg.OutlookParent
+}
+
+// resolveOutlookParents sets OutlookParent on Outlook replies that carry a
+// Thread-Index but no References or In-Reply-To. The parent is the message of
+// the same conversation nearest its start: the one in messages with the
+// shallowest Thread-Index (earliest on ties), or the conversation's earliest
+// stored message (stored maps conversation id to message-id) when the batch
+// only has other replies. Messages without a usable Thread-Index are left to
+// become roots of their own, as before.
+func resolveOutlookParents(messages []*models.Message, stored map[string]string) {
+	type entry struct {
+		msg   *models.Message
+		depth int
+	}
+	starters := make(map[string]entry)
+	for _, msg := range messages {
+		msg.OutlookParent = ""
+		conv, depth := parser.ThreadConversation(msg.ThreadIndex)
+		if conv == "" {
+			continue
+		}
+		best, ok := starters[conv]
+		if !ok || depth < best.depth || (depth == best.depth && messageBefore(msg, best.msg)) {
+			starters[conv] = entry{msg, depth}
+		}
+	}
+
+	for _, msg := range messages {
+		conv, depth := parser.ThreadConversation(msg.ThreadIndex)
+		if conv == "" || depth == 0 || firstReference(msg) != "" {
 			continue
 		}
-		currentRefID = refID
-		break // The first valid reference is our candidate root
+		starter := starters[conv]
+		if storedID := stored[conv]; storedID != "" && (starter.depth > 0 || starter.msg == msg) {
+			if storedID != msg.MessageID {
+				msg.OutlookParent = storedID
+			}
+			continue
+		}
+		if starter.msg != msg {
+			msg.OutlookParent = starter.msg.MessageID
+		}
 	}
+}
 
-	if currentRefID == "" {
+// storedConversations maps the Thread-Index conversations of messages to the
+// earliest stored message of each, so Outlook replies can join a thread that
+// started in an earlier batch
+func storedConversations(db *sql.DB, messages []*models.Message) map[string]string {
+	var convs []string
+	for _, msg := range messages {
+		if conv, depth := parser.ThreadConversation(msg.ThreadIndex); conv != "" && depth > 0 {
+			convs = append(convs, conv)
+		}
+	}
+	stored := make(map[string]string)
+	if len(convs) == 0 {
+		return stored
+	}
+
+	rows, err := db.Query(`
+		SELECT DISTINCT ON (thread_conversation) thread_conversation, message_id
+		FROM messages WHERE thread_conversation = ANY($1)
+		ORDER BY thread_conversation, created_at ASC, message_id ASC
+	`, pq.Array(convs))
+	if err != nil {
+		slog.Warn("Error looking up stored Outlook conversations", "error", err)
+		return stored
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var conv, messageID string
+		if rows.Scan(&conv, &messageID) == nil {
+			stored[conv] = messageID
+		}
+	}
+	return stored
+}
+
+// findThreadRootRFC5256 implements RFC 5256 threading algorithm
+//
+// The first reference in the chain is the real root (or the oldest missing message).
+// Even if that message doesn't exist in our dataset, we use it as the thread root
+// to ensure all messages referencing it get grouped together.
+// This is important for handling threads with missing intermediate messages.
+//
+// The chain is followed iteratively. After maxDepth hops, or on a reference
+// cycle, the message's own first reference is used as the root instead.
+func findThreadRootRFC5256(msg *models.Message, messageMap map[string]*models.Message, messageToRoot map[string]string, maxDepth int) string {
+	firstRef := firstReference(msg)
+	if firstRef == "" {
 		// No valid references found, this message is a root
 		return msg.MessageID
 	}
 
-	// Check if we already know the root for the first reference
-	if root, exists := messageToRoot[currentRefID]; exists {
-		return root
-	}
+	visited := map[string]bool{msg.MessageID: true}
+	var path []string // ancestors resolved along the way, memoized below
+	root := ""
+	for current, depth := firstRef, 0; root == ""; depth++ {
+		// Check if we already know the root for this reference
+		if known, exists := messageToRoot[current]; exists {
+			root = known
+			break
+		}
+
+		refMsg, exists := messageMap[current]
+		if !exists {
+			// Reference doesn't exist in our dataset, but we use it as the thread root anyway
+			// This ensures all messages that reference it get grouped together,
+			// even if the message itself is missing from our archives
+			root = current
+			break
+		}
 
-	// Check if the first reference exists in our message set
-	if refMsg, exists := messageMap[currentRefID]; exists {
-		// Recursively find the root of this reference
-		root := findThreadRootRFC5256(refMsg, messageMap, messageToRoot)
-		messageToRoot[currentRefID] = root
-		return root
+		if visited[current] || depth >= maxDepth {
+			slog.Warn("Reference chain too deep or cyclic; using first reference as root",
+				"message_id", msg.MessageID, "depth", depth, "root", firstRef)
+			root = firstRef
+			break
+		}
+		visited[current] = true
+		path = append(path, current)
+
+		next := firstReference(refMsg)
+		if next == "" {
+			// The referenced message has no references of its own, so it is the root
+			root = current
+			break
+		}
+		current = next
 	}
 
-	// First reference doesn't exist in our dataset, but we use it as the thread root anyway
-	// This ensures all messages that reference it get grouped together,
-	// even if the message itself is missing from our archives
-	return currentRefID
+	for _, id := range path {
+		messageToRoot[id] = root
+	}
+	return root
 }
 
 // parseReferences extracts individual message IDs from a References header
@@ -435,18 +1247,70 @@ func parseReferences(references string) []string {
 		}
 	}
 
-	return refs
+	return refs
+}
+
+// referenceIDs returns the normalized, de-duplicated message-ids msg refers to
+// through References and In-Reply-To, for indexing in messages.reference_ids
+func referenceIDs(msg *models.Message) []string {
+	refs := parseReferences(msg.RefersTo)
+	if msg.InReplyTo != "" {
+		refs = append(refs, msg.InReplyTo)
+	}
+
+	seen := make(map[string]bool, len(refs))
+	ids := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		ref = parser.NormalizeMessageID(strings.Trim(strings.TrimSpace(ref), "<>"))
+		if ref != "" && !seen[ref] {
+			seen[ref] = true
+			ids = append(ids, ref)
+		}
+	}
+	return ids
+}
+
+// compactReferences rewrites a References header as its de-duplicated,
+// normalized message-ids ("<a@x> <b@y>"). When max > 0 and there are more ids,
+// the first (the thread root) and the max-1 most recent are kept, which is all
+// threading looks at.
+func compactReferences(references string, max int) string {
+	refs := parseReferences(references)
+	seen := make(map[string]bool, len(refs))
+	ids := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		ref = parser.NormalizeMessageID(strings.Trim(strings.TrimSpace(ref), "<>"))
+		if ref != "" && !seen[ref] {
+			seen[ref] = true
+			ids = append(ids, ref)
+		}
+	}
+
+	if max > 0 && len(ids) > max {
+		ids = append(ids[:1], ids[len(ids)-(max-1):]...)
+	}
+	if len(ids) == 0 {
+		return ""
+	}
+	return "<" + strings.Join(ids, "> <") + ">"
 }
 
 // sortMessagesByTime sorts messages by creation time (earliest first)
+// Equal timestamps are ordered by message-id, matching the SQL orderings, so the
+// thread's first message doesn't depend on input order. Messages equal in both
+// (duplicates) keep their input order.
 func sortMessagesByTime(msgs []*models.Message) {
-	for i := 0; i < len(msgs)-1; i++ {
-		for j := i + 1; j < len(msgs); j++ {
-			if msgs[i].CreatedAt.After(msgs[j].CreatedAt) {
-				msgs[i], msgs[j] = msgs[j], msgs[i]
-			}
-		}
+	sort.SliceStable(msgs, func(i, j int) bool {
+		return messageBefore(msgs[i], msgs[j])
+	})
+}
+
+// messageBefore orders messages by creation time, then message-id
+func messageBefore(a, b *models.Message) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
 	}
+	return a.MessageID < b.MessageID
 }
 
 func uploadMboxHandler(db *sql.DB, cfg *config.Config) http.HandlerFunc {
@@ -469,19 +1333,11 @@ func uploadMboxHandler(db *sql.DB, cfg *config.Config) http.HandlerFunc {
 		}
 		defer file.Close()
 
-		// Read file content
-		buf := make([]byte, header.Size)
-		_, err = file.Read(buf)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to read file"})
-			return
-		}
-
-		// Save mbox file
-		mboxParser := parser.NewMboxParser(cfg.DataDir)
-		filePath, err := mboxParser.SaveMboxFile(header.Filename, buf)
+		// Save mbox file, streaming from the upload rather than buffering it
+		mboxParser := newMboxParser(cfg)
+		filePath, err := mboxParser.SaveMboxFile(header.Filename, file)
 		if err != nil {
+			log.Printf("Error saving uploaded mbox %q: %v", header.Filename, err)
 			w.WriteHeader(http.StatusInternalServerError)
 			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save file"})
 			return
@@ -498,11 +1354,134 @@ func uploadMboxHandler(db *sql.DB, cfg *config.Config) http.HandlerFunc {
 	}
 }
 
+// previewThread summarizes one would-be thread in an mbox preview
+type previewThread struct {
+	RootMessageID string `json:"root_message_id"`
+	Subject       string `json:"subject"`
+	MessageCount  int    `json:"message_count"`
+}
+
+// previewMboxHandler parses an uploaded mbox and reports the thread grouping and
+// parse stats it would produce, without saving the file or touching the database
+func previewMboxHandler(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		err := r.ParseMultipartForm(100 << 20) // 100MB max
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to parse upload"})
+			return
+		}
+
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Missing file"})
+			return
+		}
+		defer file.Close()
+
+		messages, stats, err := newMboxParser(cfg).ParseMbox(file)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to parse mbox: " + err.Error()})
+			return
+		}
+
+		resolveOutlookParents(messages, nil)
+		threads := groupByThread(messages, cfg.ThreadMaxDepth)
+		summaries := make([]previewThread, 0, len(threads))
+		for root, msgs := range threads {
+			sortMessagesByTime(msgs)
+			summaries = append(summaries, previewThread{
+				RootMessageID: root,
+				Subject:       msgs[0].Subject,
+				MessageCount:  len(msgs),
+			})
+		}
+		// Largest threads first make the best sample
+		sort.Slice(summaries, func(i, j int) bool {
+			if summaries[i].MessageCount != summaries[j].MessageCount {
+				return summaries[i].MessageCount > summaries[j].MessageCount
+			}
+			return summaries[i].RootMessageID < summaries[j].RootMessageID
+		})
+
+		const sampleSize = 20
+		if len(summaries) > sampleSize {
+			summaries = summaries[:sampleSize]
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"filename":      header.Filename,
+			"thread_count":  len(threads),
+			"message_count": len(messages),
+			"stats":         stats,
+			"sample":        summaries,
+		})
+	}
+}
+
+// syncRequest is the optional body of POST /api/sync/mbox/all. Start and End are
+// year-months ("2020-01"); when both are empty the incremental range is used.
+type syncRequest struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// monthRange is an inclusive range of months to sync
+type monthRange struct {
+	start, end time.Time
+}
+
+// parseSyncRange validates an explicit sync range. Returns nil when none was given.
+func parseSyncRange(req syncRequest) (*monthRange, error) {
+	if req.Start == "" && req.End == "" {
+		return nil, nil
+	}
+	if req.Start == "" || req.End == "" {
+		return nil, fmt.Errorf("both start and end are required")
+	}
+	start, err := time.Parse("2006-01", req.Start)
+	if err != nil {
+		return nil, fmt.Errorf("start must be YYYY-MM")
+	}
+	end, err := time.Parse("2006-01", req.End)
+	if err != nil {
+		return nil, fmt.Errorf("end must be YYYY-MM")
+	}
+	if start.After(end) {
+		return nil, fmt.Errorf("start must not be after end")
+	}
+	return &monthRange{start: start, end: end}, nil
+}
+
 func syncMboxHandler(db *sql.DB, cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 
-		go performMboxSync(db, cfg)
+		// An empty body means "sync the auto-computed incremental range"
+		var req syncRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON body"})
+			return
+		}
+		months, err := parseSyncRange(req)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		ctx, ok := GlobalSyncState.TryStartSync()
+		if !ok {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{"error": "A sync is already in progress"})
+			return
+		}
+		go performMboxSync(ctx, db, cfg, months)
 
 		json.NewEncoder(w).Encode(map[string]string{
 			"status":    "Mbox sync started",
@@ -512,47 +1491,53 @@ func syncMboxHandler(db *sql.DB, cfg *config.Config) http.HandlerFunc {
 }
 
 func processMboxFile(db *sql.DB, cfg *config.Config, filePath string) {
-	log.Printf("Processing mbox file: %s", filePath)
+	slog.Info("Processing mbox file", "file", filePath)
 
-	mboxParser := parser.NewMboxParser(cfg.DataDir)
-	messages, stats, err := mboxParser.ParseMboxFile(filePath)
+	mboxParser := newMboxParser(cfg)
+	messages, _, err := mboxParser.ParseMboxFile(filePath)
 	if err != nil {
-		log.Printf("Error parsing mbox file: %v", err)
+		slog.Error("Error parsing mbox file", "file", filePath, "error", err)
 		return
 	}
 
-	if stats != nil {
-		log.Printf("Parse stats: %d total, %d parsed, %d skipped", stats.Total, stats.Parsed, stats.Skipped)
-	}
-
-	storeMessagesInDB(db, messages)
-	log.Printf("Completed processing %d messages from %s", len(messages), filePath)
+	storeMessagesInDB(db, cfg, messages)
+	slog.Info("Completed processing mbox file", "file", filePath, "messages", len(messages))
 }
 
-func performMboxSync(db *sql.DB, cfg *config.Config) {
-	log.Println("Starting mbox sync from PostgreSQL.org archives...")
-	GlobalSyncState.SetSyncing(true)
+// performMboxSync downloads and ingests monthly archives. A nil months range syncs
+// incrementally from the latest stored message (or the last 365 days) to now.
+// Callers must claim the sync with GlobalSyncState.TryStartSync first. When
+// ctx is cancelled, downloads in flight are aborted, no further month is
+// started, and the run is recorded as interrupted.
+func performMboxSync(ctx context.Context, db *sql.DB, cfg *config.Config, months *monthRange) {
+	slog.Info("Starting mbox sync from PostgreSQL.org archives")
 	defer GlobalSyncState.SetSyncing(false)
 
 	// Catch any panics and log them
 	defer func() {
 		if r := recover(); r != nil {
-			log.Printf("PANIC in performMboxSync: %v", r)
+			slog.Error("PANIC in performMboxSync", "panic", r)
 		}
 	}()
 
-	// Determine range: from last recorded message (or 365 days ago) to present
+	// Determine range: from last recorded message (or 365 days ago) to present,
+	// unless the caller asked for an explicit range
 	const initialSyncDays = 365
 	var lastMessageAt sql.NullTime
-	err := db.QueryRow("SELECT MAX(created_at) FROM messages").Scan(&lastMessageAt)
-	if err != nil {
-		log.Printf("Error getting last message date: %v", err)
-		return
+	if months == nil {
+		err := db.QueryRow("SELECT MAX(created_at) FROM messages").Scan(&lastMessageAt)
+		if err != nil {
+			slog.Error("Error getting last message date", "error", err)
+			return
+		}
 	}
 
 	now := time.Now()
 	var start time.Time
-	if lastMessageAt.Valid && !lastMessageAt.Time.IsZero() {
+	end := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if months != nil {
+		start, end = months.start, months.end
+	} else if lastMessageAt.Valid && !lastMessageAt.Time.IsZero() {
 		// Incremental: sync from the month after last message through current month
 		start = time.Date(lastMessageAt.Time.Year(), lastMessageAt.Time.Month(), 1, 0, 0, 0, 0, time.UTC)
 		// Include the month we have so we can re-download and catch any late-arriving messages
@@ -562,99 +1547,256 @@ func performMboxSync(db *sql.DB, cfg *config.Config) {
 		start = now.AddDate(0, 0, -initialSyncDays)
 		start = time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC)
 	}
-	end := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
 
-	months := monthsBetween(start, end)
-	if len(months) == 0 {
-		log.Println("No new months to sync")
+	// A run the last one cut short (MAX_MONTHS_PER_SYNC, cancel, crash) picks up where it stopped
+	if pending := pendingSyncRange(db, months); pending != nil {
+		slog.Info("Resuming unfinished sync", "from", pending.start.Format("2006-01"), "to", pending.end.Format("2006-01"))
+		start, end = pending.start, pending.end
+	}
+
+	syncMonths := monthsBetween(start, end)
+	if len(syncMonths) == 0 {
+		slog.Info("No new months to sync")
 		return
 	}
 
-	totalMonths := len(months)
-	log.Printf("Syncing %d month(s) from %s to %s", totalMonths, start.Format("2006-01"), end.Format("2006-01"))
+	syncMonths, remaining := capSyncMonths(syncMonths, cfg.MaxMonthsPerSync, cfg.SyncOrder == config.SyncOrderNewest)
+	GlobalSyncState.SetRemainingMonths(0)
+	if remaining != nil {
+		left := len(monthsBetween(remaining.start, remaining.end))
+		GlobalSyncState.SetRemainingMonths(left)
+		slog.Warn("Sync capped by MAX_MONTHS_PER_SYNC; run the sync again to continue",
+			"max_months", cfg.MaxMonthsPerSync, "remaining_months", left,
+			"remaining_from", remaining.start.Format("2006-01"), "remaining_to", remaining.end.Format("2006-01"))
+	}
+
+	totalMonths := len(syncMonths)
+	slog.Info("Syncing months", "count", totalMonths,
+		"from", syncMonths[0].time().Format("2006-01"), "to", syncMonths[totalMonths-1].time().Format("2006-01"))
 	GlobalSyncState.Update(0, totalMonths, "")
 
+	var (
+		progressMu     sync.Mutex
+		totalStored    int
+		processedCount int
+		completed      bool
+		done           = make(map[yearMonth]bool, totalMonths)
+	)
+
+	// Record the run; if we return without completing (cancelled, or a panic)
+	// it is marked interrupted, and the months it didn't get through are
+	// recorded as remaining so the next run resumes them. Months finish out of
+	// order, so that can't be left to the next incremental start date.
+	runID := startSyncRun(db, totalMonths)
+	recordSyncRange(db, runID, months, monthRange{start: start, end: end}, remaining)
+	defer func() {
+		progressMu.Lock()
+		defer progressMu.Unlock()
+		if !completed {
+			owed := owedRange(syncMonths, done, remaining)
+			recordSyncRange(db, runID, months, monthRange{start: start, end: end}, owed)
+			if owed != nil {
+				GlobalSyncState.SetRemainingMonths(len(monthsBetween(owed.start, owed.end)))
+			}
+		}
+		finishSyncRun(db, runID, processedCount, totalStored, !completed)
+	}()
+
 	// Convert yearMonth to fetcher.MonthDownload
-	downloads := make([]fetcher.MonthDownload, len(months))
-	for i, ym := range months {
+	downloads := make([]fetcher.MonthDownload, len(syncMonths))
+	for i, ym := range syncMonths {
 		downloads[i] = fetcher.MonthDownload{Year: ym.year, Month: ym.month}
 	}
+	if cfg.SyncOrder == config.SyncOrderNewest {
+		for i, j := 0, len(downloads)-1; i < j; i, j = i+1, j-1 {
+			downloads[i], downloads[j] = downloads[j], downloads[i]
+		}
+	}
 
 	// Download all months in parallel (3-4 workers)
 	const concurrentDownloads = 4
-	log.Printf("Starting parallel download with %d workers", concurrentDownloads)
+	slog.Debug("Starting parallel download", "workers", concurrentDownloads)
 
 	// In dev mode, skip download if file exists; in production, always download fresh
 	skipIfExists := cfg.ENV == "development"
 	if skipIfExists {
-		log.Println("Dev mode: Using cached mbox files if available")
+		slog.Info("Dev mode: Using cached mbox files if available")
 	} else {
-		log.Println("Production mode: Downloading fresh mbox files")
+		slog.Info("Production mode: Downloading fresh mbox files")
 	}
 
-	downloadResults := fetcher.DownloadMonthsConcurrent(cfg.DataDir, cfg.ArchiveUsername, cfg.ArchivePassword, downloads, concurrentDownloads, skipIfExists)
+	// Parse and store each month as soon as its download finishes, so parsing
+	// overlaps with the remaining downloads
+	downloadResults := fetcher.DownloadMonthsStream(ctx, cfg.DataDir, cfg.ArchiveUsername, cfg.ArchivePassword, downloads, concurrentDownloads, skipIfExists)
+
+	// Parsing is CPU-bound and runs in parallel; DB writes are bounded separately
+	// by storeSlots so a sync can't take over the connection pool
+	storeSlots := make(chan struct{}, storeConcurrency(cfg))
+	parseWorkers := max(2, cap(storeSlots))
+	mboxParser := newMboxParser(cfg)
+	var wg sync.WaitGroup
+	syncStart := time.Now()
+
+	for i := 0; i < parseWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					slog.Error("PANIC in sync parse worker", "panic", r)
+				}
+			}()
+
+			for result := range downloadResults {
+				if ctx.Err() != nil {
+					// Drain what was already downloaded without storing it
+					continue
+				}
+				currentMonth := fmt.Sprintf("%04d-%02d", result.Year, result.Month)
+				progressMu.Lock()
+				processedCount++
+				GlobalSyncState.Update(processedCount, totalMonths, currentMonth)
+				progressMu.Unlock()
+
+				n, latest := syncMonth(ctx, db, cfg, mboxParser, result, storeSlots)
+
+				progressMu.Lock()
+				totalStored += n
+				if ctx.Err() == nil {
+					done[yearMonth{year: result.Year, month: result.Month}] = true
+				}
+				if !latest.IsZero() {
+					GlobalSyncState.SetLatestMessageDate(latest)
+				}
+				progressMu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		slog.Info("Mbox sync cancelled", "months_processed", processedCount, "stored", totalStored, "duration", time.Since(syncStart))
+		return
+	}
+	completed = true
 
-	// Process downloads and parse mbox files
-	log.Printf("Received %d download results", len(downloadResults))
-	mboxParser := parser.NewMboxParser(cfg.DataDir)
-	var totalStored int
-	processedCount := 0
+	GlobalSyncState.Update(totalMonths, totalMonths, "")
+	slog.Info("Mbox sync completed", "stored", totalStored, "duration", time.Since(syncStart))
+}
 
-	for _, result := range downloadResults {
-		processedCount++
-		currentMonth := fmt.Sprintf("%04d-%02d", result.Year, result.Month)
-		GlobalSyncState.Update(processedCount, totalMonths, currentMonth)
+// storeConcurrency is how many months a sync stores at once: STORE_CONCURRENCY,
+// capped so at least one pooled connection stays free for API requests. Each
+// store uses two connections: one holding its thread lock, one for queries.
+func storeConcurrency(cfg *config.Config) int {
+	n := cfg.StoreConcurrency
+	if limit := (cfg.DBMaxOpenConns - 1) / 2; n > limit {
+		slog.Warn("STORE_CONCURRENCY exceeds the connection pool, lowering it",
+			"store_concurrency", n, "db_max_open_conns", cfg.DBMaxOpenConns)
+		n = limit
+	}
+	return max(n, 1)
+}
 
-		if result.Error != nil {
-			log.Printf("Skip month %04d-%02d: %v", result.Year, result.Month, result.Error)
-			continue
-		}
+// syncMonth streams one downloaded month through the parser and stores its
+// messages in batches of SYNC_BATCH_SIZE, holding a storeSlots slot only for
+// each DB write, so a month is never held in memory whole. A cancelled ctx
+// stops it between batches. Returns the number of messages stored and the date
+// of the month's last message (zero if nothing was stored).
+func syncMonth(ctx context.Context, db *sql.DB, cfg *config.Config, mboxParser *parser.MboxParser, result fetcher.MonthResult, storeSlots chan struct{}) (int, time.Time) {
+	currentMonth := fmt.Sprintf("%04d-%02d", result.Year, result.Month)
+	if result.Error != nil {
+		slog.Warn("Skip month", "month", currentMonth, "error", result.Error)
+		return 0, time.Time{}
+	}
 
-		log.Printf("Processing %04d-%02d from %s (took %v)", result.Year, result.Month, result.Path, result.Duration)
+	slog.Info("Processing month", "month", currentMonth, "path", result.Path, "download_duration", result.Duration)
 
-		messages, stats, err := mboxParser.ParseMboxFile(result.Path)
-		if err != nil {
-			log.Printf("Error parsing %s: %v", result.Path, err)
-			continue
-		}
-		if stats != nil {
-			log.Printf("Parse stats for %04d-%02d: %d total, %d parsed (%.1f%%), %d skipped",
-				result.Year, result.Month, stats.Total, stats.Parsed,
-				float64(stats.Parsed)/float64(stats.Total)*100, stats.Skipped)
-		}
-		log.Printf("Parsed %d messages from %s", len(messages), result.Path)
-		if len(messages) == 0 {
-			log.Printf("No messages in %s, skipping", result.Path)
-			continue
-		}
-		log.Printf("Storing %d messages in database", len(messages))
-		n := storeMessagesInDB(db, messages)
-		totalStored += n
-		log.Printf("Stored %d new messages (total so far: %d)", n, totalStored)
-
-		// In production mode, cleanup (delete) mbox file after successful ingestion
-		if cfg.CleanupMboxFiles {
-			if err := os.Remove(result.Path); err != nil {
-				log.Printf("Warning: Failed to cleanup mbox file %s: %v", result.Path, err)
-			} else {
-				log.Printf("Cleaned up mbox file: %s", result.Path)
-			}
+	var n, parsed int
+	var latest time.Time
+	batch := make([]*models.Message, 0, cfg.SyncBatchSize)
+	store := func() {
+		if len(batch) == 0 {
+			return
 		}
+		slog.Debug("Storing messages in database", "month", currentMonth, "count", len(batch))
+		storeSlots <- struct{}{}
+		n += storeMessagesInDB(db, cfg, batch)
+		<-storeSlots
+		latest = batch[len(batch)-1].CreatedAt
+		parsed += len(batch)
+		batch = batch[:0]
+	}
 
-		// Update latest message date
-		if len(messages) > 0 {
-			latestMsg := messages[len(messages)-1]
-			GlobalSyncState.SetLatestMessageDate(latestMsg.CreatedAt)
+	_, err := mboxParser.ParseMboxFileStream(result.Path, func(msg *models.Message) error {
+		batch = append(batch, msg)
+		if len(batch) >= cfg.SyncBatchSize {
+			store()
+		}
+		return ctx.Err()
+	})
+	if errors.Is(err, context.Canceled) {
+		slog.Info("Month sync cancelled", "month", currentMonth, "stored", n)
+		return n, latest
+	}
+	if err != nil {
+		// Batches already stored stay stored; the rest of the month is skipped
+		slog.Error("Error parsing mbox file", "file", result.Path, "error", err)
+		return n, latest
+	}
+	store()
+	if parsed == 0 {
+		slog.Info("No messages in file, skipping", "file", result.Path)
+		return 0, time.Time{}
+	}
+	slog.Info("Stored new messages", "month", currentMonth, "stored", n)
+
+	// In production mode, cleanup (delete) mbox file after successful ingestion
+	if cfg.CleanupMboxFiles {
+		if err := os.Remove(result.Path); err != nil {
+			slog.Warn("Failed to cleanup mbox file", "file", result.Path, "error", err)
+		} else {
+			slog.Debug("Cleaned up mbox file", "file", result.Path)
 		}
 	}
 
-	GlobalSyncState.Update(totalMonths, totalMonths, "")
-	log.Printf("Mbox sync completed: %d new messages stored", totalStored)
+	return n, latest
+}
+
+// newMboxParser creates an mbox parser configured from cfg
+func newMboxParser(cfg *config.Config) *parser.MboxParser {
+	return newMboxParserIn(cfg, cfg.DataDir)
+}
+
+// newMboxParserIn is newMboxParser reading from dir instead of DataDir
+func newMboxParserIn(cfg *config.Config, dir string) *parser.MboxParser {
+	mboxParser := parser.NewMboxParser(dir)
+	mboxParser.KeepHTML = cfg.StoreHTMLBody
+	mboxParser.MaxBodyBytes = cfg.MaxBodyBytes
+	// The pattern is validated at startup, so an error here can't happen
+	mboxParser.Separator, _ = parser.CompileSeparator(cfg.MboxSeparatorRegex)
+	return mboxParser
 }
 
 // yearMonth is a (year, month) pair for sync range.
 type yearMonth struct{ year, month int }
 
+func (ym yearMonth) time() time.Time {
+	return time.Date(ym.year, time.Month(ym.month), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// capSyncMonths keeps at most limit of the months (all when limit is 0), the
+// oldest or the newest ones, and returns the range left for a later run
+// (nil when nothing was cut). Kept months stay in order, oldest first.
+func capSyncMonths(months []yearMonth, limit int, newestFirst bool) ([]yearMonth, *monthRange) {
+	if limit <= 0 || len(months) <= limit {
+		return months, nil
+	}
+	if newestFirst {
+		cut := len(months) - limit
+		return months[cut:], &monthRange{start: months[0].time(), end: months[cut-1].time()}
+	}
+	return months[:limit], &monthRange{start: months[limit].time(), end: months[len(months)-1].time()}
+}
+
 // monthsBetween returns (year, month) from start through end inclusive, month-by-month.
 func monthsBetween(start, end time.Time) []yearMonth {
 	var out []yearMonth
@@ -669,34 +1811,99 @@ func monthsBetween(start, end time.Time) []yearMonth {
 	return out
 }
 
-// sanitizeUTF8 removes invalid UTF-8 sequences and replaces them with replacement character
+// Values for INVALID_UTF8
+const (
+	InvalidUTF8Drop    = "drop"
+	InvalidUTF8Replace = "replace"
+)
+
+// invalidUTF8Replacement stands in for each run of invalid UTF-8 bytes:
+// empty drops them, U+FFFD leaves a visible mark that something was lost
+var invalidUTF8Replacement = ""
+
+// SetInvalidUTF8Mode chooses whether sanitizeUTF8 drops invalid bytes or
+// replaces them with U+FFFD
+func SetInvalidUTF8Mode(mode string) error {
+	switch mode {
+	case InvalidUTF8Drop:
+		invalidUTF8Replacement = ""
+	case InvalidUTF8Replace:
+		invalidUTF8Replacement = "\uFFFD"
+	default:
+		return fmt.Errorf("want %q or %q, got %q", InvalidUTF8Drop, InvalidUTF8Replace, mode)
+	}
+	return nil
+}
+
+// sanitizeUTF8 drops or replaces invalid UTF-8 sequences per INVALID_UTF8
 func sanitizeUTF8(s string) string {
-	if utf8.ValidString(s) {
-		return s
-	}
-	// Build a new string with only valid UTF-8
-	var b strings.Builder
-	b.Grow(len(s))
-	for i := 0; i < len(s); {
-		r, size := utf8.DecodeRuneInString(s[i:])
-		if r == utf8.RuneError && size == 1 {
-			// Invalid UTF-8 byte, skip it
-			i++
-			continue
+	return strings.ToValidUTF8(s, invalidUTF8Replacement)
+}
+
+// Values for EMPTY_SUBJECT_FALLBACK
+const (
+	SubjectFallbackSnippet     = "snippet"
+	SubjectFallbackPlaceholder = "placeholder"
+)
+
+// noSubject titles a thread none of whose messages has a subject
+const noSubject = "(no subject)"
+
+// subjectSnippetRunes caps the body snippet used as a fallback title
+const subjectSnippetRunes = 80
+
+// threadSubject titles a thread from its time-sorted messages: the first
+// non-empty subject, else (with the snippet fallback) the root's first line
+// of prose, else "(no subject)"
+func threadSubject(msgs []*models.Message, fallback string) string {
+	for _, msg := range msgs {
+		if subject := strings.TrimSpace(msg.Subject); subject != "" {
+			return subject
+		}
+	}
+	if fallback == SubjectFallbackSnippet && len(msgs) > 0 {
+		for _, line := range strings.Split(msgs[0].Body, "\n") {
+			line = strings.Join(strings.Fields(line), " ")
+			if line == "" || strings.HasPrefix(line, ">") {
+				continue
+			}
+			if runes := []rune(line); len(runes) > subjectSnippetRunes {
+				line = strings.TrimSpace(string(runes[:subjectSnippetRunes])) + "…"
+			}
+			return line
 		}
-		b.WriteRune(r)
-		i += size
 	}
-	return b.String()
+	return noSubject
 }
 
 // storeMessagesInDB groups messages by thread using In-Reply-To/References headers,
 // merges into existing threads by message-id when present, and returns the number of messages newly inserted.
-func storeMessagesInDB(db *sql.DB, messages []*models.Message) int {
-	threads := groupByThread(messages)
-	threadAnalyzer := analyzer.NewThreadAnalyzer(db)
+func storeMessagesInDB(db *sql.DB, cfg *config.Config, messages []*models.Message) int {
+	resolveOutlookParents(messages, storedConversations(db, messages))
+	threads := groupByThread(messages, cfg.ThreadMaxDepth)
+	threadAnalyzer := newThreadAnalyzer(db, cfg)
 	var inserted int
 
+	// Only threads touched by this batch need their stats and status refreshed:
+	// the threads messages land in, plus any thread that currently owns one of
+	// these messages and may lose it to re-threading
+	touched := make(map[string]bool)
+	batchIDs := make([]string, 0, len(messages))
+	for _, msg := range messages {
+		batchIDs = append(batchIDs, sanitizeUTF8(msg.MessageID))
+	}
+	if rows, err := db.Query("SELECT DISTINCT thread_id FROM messages WHERE message_id = ANY($1)", pq.Array(batchIDs)); err == nil {
+		for rows.Next() {
+			var id string
+			if rows.Scan(&id) == nil {
+				touched[id] = true
+			}
+		}
+		rows.Close()
+	} else {
+		slog.Warn("Error looking up existing threads for batch", "error", err)
+	}
+
 	for rootMessageID, msgs := range threads {
 		if len(msgs) == 0 {
 			continue
@@ -714,17 +1921,23 @@ func storeMessagesInDB(db *sql.DB, messages []*models.Message) int {
 		`, rootMessageID).Scan(&threadID)
 
 		if err != nil && err != sql.ErrNoRows {
-			log.Printf("Error looking up thread by message-id: %v", err)
+			slog.Error("Error looking up thread by message-id", "message_id", rootMessageID, "error", err)
 			continue
 		}
 
-		// If thread doesn't exist by root message-id, check if any message in this thread
-		// already exists and get its thread_id (handles missing intermediate messages)
+		// If thread doesn't exist by root message-id, check if the root or any
+		// message in this thread is already stored and get its thread_id (handles
+		// missing intermediate messages, and replies to a message stored by an
+		// earlier batch of the same sync)
 		if err == sql.ErrNoRows {
+			ids := []string{rootMessageID}
 			for _, msg := range msgs {
+				ids = append(ids, msg.MessageID)
+			}
+			for _, id := range ids {
 				err = db.QueryRow(`
 					SELECT thread_id FROM messages WHERE message_id = $1 LIMIT 1
-				`, msg.MessageID).Scan(&threadID)
+				`, id).Scan(&threadID)
 				if err == nil {
 					// Found an existing message, use its thread
 					break
@@ -735,22 +1948,44 @@ func storeMessagesInDB(db *sql.DB, messages []*models.Message) int {
 		// If still no thread found, create a new one
 		if threadID == "" {
 			threadID = uuid.New().String()
-			sanitizedSubject := sanitizeUTF8(firstMsg.Subject)
+			sanitizedSubject := sanitizeUTF8(threadSubject(msgs, cfg.EmptySubjectFallback))
 			sanitizedMessageID := sanitizeUTF8(rootMessageID)
 			sanitizedAuthor := sanitizeUTF8(firstMsg.Author)
 			sanitizedAuthorEmail := sanitizeUTF8(firstMsg.AuthorEmail)
 
-			_, err = db.Exec(`
+			// A concurrent ingest may have created the thread for this root since the
+			// lookup above; the no-op update lets RETURNING hand back its canonical id
+			err = db.QueryRow(`
 				INSERT INTO threads (id, subject, first_message_id, first_author, first_author_email, created_at, last_message_at)
 				VALUES ($1, $2, $3, $4, $5, $6, $7)
-				ON CONFLICT (id) DO NOTHING
-			`, threadID, sanitizedSubject, sanitizedMessageID, sanitizedAuthor, sanitizedAuthorEmail, firstMsg.CreatedAt, firstMsg.CreatedAt)
+				ON CONFLICT (first_message_id) DO UPDATE SET first_message_id = EXCLUDED.first_message_id
+				RETURNING id
+			`, threadID, sanitizedSubject, sanitizedMessageID, sanitizedAuthor, sanitizedAuthorEmail, firstMsg.CreatedAt, firstMsg.CreatedAt).Scan(&threadID)
 			if err != nil {
-				log.Printf("Error inserting thread: %v", err)
+				slog.Error("Error inserting thread", "message_id", rootMessageID, "error", err)
 				continue
 			}
 		}
 
+		touched[threadID] = true
+
+		// Hold the thread for the rest of its update, so a month stored in
+		// parallel can't interleave its own messages and derived fields
+		unlock, err := lockThreads(db, threadID)
+		if err != nil {
+			slog.Error("Error locking thread", "thread_id", threadID, "error", err)
+			continue
+		}
+
+		// A thread created without a usable subject picks one up from this batch, as
+		// does one stored with raw RFC 2047 encoded-words before they were decoded
+		db.Exec(`UPDATE threads SET subject = $1 WHERE id = $2 AND (TRIM(subject) = '' OR subject ~ '=\?[^?]+\?[BbQq]\?')`,
+			sanitizeUTF8(threadSubject(msgs, cfg.EmptySubjectFallback)), threadID)
+
+		// Remember the status before this batch so a dormant thread coming back can be noticed
+		var priorStatus string
+		db.QueryRow("SELECT status FROM threads WHERE id = $1", threadID).Scan(&priorStatus)
+
 		for _, msg := range msgs {
 			msg.ID = uuid.New().String()
 			msg.ThreadID = threadID
@@ -760,58 +1995,256 @@ func storeMessagesInDB(db *sql.DB, messages []*models.Message) int {
 			msg.Author = sanitizeUTF8(msg.Author)
 			msg.AuthorEmail = sanitizeUTF8(msg.AuthorEmail)
 			msg.Body = sanitizeUTF8(msg.Body)
+			msg.BodyHTML = sanitizeUTF8(msg.BodyHTML)
 			msg.MessageID = sanitizeUTF8(msg.MessageID)
 			msg.InReplyTo = sanitizeUTF8(msg.InReplyTo)
 			msg.RefersTo = sanitizeUTF8(msg.RefersTo)
 
+			// Only the message-ids matter, so the raw References header is stored in
+			// compact form; grouping above already used the full header
+			refIDs := referenceIDs(msg)
+			msg.HasBenchmarks = threadAnalyzer.Benchmarks.HasBenchmarks(msg.Body)
+			msg.RefersTo = compactReferences(msg.RefersTo, cfg.MaxStoredReferences)
+			msg.Supersedes = sanitizeUTF8(msg.Supersedes)
+			msg.Sender = sanitizeUTF8(msg.Sender)
+			msg.ReplyTo = sanitizeUTF8(msg.ReplyTo)
+			conversation, _ := parser.ThreadConversation(msg.ThreadIndex)
+
 			result, err := db.Exec(`
-				INSERT INTO messages (id, thread_id, message_id, in_reply_to, refers_to, subject, author, author_email, body, created_at, has_patch, patch_status, commitfest_id)
-				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
-				ON CONFLICT (message_id) DO UPDATE SET thread_id = EXCLUDED.thread_id, in_reply_to = EXCLUDED.in_reply_to, refers_to = EXCLUDED.refers_to, has_patch = EXCLUDED.has_patch, patch_status = EXCLUDED.patch_status, commitfest_id = EXCLUDED.commitfest_id
-			`, msg.ID, msg.ThreadID, msg.MessageID, msg.InReplyTo, msg.RefersTo, msg.Subject, msg.Author, msg.AuthorEmail, msg.Body, msg.CreatedAt, msg.HasPatch, msg.PatchStatus, msg.CommitFestID)
+				INSERT INTO messages (id, thread_id, message_id, in_reply_to, refers_to, subject, author, author_email, body, body_html, created_at, has_patch, patch_status, commitfest_id, size_bytes, empty_body, decode_warning, supersedes, attachments, reference_ids, archived_at, has_benchmarks, tz_offset_minutes, sender, reply_to, lists, thread_conversation)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27)
+				ON CONFLICT (message_id) DO UPDATE SET thread_id = EXCLUDED.thread_id, subject = EXCLUDED.subject, author = EXCLUDED.author, author_email = EXCLUDED.author_email, in_reply_to = EXCLUDED.in_reply_to, refers_to = EXCLUDED.refers_to, body_html = EXCLUDED.body_html, has_patch = EXCLUDED.has_patch, patch_status = EXCLUDED.patch_status, commitfest_id = EXCLUDED.commitfest_id, size_bytes = EXCLUDED.size_bytes, empty_body = EXCLUDED.empty_body, decode_warning = EXCLUDED.decode_warning, supersedes = EXCLUDED.supersedes, attachments = EXCLUDED.attachments, reference_ids = EXCLUDED.reference_ids, archived_at = EXCLUDED.archived_at, has_benchmarks = EXCLUDED.has_benchmarks, tz_offset_minutes = EXCLUDED.tz_offset_minutes, sender = EXCLUDED.sender, reply_to = EXCLUDED.reply_to, thread_conversation = EXCLUDED.thread_conversation,
+				    lists = ARRAY(SELECT DISTINCT l FROM unnest(COALESCE(messages.lists, '{}') || EXCLUDED.lists) AS l ORDER BY l)
+			`, msg.ID, msg.ThreadID, msg.MessageID, msg.InReplyTo, msg.RefersTo, msg.Subject, msg.Author, msg.AuthorEmail, msg.Body, msg.BodyHTML, msg.CreatedAt, msg.HasPatch, msg.PatchStatus, msg.CommitFestID, msg.SizeBytes, msg.EmptyBody, msg.DecodeWarning, msg.Supersedes, pq.Array(msg.Attachments), pq.Array(refIDs), msg.ArchiveURL, msg.HasBenchmarks, msg.TZOffsetMinutes, msg.Sender, msg.ReplyTo, pq.Array(uniqueStrings(msg.Lists)), conversation)
 			if err != nil {
-				log.Printf("Error inserting message: %v", err)
+				slog.Warn("Error inserting message", "message_id", msg.MessageID, "error", err)
 				continue
 			}
 			rows, _ := result.RowsAffected()
 			inserted += int(rows)
 		}
 
+		if err := threadAnalyzer.MarkSuperseded(threadID); err != nil {
+			slog.Warn("Error marking superseded messages", "thread_id", threadID, "error", err)
+		}
 		if err := threadAnalyzer.UpdateThreadActivity(threadID); err != nil {
-			log.Printf("Error updating thread activity: %v", err)
+			slog.Warn("Error updating thread activity", "thread_id", threadID, "error", err)
 		}
 		status, err := threadAnalyzer.ClassifyThread(threadID)
 		if err == nil {
 			db.Exec("UPDATE threads SET status = $1 WHERE id = $2", status, threadID)
+			if analyzer.IsRevival(priorStatus, status) {
+				db.Exec("UPDATE threads SET revived_at = last_message_at WHERE id = $1", threadID)
+				slog.Info("Dormant thread revived", "thread_id", threadID, "from", priorStatus, "to", status)
+			}
+		}
+		if hash, err := threadAnalyzer.ExtractCommitHash(threadID); err == nil && hash != "" {
+			db.Exec("UPDATE threads SET commit_hash = $1 WHERE id = $2", hash, threadID)
+		}
+		refreshPatchState(db, threadAnalyzer, threadID)
+		unlock()
+	}
+
+	touchedIDs := make([]string, 0, len(touched))
+	for id := range touched {
+		touchedIDs = append(touchedIDs, id)
+	}
+	if unlock, err := lockThreads(db, touchedIDs...); err == nil {
+		refreshThreads(db, threadAnalyzer, touchedIDs)
+		unlock()
+	} else {
+		slog.Error("Error locking threads to refresh", "error", err)
+	}
+	return inserted
+}
+
+// refreshPatchState recomputes the thread fields derived from its messages'
+// patches: maturity, needs_author_action, ready_for_committer and the patch series
+func refreshPatchState(db *sql.DB, threadAnalyzer *analyzer.ThreadAnalyzer, threadID string) {
+	if maturity, err := threadAnalyzer.ClassifyMaturity(threadID); err == nil {
+		db.Exec("UPDATE threads SET maturity = $1 WHERE id = $2", maturity, threadID)
+	}
+	if needsAction, err := threadAnalyzer.NeedsAuthorAction(threadID); err == nil {
+		db.Exec("UPDATE threads SET needs_author_action = $1 WHERE id = $2", needsAction, threadID)
+	}
+	if ready, err := threadAnalyzer.ReadyForCommitter(threadID); err == nil {
+		db.Exec("UPDATE threads SET ready_for_committer = $1 WHERE id = $2", ready, threadID)
+	}
+	if series, err := threadAnalyzer.PatchSeries(threadID); err == nil {
+		if series == nil {
+			series = &models.PatchSeries{}
 		}
+		db.Exec(`
+			UPDATE threads SET series_version = $1, series_parts_present = $2, series_parts_total = $3, series_missing = $4
+			WHERE id = $5
+		`, series.Version, series.Present, series.Total, pq.Array(series.Missing), threadID)
 	}
+}
 
-	// Refresh all thread stats from messages so every thread has correct counts
-	// (fixes duplicates and any thread that lost messages to the canonical one)
+// refreshThreads recomputes stats from messages for the given threads, deletes any
+// left without messages, and reclassifies the rest. A nil ids slice refreshes every thread.
+func refreshThreads(db *sql.DB, threadAnalyzer *analyzer.ThreadAnalyzer, ids []string) {
+	// Recompute stats from messages so counts are correct (fixes duplicates and
+	// any thread that lost messages to the canonical one)
 	_, _ = db.Exec(`
 		UPDATE threads t SET
 			message_count = (SELECT COUNT(*) FROM messages m WHERE m.thread_id = t.id),
-			unique_authors = (SELECT COUNT(DISTINCT author_email) FROM messages m WHERE m.thread_id = t.id),
+			unique_authors = (
+				SELECT COUNT(DISTINCT author_email) FROM messages m
+				WHERE m.thread_id = t.id AND LOWER(m.author_email) <> ALL($2::text[])
+			),
 			last_message_at = (SELECT MAX(created_at) FROM messages m WHERE m.thread_id = t.id),
+			patch_count = (SELECT COUNT(*) FROM messages m WHERE m.thread_id = t.id AND m.has_patch),
+			has_benchmarks = EXISTS (SELECT 1 FROM messages m WHERE m.thread_id = t.id AND m.has_benchmarks),
+			current_patch_status = COALESCE((
+				SELECT m.patch_status FROM messages m
+				WHERE m.thread_id = t.id AND m.patch_status <> ''
+				ORDER BY m.created_at DESC, m.message_id DESC LIMIT 1
+			), ''),
 			updated_at = NOW()
-	`)
+		WHERE $1::text[] IS NULL OR t.id = ANY($1::text[])
+	`, pq.Array(ids), threadAnalyzer.BotSendersArg())
+
+	if err := threadAnalyzer.RefreshHeat(ids); err != nil {
+		slog.Warn("Error refreshing thread heat", "error", err)
+	}
+	// Threads outside the batch cool down once per batch, not once per thread
+	if ids != nil {
+		if err := threadAnalyzer.CoolHeat(); err != nil {
+			slog.Warn("Error cooling thread heat", "error", err)
+		}
+	}
 
 	// Delete threads with no messages (orphaned threads)
-	_, _ = db.Exec(`DELETE FROM threads WHERE message_count = 0`)
+	_, _ = db.Exec(`DELETE FROM threads WHERE message_count = 0 AND ($1::text[] IS NULL OR id = ANY($1::text[]))`, pq.Array(ids))
 
-	// Reclassify all threads so status (in-progress, stalled, etc.) matches updated counts
-	rows, err := db.Query("SELECT id FROM threads")
-	if err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var id string
-			if err := rows.Scan(&id); err != nil {
-				continue
-			}
-			if status, err := threadAnalyzer.ClassifyThread(id); err == nil {
-				db.Exec("UPDATE threads SET status = $1 WHERE id = $2", status, id)
-			}
+	// Reclassify so status (in-progress, stalled, etc.) matches updated counts
+	rows, err := db.Query(`SELECT id FROM threads WHERE $1::text[] IS NULL OR id = ANY($1::text[])`, pq.Array(ids))
+	if err != nil {
+		slog.Error("Error listing threads to reclassify", "error", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			continue
+		}
+		if status, err := threadAnalyzer.ClassifyThread(id); err == nil {
+			db.Exec("UPDATE threads SET status = $1 WHERE id = $2", status, id)
+		}
+		if kind, err := threadAnalyzer.ClassifyKind(id); err == nil {
+			db.Exec("UPDATE threads SET kind = $1 WHERE id = $2", kind, id)
+		}
+		if err := threadAnalyzer.RecordPatchHistory(id); err != nil {
+			slog.Warn("Error recording patch history", "thread_id", id, "error", err)
+		}
+		if forkedFrom, err := threadAnalyzer.ForkedFrom(id); err == nil {
+			db.Exec("UPDATE threads SET forked_from = NULLIF($1, '') WHERE id = $2", forkedFrom, id)
+		}
+		if consensus, err := threadAnalyzer.ReachedConsensus(id); err == nil {
+			db.Exec("UPDATE threads SET consensus = $1 WHERE id = $2", consensus, id)
 		}
 	}
-	return inserted
+}
+
+// newThreadAnalyzer returns a ThreadAnalyzer using the configured staleness
+// anchor, bot senders, announcement and benchmark patterns. The patterns are validated at
+// startup, so an error here can't happen in practice; the defaults are kept if it does.
+func newThreadAnalyzer(db *sql.DB, cfg *config.Config) *analyzer.ThreadAnalyzer {
+	ta := analyzer.NewThreadAnalyzer(db)
+	ta.IgnoreAuthorBumps = cfg.IgnoreAuthorBumps
+	ta.BotSenders = cfg.BotSenders
+	ta.HeatDecay = cfg.HeatDecay
+	if d, err := analyzer.NewAnnouncementDetector(cfg.AnnouncementSubjectPatterns, cfg.AnnouncementSenders); err == nil {
+		ta.Announcements = d
+	}
+	if d, err := analyzer.NewBenchmarkDetector(cfg.BenchmarkPatterns); err == nil {
+		ta.Benchmarks = d
+	}
+	if d, err := analyzer.NewConsensusDetector(cfg.ConsensusPatterns); err == nil {
+		ta.Consensus = d
+	}
+	return ta
+}
+
+func reclassifyHandler(db *sql.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		go func() {
+			start := time.Now()
+			refreshThreads(db, newThreadAnalyzer(db, cfg), nil)
+			slog.Info("Full reclassification completed", "duration", time.Since(start))
+		}()
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"status":    "Full reclassification started",
+			"timestamp": time.Now().Format(time.RFC3339),
+		})
+	}
+}
+
+// reclassifyThreadHandler recomputes activity and status for one thread
+// synchronously, returning the new status alongside the metrics it was based on
+func reclassifyThreadHandler(db *sql.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		threadID := mux.Vars(r)["id"]
+
+		var previousStatus string
+		err := db.QueryRow("SELECT status FROM threads WHERE id = $1", threadID).Scan(&previousStatus)
+		if err == sql.ErrNoRows {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Thread not found"})
+			return
+		}
+		if err != nil {
+			log.Printf("Error querying thread: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to reclassify thread"})
+			return
+		}
+
+		threadAnalyzer := newThreadAnalyzer(db, cfg)
+		if err := threadAnalyzer.UpdateThreadActivity(threadID); err != nil {
+			log.Printf("Error updating thread activity: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to reclassify thread"})
+			return
+		}
+		status, err := threadAnalyzer.ClassifyThread(threadID)
+		if err != nil {
+			log.Printf("Error classifying thread: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to reclassify thread"})
+			return
+		}
+		db.Exec("UPDATE threads SET status = $1 WHERE id = $2", status, threadID)
+
+		var activity struct {
+			MessageCount         int  `json:"message_count"`
+			UniqueAuthors        int  `json:"unique_authors"`
+			HasPatch             bool `json:"has_patch"`
+			HasReview            bool `json:"has_review"`
+			DaysSinceLastMessage int  `json:"days_since_last_message"`
+		}
+		err = db.QueryRow(`
+			SELECT message_count, unique_authors, has_patch, has_review, days_since_last_message
+			FROM thread_activities WHERE thread_id = $1
+		`, threadID).Scan(&activity.MessageCount, &activity.UniqueAuthors, &activity.HasPatch, &activity.HasReview, &activity.DaysSinceLastMessage)
+		if err != nil {
+			log.Printf("Error querying thread activity: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to reclassify thread"})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"thread_id":       threadID,
+			"previous_status": previousStatus,
+			"status":          status,
+			"activity":        activity,
+		})
+	}
 }
@@ -0,0 +1,31 @@
+package api
+
+import "testing"
+
+func TestSanitizeUTF8Modes(t *testing.T) {
+	t.Cleanup(func() { SetInvalidUTF8Mode(InvalidUTF8Drop) })
+
+	// "na\xefve" is Latin-1 for "naïve"; a run of bad bytes is one sequence
+	cases := []struct {
+		mode, in, want string
+	}{
+		{InvalidUTF8Drop, "na\xefve", "nave"},
+		{InvalidUTF8Drop, "word\xff\xfeword", "wordword"},
+		{InvalidUTF8Drop, "already valid: naïve", "already valid: naïve"},
+		{InvalidUTF8Replace, "na\xefve", "na�ve"},
+		{InvalidUTF8Replace, "word\xff\xfeword", "word�word"},
+		{InvalidUTF8Replace, "already valid: naïve", "already valid: naïve"},
+	}
+	for _, c := range cases {
+		if err := SetInvalidUTF8Mode(c.mode); err != nil {
+			t.Fatalf("SetInvalidUTF8Mode(%q): %v", c.mode, err)
+		}
+		if got := sanitizeUTF8(c.in); got != c.want {
+			t.Errorf("%s: sanitizeUTF8(%q) = %q, want %q", c.mode, c.in, got, c.want)
+		}
+	}
+
+	if err := SetInvalidUTF8Mode("escape"); err == nil {
+		t.Error("unknown mode accepted")
+	}
+}
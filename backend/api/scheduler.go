@@ -0,0 +1,40 @@
+package api
+
+import (
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/config"
+)
+
+// StartSyncScheduler runs an incremental mbox sync every cfg.SyncInterval.
+// A zero interval disables the scheduler. Ticks that arrive while another
+// sync (manual or scheduled) is running are skipped.
+func StartSyncScheduler(db *sql.DB, cfg *config.Config) {
+	if cfg.SyncInterval <= 0 {
+		slog.Info("Sync scheduler disabled")
+		return
+	}
+
+	slog.Info("Sync scheduler started", "interval", cfg.SyncInterval)
+	go func() {
+		ticker := time.NewTicker(cfg.SyncInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			ctx, ok := GlobalSyncState.TryStartSync()
+			if !ok {
+				slog.Info("Skipping scheduled sync; a sync is already running")
+				continue
+			}
+			slog.Info("Starting scheduled sync")
+			performMboxSync(ctx, db, cfg, nil)
+			// A sync only refreshes threads it touched; cool the rest even when no mail came
+			if err := newThreadAnalyzer(db, cfg).CoolHeat(); err != nil {
+				slog.Warn("Error cooling thread heat", "error", err)
+			}
+			slog.Info("Scheduled sync finished", "next_run", time.Now().Add(cfg.SyncInterval).Format(time.RFC3339))
+		}
+	}()
+}
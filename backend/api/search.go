@@ -0,0 +1,267 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pgsql-analyzer/backend/analyzer"
+	"github.com/pgsql-analyzer/backend/config"
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+// Limits on a single search so one request can't build an arbitrarily large query
+const (
+	maxSearchMessageCriteria = 5
+	maxSearchValues          = 20
+)
+
+// threadCriteria are conditions on the thread row itself. Lists match any of
+// their values; nil booleans are not checked.
+type threadCriteria struct {
+	Status            []string   `json:"status"`
+	Kind              []string   `json:"kind"`
+	Maturity          []string   `json:"maturity"`
+	Labels            []string   `json:"labels"` // thread has every label
+	NeedsAuthorAction *bool      `json:"needs_author_action"`
+	ReadyForCommitter *bool      `json:"ready_for_committer"`
+	HasBenchmarks     *bool      `json:"has_benchmarks"`
+	Consensus         *bool      `json:"consensus"`
+	MinMessages       int        `json:"min_messages"`
+	LastMessageAfter  *time.Time `json:"last_message_after"`
+	LastMessageBefore *time.Time `json:"last_message_before"`
+}
+
+// messageCriteria describe one message the thread must contain; every field
+// given must hold for that same message
+type messageCriteria struct {
+	HasPatch      *bool      `json:"has_patch"`
+	HasBenchmarks *bool      `json:"has_benchmarks"`
+	PatchStatus   []string   `json:"patch_status"`
+	AuthorEmail   string     `json:"author_email"`
+	After         *time.Time `json:"after"`
+	Before        *time.Time `json:"before"`
+}
+
+// threadSearchRequest is the body of POST /api/threads/search. A thread
+// matches when it meets the thread criteria and contains a matching message
+// for each entry in Messages.
+type threadSearchRequest struct {
+	Thread   threadCriteria    `json:"thread"`
+	Messages []messageCriteria `json:"messages"`
+}
+
+// Values accepted for the enumerated criteria
+var (
+	searchKinds         = []string{analyzer.KindThread, analyzer.KindAnnouncement}
+	searchMaturities    = []string{analyzer.MaturityWIP, analyzer.MaturityRFC, analyzer.MaturityReviewReady}
+	searchPatchStatuses = []string{"proposed", "accepted", "committed", "rejected"}
+)
+
+// searchQuery collects conditions and their positional arguments
+type searchQuery struct {
+	conds []string
+	args  []interface{}
+}
+
+// arg registers v and returns its placeholder
+func (q *searchQuery) arg(v interface{}) string {
+	q.args = append(q.args, v)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+// checkValues verifies every value is allowed and the list isn't too long
+func checkValues(field string, values, allowed []string) error {
+	if len(values) > maxSearchValues {
+		return fmt.Errorf("%s accepts at most %d values", field, maxSearchValues)
+	}
+	for _, v := range values {
+		ok := false
+		for _, a := range allowed {
+			if v == a {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return fmt.Errorf("%s: unknown value %q", field, v)
+		}
+	}
+	return nil
+}
+
+// validate rejects unknown enum values and requests over the complexity limits
+func (req *threadSearchRequest) validate() error {
+	statuses := make([]string, 0, len(analyzer.Statuses))
+	for _, s := range analyzer.Statuses {
+		statuses = append(statuses, s.Status)
+	}
+	if err := checkValues("thread.status", req.Thread.Status, statuses); err != nil {
+		return err
+	}
+	if err := checkValues("thread.kind", req.Thread.Kind, searchKinds); err != nil {
+		return err
+	}
+	if err := checkValues("thread.maturity", req.Thread.Maturity, searchMaturities); err != nil {
+		return err
+	}
+	if len(req.Thread.Labels) > maxSearchValues {
+		return fmt.Errorf("thread.labels accepts at most %d values", maxSearchValues)
+	}
+	if req.Thread.MinMessages < 0 {
+		return fmt.Errorf("thread.min_messages must not be negative")
+	}
+	if len(req.Messages) > maxSearchMessageCriteria {
+		return fmt.Errorf("at most %d message criteria are allowed", maxSearchMessageCriteria)
+	}
+	for i, m := range req.Messages {
+		if err := checkValues(fmt.Sprintf("messages[%d].patch_status", i), m.PatchStatus, searchPatchStatuses); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// build turns the request into a WHERE clause over threads
+func (req *threadSearchRequest) build(q *searchQuery) {
+	t := req.Thread
+	if len(t.Status) > 0 {
+		q.conds = append(q.conds, "status = ANY("+q.arg(pq.Array(t.Status))+")")
+	}
+	if len(t.Kind) > 0 {
+		q.conds = append(q.conds, "kind = ANY("+q.arg(pq.Array(t.Kind))+")")
+	}
+	if len(t.Maturity) > 0 {
+		q.conds = append(q.conds, "maturity = ANY("+q.arg(pq.Array(t.Maturity))+")")
+	}
+	if len(t.Labels) > 0 {
+		q.conds = append(q.conds, "(SELECT COUNT(DISTINCT label) FROM thread_labels l WHERE l.thread_id = threads.id AND l.label = ANY("+
+			q.arg(pq.Array(t.Labels))+")) = "+q.arg(len(uniqueStrings(t.Labels))))
+	}
+	if t.NeedsAuthorAction != nil {
+		q.conds = append(q.conds, "needs_author_action = "+q.arg(*t.NeedsAuthorAction))
+	}
+	if t.ReadyForCommitter != nil {
+		q.conds = append(q.conds, "ready_for_committer = "+q.arg(*t.ReadyForCommitter))
+	}
+	if t.HasBenchmarks != nil {
+		q.conds = append(q.conds, "has_benchmarks = "+q.arg(*t.HasBenchmarks))
+	}
+	if t.Consensus != nil {
+		q.conds = append(q.conds, "consensus = "+q.arg(*t.Consensus))
+	}
+	if t.MinMessages > 0 {
+		q.conds = append(q.conds, "message_count >= "+q.arg(t.MinMessages))
+	}
+	if t.LastMessageAfter != nil {
+		q.conds = append(q.conds, "last_message_at > "+q.arg(t.LastMessageAfter.UTC())+"::timestamp")
+	}
+	if t.LastMessageBefore != nil {
+		q.conds = append(q.conds, "last_message_at < "+q.arg(t.LastMessageBefore.UTC())+"::timestamp")
+	}
+
+	for _, m := range req.Messages {
+		mconds := []string{"m.thread_id = threads.id"}
+		if m.HasPatch != nil {
+			mconds = append(mconds, "m.has_patch = "+q.arg(*m.HasPatch))
+		}
+		if m.HasBenchmarks != nil {
+			mconds = append(mconds, "m.has_benchmarks = "+q.arg(*m.HasBenchmarks))
+		}
+		if len(m.PatchStatus) > 0 {
+			mconds = append(mconds, "m.patch_status = ANY("+q.arg(pq.Array(m.PatchStatus))+")")
+		}
+		if email := strings.TrimSpace(m.AuthorEmail); email != "" {
+			mconds = append(mconds, "LOWER(m.author_email) = "+q.arg(strings.ToLower(email)))
+		}
+		if m.After != nil {
+			mconds = append(mconds, "m.created_at > "+q.arg(m.After.UTC())+"::timestamp")
+		}
+		if m.Before != nil {
+			mconds = append(mconds, "m.created_at < "+q.arg(m.Before.UTC())+"::timestamp")
+		}
+		q.conds = append(q.conds, "EXISTS (SELECT 1 FROM messages m WHERE "+strings.Join(mconds, " AND ")+")")
+	}
+}
+
+// uniqueStrings returns values without duplicates, keeping the first occurrence
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// searchThreadsHandler answers structured thread searches combining thread
+// fields with per-message conditions. Pagination and ?sort= work as on /api/threads.
+func searchThreadsHandler(db *sql.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		var req threadSearchRequest
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid search body: " + err.Error()})
+			return
+		}
+		if err := req.validate(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		limit, offset := pagination(r, cfg)
+		setPageHeaders(w, limit, offset)
+
+		q := &searchQuery{conds: []string{"TRUE"}}
+		req.build(q)
+		query := `SELECT ` + threadColumns + ` FROM threads WHERE ` + strings.Join(q.conds, " AND ") +
+			` ORDER BY ` + threadOrderBy(r) + ` LIMIT ` + q.arg(limit) + ` OFFSET ` + q.arg(offset)
+
+		rows, err := db.QueryContext(r.Context(), query, q.args...)
+		if err != nil {
+			if queryTimedOut(w, r, cfg) {
+				return
+			}
+			log.Printf("Error searching threads: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to search threads"})
+			return
+		}
+		defer rows.Close()
+
+		threads := make([]*models.Thread, 0)
+		for rows.Next() {
+			thread, err := scanThread(rows)
+			if err != nil {
+				log.Printf("Error scanning thread: %v", err)
+				continue
+			}
+			flagCommitterAttention(thread, cfg)
+			threads = append(threads, thread)
+		}
+		if err := rows.Err(); err != nil {
+			if queryTimedOut(w, r, cfg) {
+				return
+			}
+			log.Printf("Error reading search results: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to search threads"})
+			return
+		}
+
+		json.NewEncoder(w).Encode(threads)
+	}
+}
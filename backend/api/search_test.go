@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/config"
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+func TestSearchIgnoresAccents(t *testing.T) {
+	database := testDB(t)
+	cfg := config.LoadConfig()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	storeMessagesInDB(database, cfg, []*models.Message{
+		{MessageID: "accented@example.com", Subject: "Café locale collation", Author: "Álvaro Herrera", AuthorEmail: "alvaro@example.com",
+			Body: "accented", CreatedAt: now.Add(-2 * time.Hour)},
+		{MessageID: "plain@example.com", Subject: "cafe benchmark", Author: "Jose Perez", AuthorEmail: "jose@example.com",
+			Body: "plain", CreatedAt: now.Add(-time.Hour)},
+		{MessageID: "other@example.com", Subject: "Unrelated", Author: "Someone", AuthorEmail: "someone@example.com",
+			Body: "other", CreatedAt: now},
+	})
+
+	search := func(param, value string) map[string]bool {
+		threads := decodeThreads(t, func(w *httptest.ResponseRecorder) {
+			getThreadsHandler(database, cfg)(w, httptest.NewRequest("GET", "/api/threads?"+param+"="+url.QueryEscape(value), nil))
+		})
+		found := map[string]bool{}
+		for _, thread := range threads {
+			found[thread.Subject] = true
+		}
+		return found
+	}
+
+	// Accented and unaccented queries, in any case, find both spellings
+	for _, q := range []string{"cafe", "café", "CAFÉ"} {
+		found := search("search", q)
+		if len(found) != 2 || !found["Café locale collation"] || !found["cafe benchmark"] {
+			t.Errorf("search=%s found %v, want both cafe threads", q, found)
+		}
+	}
+
+	for _, q := range []string{"alvaro", "Álvaro", "ÁLVARO"} {
+		found := search("author", q)
+		if len(found) != 1 || !found["Café locale collation"] {
+			t.Errorf("author=%s found %v, want Álvaro's thread", q, found)
+		}
+	}
+	if found := search("author", "josé"); len(found) != 1 || !found["cafe benchmark"] {
+		t.Errorf("author=josé found %v, want Jose's thread", found)
+	}
+}
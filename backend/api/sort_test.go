@@ -0,0 +1,70 @@
+package api
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+func TestSortMessagesByTimeEqualTimestamps(t *testing.T) {
+	at := time.Date(2024, 2, 2, 12, 0, 0, 0, time.UTC)
+	earlier := at.Add(-time.Minute)
+
+	// Equal timestamps order by message-id whatever the input order, so the
+	// thread's root is the same on every sync
+	orders := [][]string{
+		{"c@example.com", "a@example.com", "b@example.com", "early@example.com"},
+		{"b@example.com", "early@example.com", "c@example.com", "a@example.com"},
+		{"early@example.com", "c@example.com", "b@example.com", "a@example.com"},
+	}
+	for _, ids := range orders {
+		msgs := make([]*models.Message, len(ids))
+		for i, id := range ids {
+			msgs[i] = &models.Message{MessageID: id, CreatedAt: at}
+			if id == "early@example.com" {
+				msgs[i].CreatedAt = earlier
+			}
+		}
+		sortMessagesByTime(msgs)
+		var got []string
+		for _, msg := range msgs {
+			got = append(got, msg.MessageID)
+		}
+		want := []string{"early@example.com", "a@example.com", "b@example.com", "c@example.com"}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("input %v sorted to %v, want %v", ids, got, want)
+		}
+	}
+
+	// Duplicates equal in both keep their input order
+	first := &models.Message{MessageID: "dup@example.com", CreatedAt: at, Body: "first"}
+	second := &models.Message{MessageID: "dup@example.com", CreatedAt: at, Body: "second"}
+	msgs := []*models.Message{first, second}
+	sortMessagesByTime(msgs)
+	if msgs[0] != first || msgs[1] != second {
+		t.Error("duplicates were reordered")
+	}
+}
+
+func BenchmarkSortMessagesByTime10k(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	orig := make([]*models.Message, 10000)
+	for i := range orig {
+		// Minute resolution, so plenty of timestamps collide
+		orig[i] = &models.Message{
+			MessageID: fmt.Sprintf("msg.%d@example.com", rng.Int()),
+			CreatedAt: start.Add(time.Duration(rng.Intn(5000)) * time.Minute),
+		}
+	}
+	msgs := make([]*models.Message, len(orig))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		copy(msgs, orig)
+		sortMessagesByTime(msgs)
+	}
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/config"
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+func TestConcurrentIngestsShareOneThreadPerRoot(t *testing.T) {
+	database := testDB(t)
+	cfg := config.LoadConfig()
+
+	// Each ingest holds a reply to the same root, which neither has stored, so
+	// every one of them tries to create the thread
+	const ingests = 8
+	start := time.Date(2024, 2, 2, 12, 0, 0, 0, time.UTC)
+	var wg sync.WaitGroup
+	ready := make(chan struct{})
+	for i := 0; i < ingests; i++ {
+		reply := &models.Message{
+			MessageID:   fmt.Sprintf("reply.%d@example.com", i),
+			InReplyTo:   "missing-root@example.com",
+			RefersTo:    "<missing-root@example.com>",
+			Subject:     "Re: concurrent ingest",
+			Author:      "Bob",
+			AuthorEmail: "bob@example.com",
+			Body:        "reply",
+			CreatedAt:   start.Add(time.Duration(i) * time.Minute),
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-ready
+			storeMessagesInDB(database, cfg, []*models.Message{reply})
+		}()
+	}
+	close(ready)
+	wg.Wait()
+
+	var threads, roots, messages int
+	err := database.QueryRow(`
+		SELECT COUNT(*), COUNT(DISTINCT first_message_id), (SELECT COUNT(DISTINCT thread_id) FROM messages)
+		FROM threads
+	`).Scan(&threads, &roots, &messages)
+	if err != nil {
+		t.Fatalf("query threads: %v", err)
+	}
+	if threads != 1 || roots != 1 || messages != 1 {
+		t.Errorf("got %d threads (%d roots) holding messages in %d threads, want everything in one", threads, roots, messages)
+	}
+	var count int
+	database.QueryRow("SELECT message_count FROM threads").Scan(&count)
+	if count != ingests {
+		t.Errorf("message_count = %d, want %d", count, ingests)
+	}
+}
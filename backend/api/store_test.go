@@ -0,0 +1,94 @@
+package api
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/config"
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+func TestStoreConcurrencyCap(t *testing.T) {
+	cases := []struct{ store, conns, want int }{
+		{store: 1, conns: 10, want: 1},
+		{store: 4, conns: 10, want: 4},
+		{store: 8, conns: 10, want: 4},
+		{store: 4, conns: 2, want: 1},
+		{store: 0, conns: 10, want: 1},
+	}
+	for _, c := range cases {
+		got := storeConcurrency(&config.Config{StoreConcurrency: c.store, DBMaxOpenConns: c.conns})
+		if got != c.want {
+			t.Errorf("STORE_CONCURRENCY=%d, DB_MAX_OPEN_CONNS=%d: got %d, want %d", c.store, c.conns, got, c.want)
+		}
+	}
+}
+
+func TestConcurrentStoresIntoOneThread(t *testing.T) {
+	database := testDB(t)
+	cfg := config.LoadConfig()
+	cfg.StoreConcurrency = 4
+	cfg.DBMaxOpenConns = 10
+	slots := storeConcurrency(cfg)
+	if slots < 2 {
+		t.Fatalf("storeConcurrency = %d, want stores to run in parallel", slots)
+	}
+
+	// One long thread split into batches, as months stored in parallel would be
+	const batches, perBatch = 8, 25
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	root := &models.Message{MessageID: "root@example.com", Subject: "[PATCH] concurrent stores", Author: "Alice", AuthorEmail: "alice@example.com", Body: "root", CreatedAt: start}
+	work := make([][]*models.Message, batches)
+	for b := range work {
+		for i := 0; i < perBatch; i++ {
+			n := b*perBatch + i
+			work[b] = append(work[b], &models.Message{
+				MessageID:   fmt.Sprintf("reply.%d@example.com", n),
+				InReplyTo:   root.MessageID,
+				RefersTo:    "<" + root.MessageID + ">",
+				Subject:     "Re: [PATCH] concurrent stores",
+				Author:      fmt.Sprintf("Author %d", n%5),
+				AuthorEmail: fmt.Sprintf("author%d@example.com", n%5),
+				Body:        fmt.Sprintf("reply %d", n),
+				CreatedAt:   start.Add(time.Duration(n+1) * time.Minute),
+			})
+		}
+	}
+	if n := storeMessagesInDB(database, cfg, []*models.Message{root}); n != 1 {
+		t.Fatalf("stored root: %d inserted", n)
+	}
+
+	var wg sync.WaitGroup
+	inserted := make([]int, batches)
+	sem := make(chan struct{}, slots)
+	for b := range work {
+		wg.Add(1)
+		go func(b int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			inserted[b] = storeMessagesInDB(database, cfg, work[b])
+		}(b)
+	}
+	wg.Wait()
+
+	for b, n := range inserted {
+		if n != perBatch {
+			t.Errorf("batch %d inserted %d messages, want %d", b, n, perBatch)
+		}
+	}
+	var threads, messageCount, uniqueAuthors int
+	err := database.QueryRow(`SELECT COUNT(*), COALESCE(SUM(message_count), 0), COALESCE(MAX(unique_authors), 0) FROM threads`).
+		Scan(&threads, &messageCount, &uniqueAuthors)
+	if err != nil {
+		t.Fatalf("query threads: %v", err)
+	}
+	if want := batches*perBatch + 1; threads != 1 || messageCount != want {
+		t.Errorf("got %d threads holding %d messages, want 1 thread of %d", threads, messageCount, want)
+	}
+	if uniqueAuthors != 6 {
+		t.Errorf("unique_authors = %d, want 6", uniqueAuthors)
+	}
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/config"
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+func TestThreadSubject(t *testing.T) {
+	subjectless := []*models.Message{
+		{Subject: "", Body: "> quoted from elsewhere\n\n  Proposal:   drop the   old syntax\nmore text"},
+		{Subject: "   ", Body: "agreed"},
+	}
+	long := []*models.Message{{Body: strings.Repeat("word ", 40)}}
+
+	cases := []struct {
+		name     string
+		msgs     []*models.Message
+		fallback string
+		want     string
+	}{
+		{"first non-empty subject", []*models.Message{{Subject: " "}, {Subject: "Re: idea"}}, SubjectFallbackSnippet, "Re: idea"},
+		{"snippet skips quotes and blank lines", subjectless, SubjectFallbackSnippet, "Proposal: drop the old syntax"},
+		{"placeholder", subjectless, SubjectFallbackPlaceholder, noSubject},
+		{"body with no prose", []*models.Message{{Body: "> only a quote\n\n"}}, SubjectFallbackSnippet, noSubject},
+		{"long snippet capped", long, SubjectFallbackSnippet, strings.TrimSpace(strings.Repeat("word ", 16)) + "…"},
+		{"no messages", nil, SubjectFallbackSnippet, noSubject},
+	}
+	for _, c := range cases {
+		if got := threadSubject(c.msgs, c.fallback); got != c.want {
+			t.Errorf("%s: threadSubject = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestSubjectlessThreadTitled(t *testing.T) {
+	database := testDB(t)
+	cfg := config.LoadConfig()
+	cfg.EmptySubjectFallback = SubjectFallbackSnippet
+
+	now := time.Now().UTC().Truncate(time.Second)
+	storeMessagesInDB(database, cfg, []*models.Message{
+		{MessageID: "root@example.com", Author: "Alice", AuthorEmail: "alice@example.com",
+			Body: "Why does VACUUM skip this table?", CreatedAt: now.Add(-time.Hour)},
+		{MessageID: "reply@example.com", InReplyTo: "root@example.com", RefersTo: "<root@example.com>",
+			Subject: " ", Author: "Bob", AuthorEmail: "bob@example.com",
+			Body: "Check the xmin horizon.", CreatedAt: now},
+	})
+
+	var subject string
+	if err := database.QueryRow("SELECT subject FROM threads").Scan(&subject); err != nil {
+		t.Fatalf("query thread: %v", err)
+	}
+	if subject != "Why does VACUUM skip this table?" {
+		t.Errorf("subject = %q, want the root's first line", subject)
+	}
+}
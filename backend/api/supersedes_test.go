@@ -0,0 +1,59 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pgsql-analyzer/backend/config"
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+func TestSupersedesHeaderMarksReplacedMessage(t *testing.T) {
+	database := testDB(t)
+	cfg := config.LoadConfig()
+
+	at := time.Date(2024, 2, 2, 12, 0, 0, 0, time.UTC)
+	patch := func(id, supersedes string, minutes int) *models.Message {
+		return &models.Message{
+			MessageID: id, InReplyTo: "v1@example.com", RefersTo: "<v1@example.com>", Supersedes: supersedes,
+			Subject: "[PATCH] tweak", Author: "Alice", AuthorEmail: "alice@example.com",
+			Body: "diff --git a/x.c b/x.c\n", HasPatch: true, PatchStatus: "proposed",
+			CreatedAt: at.Add(time.Duration(minutes) * time.Minute),
+		}
+	}
+	v1 := patch("v1@example.com", "", 0)
+	v1.InReplyTo, v1.RefersTo = "", ""
+	// v3 says it replaces v1; v2 carries no header and falls back to the version heuristic
+	storeMessagesInDB(database, cfg, []*models.Message{v1, patch("v2@example.com", "", 10), patch("v3@example.com", "v1@example.com", 20)})
+
+	var threadID string
+	if err := database.QueryRow("SELECT id FROM threads").Scan(&threadID); err != nil {
+		t.Fatalf("query thread: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/api/threads/"+threadID+"/messages", nil), map[string]string{"id": threadID})
+	getThreadMessagesHandler(database)(rec, req)
+	var messages []*models.Message
+	if err := json.NewDecoder(rec.Body).Decode(&messages); err != nil {
+		t.Fatalf("decode messages: %v", err)
+	}
+
+	want := map[string][2]string{
+		// message-id: {supersedes, superseded_by}
+		"v1@example.com": {"", "v3@example.com"},
+		"v2@example.com": {"", "v3@example.com"},
+		"v3@example.com": {"v1@example.com", ""},
+	}
+	if len(messages) != len(want) {
+		t.Fatalf("got %d messages, want %d", len(messages), len(want))
+	}
+	for _, m := range messages {
+		w := want[m.MessageID]
+		if m.Supersedes != w[0] || m.SupersededBy != w[1] {
+			t.Errorf("%s: supersedes %q, superseded_by %q; want %q, %q", m.MessageID, m.Supersedes, m.SupersededBy, w[0], w[1])
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"net/http"
+
+	"github.com/pgsql-analyzer/backend/config"
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+// startSyncRun records the start of a sync and returns its id (0 if it couldn't be recorded)
+func startSyncRun(db *sql.DB, totalMonths int) int {
+	var id int
+	err := db.QueryRow(`
+		INSERT INTO sync_runs (started_at, months_total) VALUES (NOW(), $1) RETURNING id
+	`, totalMonths).Scan(&id)
+	if err != nil {
+		slog.Warn("Failed to record sync run start", "error", err)
+		return 0
+	}
+	return id
+}
+
+// finishSyncRun records the outcome of a sync started with startSyncRun
+func finishSyncRun(db *sql.DB, id, monthsProcessed, messagesStored int, interrupted bool) {
+	if id == 0 {
+		return
+	}
+	_, err := db.Exec(`
+		UPDATE sync_runs
+		SET finished_at = NOW(), months_processed = $2, messages_stored = $3, interrupted = $4
+		WHERE id = $1
+	`, id, monthsProcessed, messagesStored, interrupted)
+	if err != nil {
+		slog.Warn("Failed to record sync run finish", "id", id, "error", err)
+	}
+}
+
+// bounds returns the range as query arguments, both NULL for a nil range
+func (m *monthRange) bounds() (interface{}, interface{}) {
+	if m == nil {
+		return nil, nil
+	}
+	return m.start, m.end
+}
+
+// recordSyncRange stores the months a run was asked for, the range it set out
+// to cover, and the part still owed: what MAX_MONTHS_PER_SYNC cut off, plus
+// whatever a cancelled run didn't get through
+func recordSyncRange(db *sql.DB, id int, requested *monthRange, full monthRange, remaining *monthRange) {
+	if id == 0 {
+		return
+	}
+	reqStart, reqEnd := requested.bounds()
+	remStart, remEnd := remaining.bounds()
+	_, err := db.Exec(`
+		UPDATE sync_runs
+		SET requested_start = $2, requested_end = $3, range_start = $4, range_end = $5,
+		    remaining_start = $6, remaining_end = $7
+		WHERE id = $1
+	`, id, reqStart, reqEnd, full.start, full.end, remStart, remEnd)
+	if err != nil {
+		slog.Warn("Failed to record sync run range", "id", id, "error", err)
+	}
+}
+
+// owedRange returns the span of months an unfinished run still owes: those in
+// months not marked done, merged with the capped remainder. Months in between
+// that did finish are synced again, which is harmless. Returns nil when
+// nothing is owed.
+func owedRange(months []yearMonth, done map[yearMonth]bool, capped *monthRange) *monthRange {
+	owed := capped
+	for _, ym := range months {
+		if done[ym] {
+			continue
+		}
+		t := ym.time()
+		if owed == nil {
+			owed = &monthRange{start: t, end: t}
+			continue
+		}
+		if t.Before(owed.start) {
+			owed = &monthRange{start: t, end: owed.end}
+		}
+		if t.After(owed.end) {
+			owed = &monthRange{start: owed.start, end: t}
+		}
+	}
+	return owed
+}
+
+// pendingSyncRange returns the months still owed by the latest sync run that
+// asked for the same range (both incremental, or the same explicit range): the
+// remainder it recorded when capped or cancelled, or its whole range when it
+// never finished (the process died, so what it stored is unknown). Returns nil
+// when there is nothing to resume.
+func pendingSyncRange(db *sql.DB, requested *monthRange) *monthRange {
+	reqStart, reqEnd := requested.bounds()
+	var unfinished bool
+	var rangeStart, rangeEnd, remStart, remEnd sql.NullTime
+	err := db.QueryRow(`
+		SELECT finished_at IS NULL, range_start, range_end, remaining_start, remaining_end
+		FROM sync_runs
+		WHERE range_start IS NOT NULL
+		  AND requested_start IS NOT DISTINCT FROM $1::date
+		  AND requested_end IS NOT DISTINCT FROM $2::date
+		ORDER BY id DESC
+		LIMIT 1
+	`, reqStart, reqEnd).Scan(&unfinished, &rangeStart, &rangeEnd, &remStart, &remEnd)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		slog.Warn("Failed to look up unfinished sync run", "error", err)
+		return nil
+	}
+	if unfinished && rangeStart.Valid && rangeEnd.Valid {
+		return &monthRange{start: rangeStart.Time, end: rangeEnd.Time}
+	}
+	if !remStart.Valid || !remEnd.Valid {
+		return nil
+	}
+	return &monthRange{start: remStart.Time, end: remEnd.Time}
+}
+
+// getSyncHistoryHandler returns recent sync runs plus summary stats
+func getSyncHistoryHandler(db *sql.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		limit, offset := pagination(r, cfg)
+
+		rows, err := db.Query(`
+			SELECT id, started_at, finished_at, months_total, months_processed, messages_stored, interrupted,
+			       remaining_start, remaining_end
+			FROM sync_runs
+			ORDER BY started_at DESC, id DESC
+			LIMIT $1 OFFSET $2
+		`, limit, offset)
+		if err != nil {
+			log.Printf("Error querying sync history: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch sync history"})
+			return
+		}
+		defer rows.Close()
+
+		runs := make([]*models.SyncRun, 0)
+		for rows.Next() {
+			run := &models.SyncRun{}
+			var finishedAt, remStart, remEnd sql.NullTime
+			if err := rows.Scan(
+				&run.ID, &run.StartedAt, &finishedAt, &run.MonthsTotal,
+				&run.MonthsProcessed, &run.MessagesStored, &run.Interrupted,
+				&remStart, &remEnd,
+			); err != nil {
+				log.Printf("Error scanning sync run: %v", err)
+				continue
+			}
+			if finishedAt.Valid {
+				run.FinishedAt = &finishedAt.Time
+				run.DurationSeconds = finishedAt.Time.Sub(run.StartedAt).Seconds()
+			}
+			if remStart.Valid && remEnd.Valid {
+				run.RemainingFrom = remStart.Time.Format("2006-01")
+				run.RemainingTo = remEnd.Time.Format("2006-01")
+			}
+			runs = append(runs, run)
+		}
+
+		// Average only over runs that completed normally
+		var totalRuns, completedRuns int
+		var avgDuration sql.NullFloat64
+		db.QueryRow(`
+			SELECT
+				COUNT(*),
+				COUNT(*) FILTER (WHERE finished_at IS NOT NULL AND NOT interrupted),
+				AVG(EXTRACT(EPOCH FROM finished_at - started_at)) FILTER (WHERE finished_at IS NOT NULL AND NOT interrupted)
+			FROM sync_runs
+		`).Scan(&totalRuns, &completedRuns, &avgDuration)
+
+		summary := map[string]interface{}{
+			"total_runs":     totalRuns,
+			"completed_runs": completedRuns,
+		}
+		if avgDuration.Valid {
+			summary["average_duration_seconds"] = avgDuration.Float64
+		}
+		if len(runs) > 0 {
+			summary["last_run"] = runs[0]
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"runs":    runs,
+			"summary": summary,
+			"limit":   limit,
+			"offset":  offset,
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func month(year, m int) time.Time {
+	return yearMonth{year: year, month: m}.time()
+}
+
+func TestOwedRange(t *testing.T) {
+	months := monthsBetween(month(2024, 1), month(2024, 6))
+	cases := []struct {
+		name   string
+		done   []yearMonth
+		capped *monthRange
+		want   *monthRange
+	}{
+		{name: "all done", done: months, want: nil},
+		{name: "nothing done", want: &monthRange{start: month(2024, 1), end: month(2024, 6)}},
+		{
+			// Parallel workers finished months out of order before the cancel
+			name: "out of order",
+			done: []yearMonth{{2024, 1}, {2024, 3}, {2024, 4}, {2024, 6}},
+			want: &monthRange{start: month(2024, 2), end: month(2024, 5)},
+		},
+		{
+			name:   "merged with capped remainder",
+			done:   []yearMonth{{2024, 1}, {2024, 2}, {2024, 3}, {2024, 4}, {2024, 6}},
+			capped: &monthRange{start: month(2024, 7), end: month(2024, 9)},
+			want:   &monthRange{start: month(2024, 5), end: month(2024, 9)},
+		},
+		{
+			name:   "capped remainder only",
+			done:   months,
+			capped: &monthRange{start: month(2024, 7), end: month(2024, 9)},
+			want:   &monthRange{start: month(2024, 7), end: month(2024, 9)},
+		},
+	}
+	for _, c := range cases {
+		done := make(map[yearMonth]bool)
+		for _, ym := range c.done {
+			done[ym] = true
+		}
+		got := owedRange(months, done, c.capped)
+		if (got == nil) != (c.want == nil) || got != nil && (!got.start.Equal(c.want.start) || !got.end.Equal(c.want.end)) {
+			t.Errorf("%s: owedRange = %+v, want %+v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestCancelledSyncResumesUnprocessedMonths(t *testing.T) {
+	database := testDB(t)
+
+	// A cancelled incremental run over Jan-Jun 2024 that finished Jan, Mar and Jun
+	months := monthsBetween(month(2024, 1), month(2024, 6))
+	runID := startSyncRun(database, len(months))
+	full := monthRange{start: month(2024, 1), end: month(2024, 6)}
+	recordSyncRange(database, runID, nil, full, nil)
+	done := map[yearMonth]bool{{2024, 1}: true, {2024, 3}: true, {2024, 6}: true}
+	recordSyncRange(database, runID, nil, full, owedRange(months, done, nil))
+	finishSyncRun(database, runID, 4, 100, true)
+
+	pending := pendingSyncRange(database, nil)
+	if pending == nil || !pending.start.Equal(month(2024, 2)) || !pending.end.Equal(month(2024, 5)) {
+		t.Fatalf("pendingSyncRange after cancel = %+v, want 2024-02..2024-05", pending)
+	}
+
+	// An explicit range doesn't pick up the incremental run's remainder
+	if got := pendingSyncRange(database, &full); got != nil {
+		t.Errorf("pendingSyncRange for another range = %+v, want nil", got)
+	}
+
+	// A run that never finished (process died) resumes its whole range
+	runID = startSyncRun(database, len(months))
+	recordSyncRange(database, runID, nil, full, nil)
+	pending = pendingSyncRange(database, nil)
+	if pending == nil || !pending.start.Equal(full.start) || !pending.end.Equal(full.end) {
+		t.Errorf("pendingSyncRange after crash = %+v, want the whole range", pending)
+	}
+
+	// A completed run owes nothing
+	finishSyncRun(database, runID, len(months), 100, false)
+	if got := pendingSyncRange(database, nil); got != nil {
+		t.Errorf("pendingSyncRange after completing = %+v, want nil", got)
+	}
+}
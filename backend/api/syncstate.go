@@ -1,6 +1,10 @@
 package api
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
 	"sync"
 	"time"
 
@@ -17,8 +21,40 @@ var GlobalSyncState = &SyncState{
 }
 
 type SyncState struct {
-	mu       sync.RWMutex
-	Progress models.SyncProgress
+	mu          sync.RWMutex
+	Progress    models.SyncProgress
+	cancel      context.CancelFunc // cancels the running sync's context; nil when idle
+	subscribers map[chan models.SyncProgress]struct{}
+}
+
+// Subscribe returns a channel that receives the progress after every change,
+// and a function that unregisters it. A subscriber that falls behind only
+// misses intermediate states: the channel always holds the latest one.
+func (s *SyncState) Subscribe() (<-chan models.SyncProgress, func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch := make(chan models.SyncProgress, 1)
+	if s.subscribers == nil {
+		s.subscribers = make(map[chan models.SyncProgress]struct{})
+	}
+	s.subscribers[ch] = struct{}{}
+	return ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.subscribers, ch)
+	}
+}
+
+// notify sends the current progress to every subscriber without blocking,
+// replacing a value a slow subscriber hasn't read yet. Callers hold s.mu.
+func (s *SyncState) notify() {
+	for ch := range s.subscribers {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- s.Progress
+	}
 }
 
 func (s *SyncState) Update(monthsSynced, totalMonths int, currentMonth string) {
@@ -29,18 +65,62 @@ func (s *SyncState) Update(monthsSynced, totalMonths int, currentMonth string) {
 	s.Progress.CurrentMonth = currentMonth
 	now := time.Now()
 	s.Progress.LastSyncedAt = &now
+	s.notify()
 }
 
 func (s *SyncState) SetSyncing(syncing bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.Progress.IsSyncing = syncing
+	if !syncing && s.cancel != nil {
+		// Releases the context's resources; the sync is already over
+		s.cancel()
+		s.cancel = nil
+	}
+	s.notify()
+}
+
+// TryStartSync marks a sync as running unless one already is. It reports
+// whether the caller now owns the sync and must call SetSyncing(false) when done.
+// The returned context is cancelled by Cancel; the sync should stop promptly
+// once it is done.
+func (s *SyncState) TryStartSync() (context.Context, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Progress.IsSyncing {
+		return nil, false
+	}
+	s.Progress.IsSyncing = true
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.notify()
+	return ctx, true
+}
+
+// Cancel asks the running sync to stop. It reports whether one was running.
+func (s *SyncState) Cancel() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.Progress.IsSyncing || s.cancel == nil {
+		return false
+	}
+	s.cancel()
+	return true
+}
+
+// SetRemainingMonths records how many months the sync left for a later run
+func (s *SyncState) SetRemainingMonths(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Progress.RemainingMonths = n
+	s.notify()
 }
 
 func (s *SyncState) SetLatestMessageDate(t time.Time) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.Progress.LatestMessageDate = &t
+	s.notify()
 }
 
 func (s *SyncState) Get() models.SyncProgress {
@@ -48,3 +128,60 @@ func (s *SyncState) Get() models.SyncProgress {
 	defer s.mu.RUnlock()
 	return s.Progress
 }
+
+// progressKeepAlive is how often an idle progress stream sends a comment line,
+// so proxies don't close it
+const progressKeepAlive = 30 * time.Second
+
+// getSyncProgressStreamHandler streams sync progress as Server-Sent Events:
+// a "progress" event with the SyncProgress JSON on connect and after every
+// change, until the client disconnects
+func getSyncProgressStreamHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Streaming is not supported"})
+		return
+	}
+
+	updates, unsubscribe := GlobalSyncState.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	send := func(progress models.SyncProgress) bool {
+		data, err := json.Marshal(progress)
+		if err != nil {
+			return false
+		}
+		if _, err := fmt.Fprintf(w, "event: progress\ndata: %s\n\n", data); err != nil {
+			return false
+		}
+		flusher.Flush()
+		return true
+	}
+	if !send(GlobalSyncState.Get()) {
+		return
+	}
+
+	keepAlive := time.NewTicker(progressKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case progress := <-updates:
+			if !send(progress) {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
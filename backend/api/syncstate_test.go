@@ -0,0 +1,148 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+func TestSyncStateCancel(t *testing.T) {
+	s := &SyncState{}
+	if s.Cancel() {
+		t.Error("Cancel reported a sync when none was running")
+	}
+
+	ctx, ok := s.TryStartSync()
+	if !ok {
+		t.Fatal("TryStartSync failed on an idle state")
+	}
+	if _, ok := s.TryStartSync(); ok {
+		t.Error("a second sync started while one was running")
+	}
+	if !s.Cancel() {
+		t.Error("Cancel reported no sync while one was running")
+	}
+	select {
+	case <-ctx.Done():
+	default:
+		t.Fatal("Cancel did not cancel the sync's context")
+	}
+
+	s.SetSyncing(false)
+	if _, ok := s.TryStartSync(); !ok {
+		t.Error("TryStartSync failed after the cancelled sync finished")
+	}
+}
+
+// nextProgress waits briefly for the next value on ch
+func nextProgress(t *testing.T, ch <-chan models.SyncProgress) models.SyncProgress {
+	t.Helper()
+	select {
+	case progress := <-ch:
+		return progress
+	case <-time.After(time.Second):
+		t.Fatal("no progress delivered")
+		return models.SyncProgress{}
+	}
+}
+
+func TestSyncStateSubscribe(t *testing.T) {
+	s := &SyncState{}
+	updates, unsubscribe := s.Subscribe()
+
+	s.Update(2, 5, "2024-02")
+	if got := nextProgress(t, updates); got.MonthsSynced != 2 || got.TotalMonths != 5 || got.CurrentMonth != "2024-02" {
+		t.Errorf("delivered %+v, want month 2 of 5", got)
+	}
+
+	// A subscriber that falls behind gets the latest state, not a backlog
+	s.Update(3, 5, "2024-03")
+	s.SetSyncing(true)
+	latest := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	s.SetLatestMessageDate(latest)
+	got := nextProgress(t, updates)
+	if got.MonthsSynced != 3 || !got.IsSyncing || got.LatestMessageDate == nil || !got.LatestMessageDate.Equal(latest) {
+		t.Errorf("delivered %+v, want the state after all three changes", got)
+	}
+	select {
+	case stale := <-updates:
+		t.Errorf("intermediate state %+v delivered after the latest", stale)
+	default:
+	}
+
+	unsubscribe()
+	s.Update(4, 5, "2024-04")
+	select {
+	case progress := <-updates:
+		t.Errorf("delivered %+v after unsubscribing", progress)
+	default:
+	}
+}
+
+func TestSyncProgressStream(t *testing.T) {
+	saved := GlobalSyncState.Get()
+	t.Cleanup(func() {
+		GlobalSyncState.mu.Lock()
+		GlobalSyncState.Progress = saved
+		GlobalSyncState.mu.Unlock()
+	})
+	subscribers := func() int {
+		GlobalSyncState.mu.RLock()
+		defer GlobalSyncState.mu.RUnlock()
+		return len(GlobalSyncState.subscribers)
+	}
+	before := subscribers()
+
+	srv := httptest.NewServer(http.HandlerFunc(getSyncProgressStreamHandler))
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/api/sync/progress/stream", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	events := make(chan models.SyncProgress)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+			if !ok {
+				continue
+			}
+			var progress models.SyncProgress
+			if json.Unmarshal([]byte(data), &progress) == nil {
+				events <- progress
+			}
+		}
+	}()
+
+	// The current state is sent on connect, then every change
+	nextProgress(t, events)
+	GlobalSyncState.Update(7, 12, "2024-07")
+	if got := nextProgress(t, events); got.MonthsSynced != 7 || got.TotalMonths != 12 || got.CurrentMonth != "2024-07" {
+		t.Errorf("streamed %+v, want month 7 of 12", got)
+	}
+
+	// Disconnecting unregisters the subscriber
+	cancel()
+	for deadline := time.Now().Add(time.Second); subscribers() != before; {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d subscribers after disconnect, want %d", subscribers(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/config"
+	"github.com/pgsql-analyzer/backend/db"
+)
+
+// testDB connects to TEST_DATABASE_URL inside a throwaway, fully migrated
+// schema that is dropped when the test ends. Tests using it are skipped
+// without the variable.
+func testDB(t testing.TB) *sql.DB {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	database, err := db.InitDB(&config.Config{DatabaseURL: url, DBSchema: schema, DBMaxOpenConns: 8})
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() {
+		database.Exec("DROP SCHEMA " + schema + " CASCADE")
+		database.Close()
+	})
+	if err := db.RunMigrations(database); err != nil {
+		t.Fatalf("RunMigrations: %v", err)
+	}
+	return database
+}
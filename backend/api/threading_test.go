@@ -0,0 +1,105 @@
+package api
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+func TestGroupByThreadMatchesMixedCaseReferences(t *testing.T) {
+	// The root's id is stored normalized, as cleanMessageID leaves it; replies
+	// quote it with the domain in other cases
+	messages := []*models.Message{
+		{MessageID: "Root.1@mail.example.com"},
+		{MessageID: "reply.1@example.org", RefersTo: "<Root.1@Mail.Example.COM>"},
+		{MessageID: "reply.2@example.org", InReplyTo: "Root.1@MAIL.EXAMPLE.COM"},
+		{MessageID: "reply.3@example.org", RefersTo: "<Root.1@mail.example.com> <reply.1@EXAMPLE.ORG>"},
+	}
+
+	threads := groupByThread(messages, 100)
+	if len(threads) != 1 {
+		t.Fatalf("got %d threads, want 1: %v", len(threads), threads)
+	}
+	if got := len(threads["Root.1@mail.example.com"]); got != 4 {
+		t.Errorf("root thread has %d messages, want 4", got)
+	}
+}
+
+func TestGroupByThreadKeepsLocalPartCase(t *testing.T) {
+	messages := []*models.Message{
+		{MessageID: "abc@example.com"},
+		{MessageID: "reply@example.org", RefersTo: "<ABC@example.com>"},
+	}
+	if threads := groupByThread(messages, 100); len(threads) != 2 {
+		t.Errorf("local parts differing in case were merged: %v", threads)
+	}
+}
+
+func TestReferenceIDsNormalizeDomains(t *testing.T) {
+	msg := &models.Message{RefersTo: "<A@Example.COM> <a@example.com> <A@example.com>", InReplyTo: "B@EXAMPLE.com"}
+	got := referenceIDs(msg)
+	want := []string{"A@example.com", "a@example.com", "B@example.com"}
+	if len(got) != len(want) {
+		t.Fatalf("referenceIDs = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("referenceIDs = %v, want %v", got, want)
+			break
+		}
+	}
+}
+
+// replyChain returns n messages, each replying (In-Reply-To only) to the one before
+func replyChain(n int) []*models.Message {
+	msgs := make([]*models.Message, n)
+	for i := range msgs {
+		msgs[i] = &models.Message{MessageID: fmt.Sprintf("m%d@example.com", i)}
+		if i > 0 {
+			msgs[i].InReplyTo = msgs[i-1].MessageID
+		}
+	}
+	return msgs
+}
+
+func TestGroupByThreadDeepChain(t *testing.T) {
+	const depth = 1000
+	// Newest first, so resolving each message walks the chain rather than
+	// hitting an ancestor resolved just before
+	msgs := replyChain(depth)
+	for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
+		msgs[i], msgs[j] = msgs[j], msgs[i]
+	}
+
+	threads := groupByThread(msgs, depth)
+	if len(threads) != 1 || len(threads["m0@example.com"]) != depth {
+		t.Fatalf("got %d threads, want one of %d rooted at m0", len(threads), depth)
+	}
+
+	// Past the cap, a message falls back to its first reference as root
+	const maxDepth = 100
+	roots := threadRoots(msgs, maxDepth)
+	if got := roots["m999@example.com"]; got != "m998@example.com" {
+		t.Errorf("root of m999 with depth cap %d = %q, want its parent m998", maxDepth, got)
+	}
+	if got := roots["m50@example.com"]; got != "m0@example.com" {
+		t.Errorf("root of m50 within the cap = %q, want m0", got)
+	}
+}
+
+func TestGroupByThreadReferenceCycle(t *testing.T) {
+	msgs := []*models.Message{
+		{MessageID: "a@example.com", InReplyTo: "c@example.com"},
+		{MessageID: "b@example.com", InReplyTo: "a@example.com"},
+		{MessageID: "c@example.com", InReplyTo: "b@example.com"},
+	}
+	threads := groupByThread(msgs, 1000)
+	total := 0
+	for _, thread := range threads {
+		total += len(thread)
+	}
+	if total != len(msgs) {
+		t.Errorf("cyclic references lost messages: %v", threads)
+	}
+}
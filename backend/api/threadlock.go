@@ -0,0 +1,39 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+)
+
+// threadLockClass namespaces the advisory locks taken by lockThreads
+const threadLockClass = 1740
+
+// lockThreads serializes stores that touch the same threads: it takes a
+// session advisory lock per thread id, in sorted order so two callers can't
+// deadlock, on a connection of its own, and returns a function releasing them.
+// Advisory rather than row locks, because the analyzer updates thread rows
+// through other pooled connections, which a row lock held here would block.
+func lockThreads(db *sql.DB, ids ...string) (func(), error) {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sorted := append([]string(nil), ids...)
+	sort.Strings(sorted)
+	release := func() {
+		conn.ExecContext(ctx, "SELECT pg_advisory_unlock_all()")
+		conn.Close()
+	}
+	for i, id := range sorted {
+		if i > 0 && id == sorted[i-1] {
+			continue
+		}
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1, hashtext($2))", threadLockClass, id); err != nil {
+			release()
+			return nil, err
+		}
+	}
+	return release, nil
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// defaultSizeBuckets are the lower bounds of the default thread size buckets:
+// 1, 2-5, 6-20, 21-50, 51-100, 101+
+var defaultSizeBuckets = []int{1, 2, 6, 21, 51, 101}
+
+// maxSizeBuckets caps ?buckets= so the response stays a histogram
+const maxSizeBuckets = 50
+
+// threadSizeBucket counts threads whose message_count is in [Min, Max]
+type threadSizeBucket struct {
+	Label   string `json:"label"` // e.g. "2-5" or "101+"
+	Min     int    `json:"min"`
+	Max     *int   `json:"max"` // null for the open-ended last bucket
+	Threads int    `json:"threads"`
+}
+
+// parseSizeBuckets reads a comma-separated list of strictly increasing,
+// positive lower bounds
+func parseSizeBuckets(value string) ([]int, error) {
+	if value == "" {
+		return defaultSizeBuckets, nil
+	}
+	parts := strings.Split(value, ",")
+	if len(parts) > maxSizeBuckets {
+		return nil, fmt.Errorf("buckets accepts at most %d bounds", maxSizeBuckets)
+	}
+	bounds := make([]int, 0, len(parts))
+	for _, p := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("buckets must be positive integers, got %q", p)
+		}
+		if len(bounds) > 0 && n <= bounds[len(bounds)-1] {
+			return nil, fmt.Errorf("buckets must be strictly increasing")
+		}
+		bounds = append(bounds, n)
+	}
+	return bounds, nil
+}
+
+// getThreadSizeStatsHandler returns a histogram of threads by message_count.
+// ?buckets= gives the lower bound of each bucket (default 1,2,6,21,51,101);
+// each bucket runs up to the next bound and the last is open-ended. Threads
+// smaller than the first bound aren't counted. Announcements are left out
+// unless ?include_announcements=true, as on /api/stats.
+func getThreadSizeStatsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		bounds, err := parseSizeBuckets(r.URL.Query().Get("buckets"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		kindFilter := " AND kind <> 'announcement'"
+		if r.URL.Query().Get("include_announcements") == "true" {
+			kindFilter = ""
+		}
+
+		buckets := make([]threadSizeBucket, len(bounds))
+		for i, min := range bounds {
+			buckets[i] = threadSizeBucket{Min: min, Label: fmt.Sprintf("%d+", min)}
+			if i+1 < len(bounds) {
+				max := bounds[i+1] - 1
+				buckets[i].Max = &max
+				buckets[i].Label = fmt.Sprintf("%d-%d", min, max)
+				if min == max {
+					buckets[i].Label = strconv.Itoa(min)
+				}
+			}
+		}
+
+		// width_bucket returns i for bounds[i-1] <= message_count < bounds[i]
+		rows, err := db.Query(`
+			SELECT width_bucket(message_count, $1::int[]) AS bucket, COUNT(*)
+			FROM threads
+			WHERE message_count >= $2`+kindFilter+`
+			GROUP BY bucket
+		`, pq.Array(bounds), bounds[0])
+		if err != nil {
+			log.Printf("Error querying thread size stats: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch thread size stats"})
+			return
+		}
+		defer rows.Close()
+
+		total := 0
+		for rows.Next() {
+			var bucket, count int
+			if err := rows.Scan(&bucket, &count); err != nil {
+				log.Printf("Error scanning thread size stats: %v", err)
+				continue
+			}
+			if bucket >= 1 && bucket <= len(buckets) {
+				buckets[bucket-1].Threads = count
+				total += count
+			}
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"buckets":       buckets,
+			"total_threads": total,
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/config"
+)
+
+// timeoutHeader lets a client choose how long its request's queries may run
+const timeoutHeader = "X-Timeout-Ms"
+
+// withRequestTimeout bounds the request context of an expensive handler. The
+// deadline is X-Timeout-Ms when given, clamped to cfg.MaxRequestTimeout, and
+// the maximum otherwise. Handlers pass r.Context() to their queries and report
+// an expired deadline with queryTimedOut.
+func withRequestTimeout(cfg *config.Config, next http.HandlerFunc) http.HandlerFunc {
+	return requestTimeout(cfg, true, next)
+}
+
+// withExportTimeout is withRequestTimeout for streamed exports: the deadline
+// applies only when the client sends X-Timeout-Ms, since a large export can
+// legitimately stream for longer than any fixed default
+func withExportTimeout(cfg *config.Config, next http.HandlerFunc) http.HandlerFunc {
+	return requestTimeout(cfg, false, next)
+}
+
+func requestTimeout(cfg *config.Config, byDefault bool, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		timeout := cfg.MaxRequestTimeout
+		value := strings.TrimSpace(r.Header.Get(timeoutHeader))
+		if value == "" && !byDefault {
+			next(w, r)
+			return
+		}
+		if value != "" {
+			ms, err := strconv.Atoi(value)
+			if err != nil || ms <= 0 {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": timeoutHeader + " must be a positive number of milliseconds",
+				})
+				return
+			}
+			timeout = min(time.Duration(ms)*time.Millisecond, cfg.MaxRequestTimeout)
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// queryTimedOut writes a 504 and returns true when the request's deadline has
+// passed. The driver's error for a cancelled statement doesn't always wrap the
+// context error, so the context is checked rather than err.
+func queryTimedOut(w http.ResponseWriter, r *http.Request, cfg *config.Config) bool {
+	if !errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusGatewayTimeout)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": fmt.Sprintf("Query exceeded the request timeout; narrow it or raise %s (at most %d)",
+			timeoutHeader, cfg.MaxRequestTimeout.Milliseconds()),
+	})
+	return true
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/config"
+)
+
+// deadlineOf runs wrap around a handler that records the request's deadline
+func deadlineOf(wrap func(*config.Config, http.HandlerFunc) http.HandlerFunc, cfg *config.Config, header string) (time.Duration, bool, int) {
+	var left time.Duration
+	var has bool
+	h := wrap(cfg, func(w http.ResponseWriter, r *http.Request) {
+		var deadline time.Time
+		deadline, has = r.Context().Deadline()
+		left = time.Until(deadline)
+	})
+	req := httptest.NewRequest("GET", "/", nil)
+	if header != "" {
+		req.Header.Set(timeoutHeader, header)
+	}
+	rec := httptest.NewRecorder()
+	h(rec, req)
+	return left, has, rec.Code
+}
+
+func TestRequestTimeoutDeadline(t *testing.T) {
+	cfg := &config.Config{MaxRequestTimeout: time.Minute}
+
+	if left, has, _ := deadlineOf(withRequestTimeout, cfg, ""); !has || left < 59*time.Second {
+		t.Errorf("no header: deadline in %v (set %v), want the 1m maximum", left, has)
+	}
+	if left, has, _ := deadlineOf(withRequestTimeout, cfg, "500"); !has || left > 500*time.Millisecond {
+		t.Errorf("500ms header: deadline in %v (set %v)", left, has)
+	}
+	if left, _, _ := deadlineOf(withRequestTimeout, cfg, "3600000"); left > time.Minute {
+		t.Errorf("header above the maximum: deadline in %v, want it clamped to 1m", left)
+	}
+	for _, bad := range []string{"0", "-5", "soon"} {
+		if _, _, code := deadlineOf(withRequestTimeout, cfg, bad); code != http.StatusBadRequest {
+			t.Errorf("header %q: status %d, want 400", bad, code)
+		}
+	}
+
+	// A streamed export only gets a deadline the client asked for
+	if _, has, _ := deadlineOf(withExportTimeout, cfg, ""); has {
+		t.Error("export without header got a deadline")
+	}
+	if left, has, _ := deadlineOf(withExportTimeout, cfg, "500"); !has || left > 500*time.Millisecond {
+		t.Errorf("export with 500ms header: deadline in %v (set %v)", left, has)
+	}
+}
+
+func TestSlowQueryTimesOut(t *testing.T) {
+	database := testDB(t)
+	cfg := &config.Config{MaxRequestTimeout: time.Minute}
+
+	h := withRequestTimeout(cfg, func(w http.ResponseWriter, r *http.Request) {
+		if _, err := database.ExecContext(r.Context(), "SELECT pg_sleep(10)"); err != nil {
+			if queryTimedOut(w, r, cfg) {
+				return
+			}
+			t.Errorf("slow query failed without timing out: %v", err)
+		}
+	})
+	req := httptest.NewRequest("GET", "/api/threads", nil)
+	req.Header.Set(timeoutHeader, "200")
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	h(rec, req)
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("status %d, want 504: %s", rec.Code, rec.Body)
+	}
+	if took := time.Since(start); took > 5*time.Second {
+		t.Errorf("request took %v; the query wasn't cancelled at the deadline", took)
+	}
+}
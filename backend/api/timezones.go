@@ -0,0 +1,71 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// timezoneBucket counts messages sent from one UTC offset
+type timezoneBucket struct {
+	OffsetMinutes int    `json:"offset_minutes"`
+	Offset        string `json:"offset"` // e.g. "+05:30"
+	Messages      int    `json:"messages"`
+	Authors       int    `json:"authors"`
+}
+
+// formatOffset renders an offset in minutes as ±hh:mm
+func formatOffset(minutes int) string {
+	sign := '+'
+	if minutes < 0 {
+		sign = '-'
+		minutes = -minutes
+	}
+	return fmt.Sprintf("%c%02d:%02d", sign, minutes/60, minutes%60)
+}
+
+// getTimezoneStatsHandler aggregates messages by the UTC offset in their Date
+// header. Messages whose header gave no usable offset are counted as unknown.
+func getTimezoneStatsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		rows, err := db.Query(`
+			SELECT tz_offset_minutes, COUNT(*), COUNT(DISTINCT LOWER(author_email))
+			FROM messages
+			WHERE tz_offset_minutes IS NOT NULL
+			GROUP BY tz_offset_minutes
+			ORDER BY tz_offset_minutes ASC
+		`)
+		if err != nil {
+			log.Printf("Error querying timezone stats: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch timezone stats"})
+			return
+		}
+		defer rows.Close()
+
+		buckets := make([]timezoneBucket, 0)
+		for rows.Next() {
+			var b timezoneBucket
+			if err := rows.Scan(&b.OffsetMinutes, &b.Messages, &b.Authors); err != nil {
+				log.Printf("Error scanning timezone stats: %v", err)
+				continue
+			}
+			b.Offset = formatOffset(b.OffsetMinutes)
+			buckets = append(buckets, b)
+		}
+
+		var unknown int
+		if err := db.QueryRow("SELECT COUNT(*) FROM messages WHERE tz_offset_minutes IS NULL").Scan(&unknown); err != nil {
+			log.Printf("Error counting messages without timezone: %v", err)
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"offsets": buckets,
+			"unknown": unknown,
+		})
+	}
+}
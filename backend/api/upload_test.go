@@ -0,0 +1,91 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/config"
+)
+
+// chunkedReader returns at most n bytes per Read, as network readers may
+type chunkedReader struct {
+	r io.Reader
+	n int
+}
+
+func (c chunkedReader) Read(p []byte) (int, error) {
+	if len(p) > c.n {
+		p = p[:c.n]
+	}
+	return c.r.Read(p)
+}
+
+func TestUploadLargeMboxShortReads(t *testing.T) {
+	database := testDB(t)
+	cfg := config.LoadConfig()
+	cfg.DataDir = t.TempDir()
+
+	// About 5MB of replies to a handful of threads
+	var mbox strings.Builder
+	filler := strings.Repeat("Lorem ipsum dolor sit amet, consectetur adipiscing elit.\n", 60)
+	count := 0
+	for mbox.Len() < 5<<20 {
+		fmt.Fprintf(&mbox, "From author%d@example.com Fri Feb  2 12:00:00 2024\n", count)
+		fmt.Fprintf(&mbox, "Message-ID: <m%d@example.com>\n", count)
+		if count >= 20 {
+			fmt.Fprintf(&mbox, "In-Reply-To: <m%d@example.com>\nReferences: <m%d@example.com>\n", count%20, count%20)
+		}
+		fmt.Fprintf(&mbox, "From: Author %d <author%d@example.com>\n", count%7, count%7)
+		fmt.Fprintf(&mbox, "Date: %s\n", time.Date(2024, 2, 2, 0, 0, count, 0, time.UTC).Format(time.RFC1123Z))
+		fmt.Fprintf(&mbox, "Subject: thread %d\n\n%s\n", count%20, filler)
+		count++
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "upload.mbox")
+	if err != nil {
+		t.Fatalf("CreateFormFile: %v", err)
+	}
+	io.WriteString(part, mbox.String())
+	form.Close()
+
+	req := httptest.NewRequest("POST", "/api/sync/mbox", chunkedReader{&body, 512})
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rec := httptest.NewRecorder()
+	uploadMboxHandler(database, cfg)(rec, req)
+	if rec.Code != 200 {
+		t.Fatalf("upload status %d: %s", rec.Code, rec.Body)
+	}
+	var resp map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || resp["filename"] != "upload.mbox" {
+		t.Fatalf("upload response %v, %v", resp, err)
+	}
+
+	// The file is processed in the background; wait until every message is
+	// stored and counted in its thread
+	deadline := time.Now().Add(time.Minute)
+	for {
+		var stored, counted int
+		err := database.QueryRow(`
+			SELECT (SELECT COUNT(*) FROM messages), (SELECT COALESCE(SUM(message_count), 0) FROM threads)
+		`).Scan(&stored, &counted)
+		if err != nil {
+			t.Fatalf("count messages: %v", err)
+		}
+		if stored == count && counted == count {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("stored %d and counted %d of %d uploaded messages", stored, counted, count)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
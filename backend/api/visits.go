@@ -0,0 +1,164 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/config"
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+// clientTokenHeader carries the opaque token a client uses to keep its
+// "last seen" marker across devices. Requests without it get no read state.
+const clientTokenHeader = "X-Client-Token"
+
+// clientTokenPattern keeps tokens to URL-safe strings long enough to be unguessable
+var clientTokenPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{16,128}$`)
+
+// clientVisit is a token's read-state marker
+type clientVisit struct {
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// clientToken returns the request's token, "" when none was sent, or an error
+// response when one was sent but is malformed
+func clientToken(w http.ResponseWriter, r *http.Request) (string, bool) {
+	token := r.Header.Get(clientTokenHeader)
+	if token == "" || clientTokenPattern.MatchString(token) {
+		return token, true
+	}
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": clientTokenHeader + " must be 16-128 letters, digits, '-' or '_'",
+	})
+	return "", false
+}
+
+// lastSeenAt looks up the token's marker. A token that was never advanced or
+// has expired has none, and threads are then not marked.
+func lastSeenAt(db *sql.DB, cfg *config.Config, token string) (*time.Time, error) {
+	var seen time.Time
+	err := db.QueryRow(`
+		SELECT last_seen_at FROM client_visits
+		WHERE token = $1 AND updated_at > $2
+	`, token, time.Now().Add(-cfg.VisitTokenTTL)).Scan(&seen)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &seen, nil
+}
+
+// markNewActivity flags threads with messages after the token's last visit
+func markNewActivity(threads []*models.Thread, seen *time.Time) {
+	if seen == nil {
+		return
+	}
+	for _, t := range threads {
+		isNew := t.LastMessageAt != nil && t.LastMessageAt.After(*seen)
+		t.NewActivity = &isNew
+	}
+}
+
+// getVisitHandler returns the marker for the X-Client-Token token
+func getVisitHandler(db *sql.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		token, ok := clientToken(w, r)
+		if !ok {
+			return
+		}
+		if token == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": clientTokenHeader + " header is required"})
+			return
+		}
+
+		var visit clientVisit
+		var updatedAt time.Time
+		err := db.QueryRow(`
+			SELECT last_seen_at, updated_at FROM client_visits
+			WHERE token = $1 AND updated_at > $2
+		`, token, time.Now().Add(-cfg.VisitTokenTTL)).Scan(&visit.LastSeenAt, &updatedAt)
+		if err == sql.ErrNoRows {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "No visit recorded for this token"})
+			return
+		} else if err != nil {
+			log.Printf("Error querying client visit: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch visit"})
+			return
+		}
+		visit.ExpiresAt = updatedAt.Add(cfg.VisitTokenTTL)
+
+		json.NewEncoder(w).Encode(visit)
+	}
+}
+
+// advanceVisitHandler moves the token's marker to now, or to the optional
+// {"seen_at": ...} in the body. The marker never moves backwards, so a stale
+// device can't resurface threads another device already saw. Expired tokens
+// are purged here, which keeps the table small without a background job.
+func advanceVisitHandler(db *sql.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		token, ok := clientToken(w, r)
+		if !ok {
+			return
+		}
+		if token == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": clientTokenHeader + " header is required"})
+			return
+		}
+
+		var body struct {
+			SeenAt *time.Time `json:"seen_at"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body: " + err.Error()})
+			return
+		}
+		now := time.Now().UTC()
+		seenAt := now
+		if body.SeenAt != nil && body.SeenAt.Before(now) {
+			seenAt = body.SeenAt.UTC()
+		}
+
+		cutoff := now.Add(-cfg.VisitTokenTTL)
+		if _, err := db.Exec("DELETE FROM client_visits WHERE updated_at <= $1", cutoff); err != nil {
+			log.Printf("Error purging expired client visits: %v", err)
+		}
+
+		var visit clientVisit
+		err := db.QueryRow(`
+			INSERT INTO client_visits (token, last_seen_at, updated_at)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (token) DO UPDATE
+			SET last_seen_at = GREATEST(client_visits.last_seen_at, EXCLUDED.last_seen_at),
+			    updated_at = EXCLUDED.updated_at
+			RETURNING last_seen_at
+		`, token, seenAt, now).Scan(&visit.LastSeenAt)
+		if err != nil {
+			log.Printf("Error advancing client visit: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to record visit"})
+			return
+		}
+		visit.ExpiresAt = now.Add(cfg.VisitTokenTTL)
+
+		json.NewEncoder(w).Encode(visit)
+	}
+}
@@ -1,7 +1,17 @@
 package config
 
 import (
+	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Values for SYNC_ORDER
+const (
+	SyncOrderOldest = "oldest"
+	SyncOrderNewest = "newest"
 )
 
 type Config struct {
@@ -12,6 +22,16 @@ type Config struct {
 	DBName      string
 	DBUser      string
 	DBPassword  string
+	DBSchema    string // search_path for all connections (empty = server default)
+
+	// How long startup keeps retrying the first database connection
+	DBConnectTimeout time.Duration
+	// Cap on pooled database connections, shared by API requests and sync
+	DBMaxOpenConns int
+	// Months a sync may write to the database at once (bounded by DBMaxOpenConns)
+	StoreConcurrency int
+	// Messages a sync parses before storing them, bounding its memory per month
+	SyncBatchSize int
 
 	// API
 	APIPort string
@@ -33,11 +53,87 @@ type Config struct {
 	ArchiveUsername string
 	ArchivePassword string
 
+	// Proxy for archive downloads (empty = HTTP_PROXY/HTTPS_PROXY/NO_PROXY from the environment)
+	FetchProxyURL string
+
+	// Tries per archive month before a download fails; transient errors only
+	DownloadMaxAttempts int
+
 	// Environment mode (dev or production)
 	ENV string
 
+	// Log verbosity: error, warn, info, or debug
+	LogLevel string
+
+	// Cap on months one sync processes (0 = no cap); the rest is left for the next run
+	MaxMonthsPerSync int
+	// Which end of a capped range is synced first: "oldest" or "newest"
+	SyncOrder string
+
 	// Cleanup mbox files after ingestion (production behavior)
 	CleanupMboxFiles bool
+
+	// Regex for mbox "From " separator lines (empty = parser default)
+	MboxSeparatorRegex string
+
+	// Store sanitized HTML parts in messages.body_html alongside the text body
+	StoreHTMLBody bool
+
+	// Pagination: default and maximum page size for list endpoints
+	DefaultPageSize int
+	MaxPageSize     int
+
+	// Interval between scheduled archive syncs (0 = scheduler disabled)
+	SyncInterval time.Duration
+
+	// Announcement detection: subject regexes (";"-separated) and sender
+	// addresses (","-separated); empty uses the analyzer defaults
+	AnnouncementSubjectPatterns []string
+	AnnouncementSenders         []string
+
+	// Regexes (";"-separated) marking message bodies that contain benchmark
+	// results; empty uses the analyzer defaults
+	BenchmarkPatterns []string
+
+	// Regexes (";"-separated) marking replies that voice agreement, for the
+	// thread consensus flag; empty uses the analyzer defaults
+	ConsensusPatterns []string
+
+	// Sender addresses treated as bots: their messages are stored but they
+	// don't count as thread participants
+	BotSenders []string
+
+	// Time constant of the thread heat score's exponential decay
+	HeatDecay time.Duration
+
+	// How long a client token's "last seen" marker survives without being advanced
+	VisitTokenTTL time.Duration
+
+	// Upper bound on a request's query time; X-Timeout-Ms may only lower it
+	MaxRequestTimeout time.Duration
+
+	// How stored text handles invalid UTF-8: "drop" the bytes or "replace" them with U+FFFD
+	InvalidUTF8 string
+
+	// Title for threads with no subject: "snippet" (root body's first line) or "placeholder"
+	EmptySubjectFallback string
+
+	// Age stalled/abandoned threads from the last non-author message, ignoring self-bumps
+	IgnoreAuthorBumps bool
+
+	// Days a ready-for-committer thread may be idle before it needs committer attention
+	CommitterAttentionDays int
+
+	// Maximum References hops followed when resolving a thread root
+	ThreadMaxDepth int
+
+	// Cap on a message's decoded body; longer bodies are truncated with a marker
+	MaxBodyBytes int
+	// Cap on message-ids kept in a stored References header (0 = keep all)
+	MaxStoredReferences int
+
+	// Bearer token required for sync/upload/reset endpoints (empty = unprotected)
+	AdminToken string
 }
 
 func LoadConfig() *Config {
@@ -46,24 +142,56 @@ func LoadConfig() *Config {
 	cleanupMbox := env == "production"
 
 	return &Config{
-		DatabaseURL:      getEnv("DATABASE_URL", ""),
-		DBHost:           getEnv("DB_HOST", "localhost"),
-		DBPort:           getEnv("DB_PORT", "5432"),
-		DBName:           getEnv("DB_NAME", "pgsql_analyzer"),
-		DBUser:           getEnv("DB_USER", "postgres"),
-		DBPassword:       getEnv("DB_PASSWORD", "postgres"),
-		APIPort:          getEnv("API_PORT", "8080"),
-		APIHost:          getEnv("API_HOST", "0.0.0.0"),
-		MailIMAPHost:     getEnv("MAIL_IMAP_HOST", "imap.gmail.com"),
-		MailIMAPPort:     getEnv("MAIL_IMAP_PORT", "993"),
-		MailUsername:     getEnv("MAIL_USERNAME", ""),
-		MailPassword:     getEnv("MAIL_PASSWORD", ""),
-		MailingListEmail: getEnv("MAILING_LIST_EMAIL", "pgsql-hackers@postgresql.org"),
-		DataDir:          getEnv("DATA_DIR", "./data"),
-		ArchiveUsername:  getEnv("ARCHIVE_USERNAME", "archives"),
-		ArchivePassword:  getEnv("ARCHIVE_PASSWORD", "antispam"),
-		ENV:              env,
-		CleanupMboxFiles: cleanupMbox,
+		DatabaseURL:            getEnv("DATABASE_URL", ""),
+		DBHost:                 getEnv("DB_HOST", "localhost"),
+		DBPort:                 getEnv("DB_PORT", "5432"),
+		DBName:                 getEnv("DB_NAME", "pgsql_analyzer"),
+		DBUser:                 getEnv("DB_USER", "postgres"),
+		DBPassword:             getEnv("DB_PASSWORD", "postgres"),
+		DBSchema:               getEnv("DB_SCHEMA", ""),
+		DBConnectTimeout:       getEnvDuration("DB_CONNECT_TIMEOUT", 30*time.Second),
+		DBMaxOpenConns:         getEnvInt("DB_MAX_OPEN_CONNS", 10),
+		StoreConcurrency:       getEnvInt("STORE_CONCURRENCY", 1),
+		SyncBatchSize:          getEnvInt("SYNC_BATCH_SIZE", 1000),
+		DownloadMaxAttempts:    getEnvInt("DOWNLOAD_MAX_ATTEMPTS", 3),
+		APIPort:                getEnv("API_PORT", "8080"),
+		APIHost:                getEnv("API_HOST", "0.0.0.0"),
+		MailIMAPHost:           getEnv("MAIL_IMAP_HOST", "imap.gmail.com"),
+		MailIMAPPort:           getEnv("MAIL_IMAP_PORT", "993"),
+		MailUsername:           getEnv("MAIL_USERNAME", ""),
+		MailPassword:           getEnv("MAIL_PASSWORD", ""),
+		MailingListEmail:       getEnv("MAILING_LIST_EMAIL", "pgsql-hackers@postgresql.org"),
+		DataDir:                getEnv("DATA_DIR", "./data"),
+		ArchiveUsername:        getEnv("ARCHIVE_USERNAME", "archives"),
+		ArchivePassword:        getEnv("ARCHIVE_PASSWORD", "antispam"),
+		FetchProxyURL:          getEnv("FETCH_PROXY_URL", ""),
+		ENV:                    env,
+		LogLevel:               getEnv("LOG_LEVEL", "info"),
+		CleanupMboxFiles:       cleanupMbox,
+		StoreHTMLBody:          getEnv("STORE_HTML_BODY", "false") == "true",
+		MboxSeparatorRegex:     getEnv("MBOX_SEPARATOR_REGEX", ""),
+		DefaultPageSize:        getEnvInt("DEFAULT_PAGE_SIZE", 50),
+		MaxPageSize:            getEnvInt("MAX_PAGE_SIZE", 500),
+		SyncInterval:           getEnvDuration("SYNC_INTERVAL", 0),
+		MaxMonthsPerSync:       getEnvInt("MAX_MONTHS_PER_SYNC", 0),
+		SyncOrder:              getEnv("SYNC_ORDER", SyncOrderOldest),
+		HeatDecay:              getEnvDuration("HEAT_DECAY", 48*time.Hour),
+		VisitTokenTTL:          getEnvDuration("VISIT_TOKEN_TTL", 90*24*time.Hour),
+		MaxRequestTimeout:      getEnvDuration("MAX_REQUEST_TIMEOUT", 60*time.Second),
+		EmptySubjectFallback:   getEnv("EMPTY_SUBJECT_FALLBACK", "snippet"),
+		InvalidUTF8:            getEnv("INVALID_UTF8", "drop"),
+		CommitterAttentionDays: getEnvInt("COMMITTER_ATTENTION_DAYS", 14),
+		IgnoreAuthorBumps:      getEnv("IGNORE_AUTHOR_BUMPS", "false") == "true",
+		ThreadMaxDepth:         getEnvInt("THREAD_MAX_DEPTH", 1000),
+		MaxStoredReferences:    getEnvInt("MAX_STORED_REFERENCES", 0),
+		MaxBodyBytes:           getEnvInt("MAX_BODY_BYTES", 10<<20),
+		AdminToken:             getEnv("ADMIN_TOKEN", ""),
+
+		AnnouncementSubjectPatterns: getEnvList("ANNOUNCEMENT_SUBJECT_PATTERNS", ";"),
+		AnnouncementSenders:         getEnvList("ANNOUNCEMENT_SENDERS", ","),
+		BotSenders:                  getEnvList("BOT_SENDERS", ","),
+		BenchmarkPatterns:           getEnvList("BENCHMARK_PATTERNS", ";"),
+		ConsensusPatterns:           getEnvList("CONSENSUS_PATTERNS", ";"),
 	}
 }
 
@@ -74,3 +202,34 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+func getEnvList(key, sep string) []string {
+	var values []string
+	for _, v := range strings.Split(os.Getenv(key), sep) {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" || value == "0" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		log.Printf("Invalid %s %q, using %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return d
+}
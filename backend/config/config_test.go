@@ -0,0 +1,26 @@
+package config
+
+import "testing"
+
+func TestLoadConfigFetchProxyURL(t *testing.T) {
+	t.Setenv("FETCH_PROXY_URL", "http://proxy.internal:3128")
+	if got := LoadConfig().FetchProxyURL; got != "http://proxy.internal:3128" {
+		t.Errorf("FetchProxyURL = %q, want the FETCH_PROXY_URL value", got)
+	}
+
+	t.Setenv("FETCH_PROXY_URL", "")
+	if got := LoadConfig().FetchProxyURL; got != "" {
+		t.Errorf("FetchProxyURL = %q with FETCH_PROXY_URL unset, want empty", got)
+	}
+}
+
+func TestLoadConfigIgnoreAuthorBumps(t *testing.T) {
+	t.Setenv("IGNORE_AUTHOR_BUMPS", "")
+	if LoadConfig().IgnoreAuthorBumps {
+		t.Error("IgnoreAuthorBumps on by default")
+	}
+	t.Setenv("IGNORE_AUTHOR_BUMPS", "true")
+	if !LoadConfig().IgnoreAuthorBumps {
+		t.Error("IGNORE_AUTHOR_BUMPS=true not applied")
+	}
+}
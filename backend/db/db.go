@@ -3,12 +3,25 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
 
 	_ "github.com/lib/pq"
 	"github.com/pgsql-analyzer/backend/config"
 )
 
+// schemaName restricts DB_SCHEMA to plain lowercase identifiers so it can be
+// used unquoted in search_path and DDL
+var schemaName = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
 func InitDB(cfg *config.Config) (*sql.DB, error) {
+	if cfg.DBSchema != "" && !schemaName.MatchString(cfg.DBSchema) {
+		return nil, fmt.Errorf("invalid DB_SCHEMA %q: must be a lowercase identifier", cfg.DBSchema)
+	}
+
 	var connStr string
 	if cfg.DatabaseURL != "" {
 		connStr = cfg.DatabaseURL
@@ -16,21 +29,95 @@ func InitDB(cfg *config.Config) (*sql.DB, error) {
 		connStr = fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 			cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName)
 	}
+	if cfg.DBSchema != "" {
+		var err error
+		if connStr, err = withSearchPath(connStr, cfg.DBSchema); err != nil {
+			return nil, err
+		}
+	}
 
+	// search_path is sent as a startup parameter so every pooled connection gets it
 	db, err := sql.Open("postgres", connStr)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := db.Ping(); err != nil {
+	db.SetMaxOpenConns(cfg.DBMaxOpenConns)
+
+	if err := pingWithRetry(db.Ping, cfg.DBConnectTimeout, time.Sleep); err != nil {
+		db.Close()
 		return nil, err
 	}
 
+	// The schema must exist before RunMigrations creates tables in it
+	if cfg.DBSchema != "" {
+		if _, err := db.Exec("CREATE SCHEMA IF NOT EXISTS " + cfg.DBSchema); err != nil {
+			return nil, fmt.Errorf("failed to create schema %s: %w", cfg.DBSchema, err)
+		}
+	}
+
 	return db, nil
 }
 
-func RunMigrations(db *sql.DB) error {
-	schema := `
+// withSearchPath adds search_path to a connection string, which lib/pq takes
+// either as a postgres:// URL or as keyword=value pairs
+func withSearchPath(connStr, schema string) (string, error) {
+	lower := strings.ToLower(connStr)
+	if !strings.HasPrefix(lower, "postgres://") && !strings.HasPrefix(lower, "postgresql://") {
+		return connStr + " search_path=" + schema, nil
+	}
+	u, err := url.Parse(connStr)
+	if err != nil {
+		return "", fmt.Errorf("invalid DATABASE_URL: %w", err)
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Backoff between startup connection attempts
+const (
+	initialPingBackoff = 500 * time.Millisecond
+	maxPingBackoff     = 5 * time.Second
+)
+
+// pingWithRetry calls ping until it succeeds or window has elapsed, doubling
+// the wait between attempts. Postgres is often still starting when the app
+// comes up under docker-compose, so the first failures are expected.
+func pingWithRetry(ping func() error, window time.Duration, sleep func(time.Duration)) error {
+	backoff := initialPingBackoff
+	var waited time.Duration
+	for attempt := 1; ; attempt++ {
+		err := ping()
+		if err == nil {
+			if attempt > 1 {
+				slog.Info("Database is ready", "attempts", attempt)
+			}
+			return nil
+		}
+		if waited >= window {
+			return fmt.Errorf("database not ready after %d attempts over %s: %w", attempt, waited, err)
+		}
+		if remaining := window - waited; backoff > remaining {
+			backoff = remaining
+		}
+		slog.Warn("Database not ready, retrying", "attempt", attempt, "retry_in", backoff, "error", err)
+		sleep(backoff)
+		waited += backoff
+		backoff *= 2
+		if backoff > maxPingBackoff {
+			backoff = maxPingBackoff
+		}
+	}
+}
+
+// baseSchema is version 1: the tables, columns and indexes as they stood when
+// migrations started being recorded. Databases created before then already
+// have some of it, so every statement is IF NOT EXISTS. Later schema changes
+// are their own steps below; don't add to this one.
+func baseSchema(tx *sql.Tx) error {
+	_, err := tx.Exec(`
 	CREATE TABLE IF NOT EXISTS threads (
 		id VARCHAR(255) PRIMARY KEY,
 		subject TEXT NOT NULL,
@@ -75,14 +162,321 @@ func RunMigrations(db *sql.DB) error {
 		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	);
 
+	ALTER TABLE threads ADD COLUMN IF NOT EXISTS commit_hash VARCHAR(40) DEFAULT '';
+	ALTER TABLE messages ADD COLUMN IF NOT EXISTS body_html TEXT DEFAULT '';
+	ALTER TABLE threads ADD COLUMN IF NOT EXISTS maturity VARCHAR(20) DEFAULT 'review-ready';
+	ALTER TABLE messages ADD COLUMN IF NOT EXISTS size_bytes INT DEFAULT 0;
+	ALTER TABLE messages ADD COLUMN IF NOT EXISTS empty_body BOOLEAN DEFAULT FALSE;
+	ALTER TABLE messages ADD COLUMN IF NOT EXISTS decode_warning TEXT DEFAULT '';
+	ALTER TABLE messages ADD COLUMN IF NOT EXISTS supersedes VARCHAR(255) DEFAULT '';
+	ALTER TABLE messages ADD COLUMN IF NOT EXISTS superseded_by VARCHAR(255) DEFAULT '';
+	ALTER TABLE messages ADD COLUMN IF NOT EXISTS attachments TEXT[] DEFAULT '{}';
+	ALTER TABLE messages ADD COLUMN IF NOT EXISTS reference_ids TEXT[];
+	ALTER TABLE threads ADD COLUMN IF NOT EXISTS patch_count INT DEFAULT 0;
+	ALTER TABLE threads ADD COLUMN IF NOT EXISTS needs_author_action BOOLEAN DEFAULT FALSE;
+	ALTER TABLE threads ADD COLUMN IF NOT EXISTS revived_at TIMESTAMP;
+	ALTER TABLE threads ADD COLUMN IF NOT EXISTS kind VARCHAR(50) DEFAULT 'thread';
+	ALTER TABLE threads ADD COLUMN IF NOT EXISTS ready_for_committer BOOLEAN DEFAULT FALSE;
+	ALTER TABLE threads ADD COLUMN IF NOT EXISTS series_version INT DEFAULT 0;
+	ALTER TABLE threads ADD COLUMN IF NOT EXISTS series_parts_present INT DEFAULT 0;
+	ALTER TABLE threads ADD COLUMN IF NOT EXISTS series_parts_total INT DEFAULT 0;
+	ALTER TABLE threads ADD COLUMN IF NOT EXISTS series_missing INT[] DEFAULT '{}';
+
+	CREATE TABLE IF NOT EXISTS sync_runs (
+		id SERIAL PRIMARY KEY,
+		started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		finished_at TIMESTAMP,
+		months_total INT DEFAULT 0,
+		months_processed INT DEFAULT 0,
+		messages_stored INT DEFAULT 0,
+		interrupted BOOLEAN DEFAULT FALSE
+	);
+
 	CREATE INDEX IF NOT EXISTS idx_messages_thread_id ON messages(thread_id);
 	CREATE INDEX IF NOT EXISTS idx_messages_created_at ON messages(created_at);
 	CREATE INDEX IF NOT EXISTS idx_messages_has_patch ON messages(has_patch);
+	CREATE INDEX IF NOT EXISTS idx_messages_size_bytes ON messages(size_bytes);
 	CREATE INDEX IF NOT EXISTS idx_messages_in_reply_to ON messages(in_reply_to);
+	CREATE INDEX IF NOT EXISTS idx_messages_reference_ids ON messages USING GIN (reference_ids);
 	CREATE INDEX IF NOT EXISTS idx_threads_status ON threads(status);
+	CREATE INDEX IF NOT EXISTS idx_threads_kind ON threads(kind);
+	CREATE INDEX IF NOT EXISTS idx_threads_maturity ON threads(maturity);
 	CREATE INDEX IF NOT EXISTS idx_threads_last_message ON threads(last_message_at);
-	`
+	`)
+	return err
+}
+
+// backfillReferenceIDs fills messages.reference_ids for rows stored before the
+// column existed: every <id> in References plus In-Reply-To, with the domain
+// lowercased the same way the parser normalizes Message-IDs
+func backfillReferenceIDs(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	UPDATE messages SET reference_ids = ARRAY(
+		SELECT DISTINCT regexp_replace(ref, '@[^@]*$', '') || COALESCE(LOWER(SUBSTRING(ref FROM '@[^@]*$')), '')
+		FROM (
+			SELECT (regexp_matches(refers_to, '<([^<>[:space:]]+)>', 'g'))[1] AS ref
+			UNION
+			SELECT NULLIF(in_reply_to, '')
+		) refs
+		WHERE ref IS NOT NULL
+	)
+	WHERE reference_ids IS NULL
+	`)
+	return err
+}
+
+// uniqueThreadRoots enforces one thread per root message-id. Concurrent ingests
+// could previously create duplicate threads for the same root, so existing
+// duplicates are merged into the oldest thread before the index is created.
+func uniqueThreadRoots(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TEMP TABLE IF NOT EXISTS duplicate_threads AS
+		SELECT id, keep_id FROM (
+			SELECT id, FIRST_VALUE(id) OVER (PARTITION BY first_message_id ORDER BY created_at, id) AS keep_id
+			FROM threads
+		) ranked
+		WHERE id <> keep_id;
+
+	UPDATE messages m SET thread_id = d.keep_id
+	FROM duplicate_threads d
+	WHERE m.thread_id = d.id;
+
+	DELETE FROM threads t
+	USING duplicate_threads d
+	WHERE t.id = d.id;
+
+	DROP TABLE duplicate_threads;
+
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_threads_first_message_id ON threads(first_message_id);
+	`)
+	return err
+}
+
+// accentInsensitiveSearch enables unaccent and pg_trgm for subject and author
+// search. unaccent is only STABLE (its dictionary could be swapped), so it is
+// wrapped in an IMMUTABLE function pinned to the extension's dictionary, which
+// lets the trigram indexes below be built on it.
+func accentInsensitiveSearch(tx *sql.Tx) error {
+	schemas := map[string]string{}
+	for _, ext := range []string{"unaccent", "pg_trgm"} {
+		if _, err := tx.Exec("CREATE EXTENSION IF NOT EXISTS " + ext); err != nil {
+			return fmt.Errorf("failed to enable %s extension: %w", ext, err)
+		}
+		// regnamespace output is already quoted where needed
+		var schema string
+		if err := tx.QueryRow(
+			"SELECT extnamespace::regnamespace::text FROM pg_extension WHERE extname = $1", ext,
+		).Scan(&schema); err != nil {
+			return fmt.Errorf("failed to locate %s extension: %w", ext, err)
+		}
+		schemas[ext] = schema
+	}
+
+	_, err := tx.Exec(fmt.Sprintf(`
+	CREATE OR REPLACE FUNCTION immutable_unaccent(text) RETURNS text
+		LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT
+		AS $$ SELECT %[1]s.unaccent('%[1]s.unaccent'::regdictionary, $1) $$;
+
+	CREATE INDEX IF NOT EXISTS idx_threads_subject_unaccent
+		ON threads USING gin (immutable_unaccent(LOWER(subject)) %[2]s.gin_trgm_ops);
+	CREATE INDEX IF NOT EXISTS idx_threads_first_author_unaccent
+		ON threads USING gin (immutable_unaccent(LOWER(first_author)) %[2]s.gin_trgm_ops);
+	`, schemas["unaccent"], schemas["pg_trgm"]))
+	return err
+}
 
-	_, err := db.Exec(schema)
+// sqlStep is a migration step that is a single batch of SQL
+func sqlStep(query string) func(*sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(query)
+		return err
+	}
+}
+
+// Schema steps after version 4. They keep IF NOT EXISTS because builds from
+// before they were split out of baseSchema may already have applied them.
+var (
+	threadLabels = sqlStep(`
+	CREATE TABLE IF NOT EXISTS thread_labels (
+		thread_id VARCHAR(255) NOT NULL REFERENCES threads(id) ON DELETE CASCADE,
+		label VARCHAR(100) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (thread_id, label)
+	);
+	CREATE INDEX IF NOT EXISTS idx_thread_labels_label ON thread_labels(label);
+	`)
+
+	threadHeat = sqlStep(`
+	ALTER TABLE threads ADD COLUMN IF NOT EXISTS heat DOUBLE PRECISION DEFAULT 0;
+	CREATE INDEX IF NOT EXISTS idx_threads_heat ON threads(heat);
+	`)
+
+	messageArchivedAt = sqlStep(`
+	ALTER TABLE messages ADD COLUMN IF NOT EXISTS archived_at TEXT DEFAULT '';
+	`)
+
+	patchStatusHistory = sqlStep(`
+	CREATE TABLE IF NOT EXISTS patch_status_history (
+		thread_id VARCHAR(255) NOT NULL REFERENCES threads(id) ON DELETE CASCADE,
+		message_id VARCHAR(255) NOT NULL,
+		from_status VARCHAR(20) DEFAULT '',
+		to_status VARCHAR(20) NOT NULL,
+		changed_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (thread_id, message_id)
+	);
+	`)
+
+	threadChangeFeed = sqlStep(`
+	CREATE INDEX IF NOT EXISTS idx_threads_updated_at ON threads(updated_at, id);
+	`)
+
+	benchmarkFlags = sqlStep(`
+	ALTER TABLE messages ADD COLUMN IF NOT EXISTS has_benchmarks BOOLEAN DEFAULT FALSE;
+	ALTER TABLE threads ADD COLUMN IF NOT EXISTS has_benchmarks BOOLEAN DEFAULT FALSE;
+	`)
+
+	currentPatchStatus = sqlStep(`
+	ALTER TABLE threads ADD COLUMN IF NOT EXISTS current_patch_status VARCHAR(20) DEFAULT '';
+	`)
+
+	messageTZOffset = sqlStep(`
+	ALTER TABLE messages ADD COLUMN IF NOT EXISTS tz_offset_minutes INT;
+	`)
+
+	messageSenderReplyTo = sqlStep(`
+	ALTER TABLE messages ADD COLUMN IF NOT EXISTS sender TEXT DEFAULT '';
+	ALTER TABLE messages ADD COLUMN IF NOT EXISTS reply_to TEXT DEFAULT '';
+	`)
+
+	clientVisits = sqlStep(`
+	CREATE TABLE IF NOT EXISTS client_visits (
+		token VARCHAR(128) PRIMARY KEY,
+		last_seen_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	`)
+
+	threadForkedFrom = sqlStep(`
+	ALTER TABLE threads ADD COLUMN IF NOT EXISTS forked_from VARCHAR(255) REFERENCES threads(id) ON DELETE SET NULL;
+	`)
+
+	// Month range bookkeeping so a sync capped by MAX_MONTHS_PER_SYNC can be resumed.
+	// requested_* is NULL for incremental syncs; remaining_* is NULL unless the run was capped.
+	syncRunRanges = sqlStep(`
+	ALTER TABLE sync_runs ADD COLUMN IF NOT EXISTS requested_start DATE;
+	ALTER TABLE sync_runs ADD COLUMN IF NOT EXISTS requested_end DATE;
+	ALTER TABLE sync_runs ADD COLUMN IF NOT EXISTS range_start DATE;
+	ALTER TABLE sync_runs ADD COLUMN IF NOT EXISTS range_end DATE;
+	ALTER TABLE sync_runs ADD COLUMN IF NOT EXISTS remaining_start DATE;
+	ALTER TABLE sync_runs ADD COLUMN IF NOT EXISTS remaining_end DATE;
+	`)
+
+	threadConsensus = sqlStep(`
+	ALTER TABLE threads ADD COLUMN IF NOT EXISTS consensus BOOLEAN DEFAULT FALSE;
+	`)
+
+	messageLists = sqlStep(`
+	ALTER TABLE messages ADD COLUMN IF NOT EXISTS lists TEXT[] DEFAULT '{}';
+	`)
+
+	messageThreadConversation = sqlStep(`
+	ALTER TABLE messages ADD COLUMN IF NOT EXISTS thread_conversation TEXT DEFAULT '';
+	CREATE INDEX IF NOT EXISTS idx_messages_thread_conversation ON messages(thread_conversation) WHERE thread_conversation <> '';
+	`)
+)
+
+// normalizeMessageIDs lowercases the domain of every stored message-id, as the
+// parser does since it started normalizing them, so re-synced messages hit
+// ON CONFLICT (message_id) and replies find their parents. Copies of a message
+// stored under differently cased domains are collapsed into the oldest one,
+// and threads whose roots differed only in case are merged as in
+// uniqueThreadRoots. Other thread stats catch up when the thread next changes.
+func normalizeMessageIDs(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE FUNCTION pg_temp.normalize_message_id(id text) RETURNS text
+		LANGUAGE sql IMMUTABLE STRICT
+		AS $$ SELECT regexp_replace(id, '@[^@]*$', '') || COALESCE(LOWER(SUBSTRING(id FROM '@[^@]*$')), '') $$;
+
+	CREATE TEMP TABLE duplicate_messages AS
+		SELECT id FROM (
+			SELECT id, ROW_NUMBER() OVER (PARTITION BY pg_temp.normalize_message_id(message_id) ORDER BY created_at, id) AS n
+			FROM messages
+		) ranked
+		WHERE n > 1;
+
+	DELETE FROM messages m
+	USING duplicate_messages d
+	WHERE m.id = d.id;
+
+	DROP TABLE duplicate_messages;
+
+	UPDATE messages SET message_id = pg_temp.normalize_message_id(message_id)
+	WHERE message_id <> pg_temp.normalize_message_id(message_id);
+	UPDATE messages SET in_reply_to = pg_temp.normalize_message_id(in_reply_to)
+	WHERE in_reply_to <> pg_temp.normalize_message_id(in_reply_to);
+	UPDATE messages SET supersedes = pg_temp.normalize_message_id(supersedes)
+	WHERE supersedes <> pg_temp.normalize_message_id(supersedes);
+	UPDATE messages SET superseded_by = pg_temp.normalize_message_id(superseded_by)
+	WHERE superseded_by <> pg_temp.normalize_message_id(superseded_by);
+	UPDATE messages SET reference_ids = ARRAY(
+		SELECT pg_temp.normalize_message_id(ref)
+		FROM unnest(reference_ids) WITH ORDINALITY AS r(ref, n)
+		GROUP BY 1
+		ORDER BY MIN(n)
+	)
+	WHERE EXISTS (SELECT 1 FROM unnest(reference_ids) AS ref WHERE ref <> pg_temp.normalize_message_id(ref));
+
+	CREATE TEMP TABLE duplicate_threads AS
+		SELECT id, keep_id FROM (
+			SELECT id, FIRST_VALUE(id) OVER (PARTITION BY pg_temp.normalize_message_id(first_message_id) ORDER BY created_at, id) AS keep_id
+			FROM threads
+		) ranked
+		WHERE id <> keep_id;
+
+	UPDATE messages m SET thread_id = d.keep_id
+	FROM duplicate_threads d
+	WHERE m.thread_id = d.id;
+
+	DELETE FROM threads t
+	USING duplicate_threads d
+	WHERE t.id = d.id;
+
+	DROP TABLE duplicate_threads;
+
+	UPDATE threads SET first_message_id = pg_temp.normalize_message_id(first_message_id)
+	WHERE first_message_id <> pg_temp.normalize_message_id(first_message_id);
+
+	DELETE FROM patch_status_history h
+	USING patch_status_history other
+	WHERE h.thread_id = other.thread_id
+	  AND pg_temp.normalize_message_id(h.message_id) = pg_temp.normalize_message_id(other.message_id)
+	  AND h.message_id > other.message_id;
+	UPDATE patch_status_history SET message_id = pg_temp.normalize_message_id(message_id)
+	WHERE message_id <> pg_temp.normalize_message_id(message_id);
+
+	-- Counts must match before refreshThreads next looks at these threads
+	UPDATE threads t SET message_count = s.messages, last_message_at = s.last_at
+	FROM (
+		SELECT thread_id, COUNT(*) AS messages, MAX(created_at) AS last_at
+		FROM messages GROUP BY thread_id
+	) s
+	WHERE t.id = s.thread_id AND t.message_count <> s.messages;
+	DELETE FROM threads t WHERE NOT EXISTS (SELECT 1 FROM messages m WHERE m.thread_id = t.id);
+
+	DROP FUNCTION pg_temp.normalize_message_id(text);
+	`)
 	return err
 }
+
+// backfillReadyForCommitter sets threads.ready_for_committer for threads stored
+// before ingest computed it, the way ThreadAnalyzer.ReadyForCommitter does: the
+// latest patch verdict is "accepted" and no commit has been recorded
+var backfillReadyForCommitter = sqlStep(`
+	UPDATE threads t SET ready_for_committer = COALESCE((
+		SELECT m.patch_status = 'accepted' AND COALESCE(t.commit_hash, '') = ''
+		FROM messages m
+		WHERE m.thread_id = t.id AND m.patch_status IN ('accepted', 'committed', 'rejected')
+		ORDER BY m.created_at DESC, m.message_id DESC
+		LIMIT 1
+	), FALSE);
+`)
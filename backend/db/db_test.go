@@ -0,0 +1,89 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPingWithRetryDelayedReady(t *testing.T) {
+	// The database comes up on the fourth attempt
+	refused := errors.New("connection refused")
+	attempts := 0
+	ping := func() error {
+		attempts++
+		if attempts < 4 {
+			return refused
+		}
+		return nil
+	}
+	var slept []time.Duration
+	sleep := func(d time.Duration) { slept = append(slept, d) }
+
+	if err := pingWithRetry(ping, 30*time.Second, sleep); err != nil {
+		t.Fatalf("pingWithRetry: %v", err)
+	}
+	if attempts != 4 {
+		t.Errorf("%d attempts, want 4", attempts)
+	}
+	want := []time.Duration{500 * time.Millisecond, time.Second, 2 * time.Second}
+	if len(slept) != len(want) {
+		t.Fatalf("slept %v, want %v", slept, want)
+	}
+	for i := range want {
+		if slept[i] != want[i] {
+			t.Errorf("slept %v, want %v", slept, want)
+			break
+		}
+	}
+}
+
+func TestPingWithRetryGivesUp(t *testing.T) {
+	refused := errors.New("connection refused")
+	attempts := 0
+	var waited time.Duration
+	err := pingWithRetry(func() error { attempts++; return refused }, 12*time.Second,
+		func(d time.Duration) {
+			if d > maxPingBackoff {
+				t.Errorf("slept %s, more than the %s cap", d, maxPingBackoff)
+			}
+			waited += d
+		})
+	if !errors.Is(err, refused) {
+		t.Fatalf("err = %v, want the last ping error", err)
+	}
+	if waited != 12*time.Second {
+		t.Errorf("waited %s, want exactly the 12s window", waited)
+	}
+	// 0.5 + 1 + 2 + 4 + 4.5 seconds of waiting, then the final attempt
+	if attempts != 6 {
+		t.Errorf("%d attempts, want 6", attempts)
+	}
+}
+
+func TestPingWithRetryNoWindow(t *testing.T) {
+	attempts := 0
+	err := pingWithRetry(func() error { attempts++; return errors.New("down") }, 0,
+		func(time.Duration) { t.Error("slept with no startup window") })
+	if err == nil || attempts != 1 {
+		t.Errorf("err = %v after %d attempts, want a failure after one", err, attempts)
+	}
+}
+
+func TestWithSearchPath(t *testing.T) {
+	cases := []struct{ in, want string }{
+		{"postgres://u:p@db:5432/app?sslmode=disable", "postgres://u:p@db:5432/app?search_path=test_1&sslmode=disable"},
+		{"postgresql://db/app", "postgresql://db/app?search_path=test_1"},
+		{"host=db port=5432 dbname=app sslmode=disable", "host=db port=5432 dbname=app sslmode=disable search_path=test_1"},
+		{"dbname=app", "dbname=app search_path=test_1"},
+	}
+	for _, c := range cases {
+		got, err := withSearchPath(c.in, "test_1")
+		if err != nil || got != c.want {
+			t.Errorf("withSearchPath(%q) = %q, %v; want %q", c.in, got, err, c.want)
+		}
+	}
+	if _, err := withSearchPath("postgres://db:port/app", "test_1"); err == nil {
+		t.Error("invalid URL accepted")
+	}
+}
@@ -0,0 +1,146 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// migration is one schema step. Each runs once, in its own transaction, and
+// schema_migrations records it as applied in that same transaction.
+type migration struct {
+	Version int
+	Name    string
+	run     func(*sql.Tx) error
+}
+
+// migrations lists every schema step. Append new steps with the next version;
+// never renumber, remove or edit an applied one.
+var migrations = []migration{
+	{Version: 1, Name: "base_schema", run: baseSchema},
+	{Version: 2, Name: "backfill_reference_ids", run: backfillReferenceIDs},
+	{Version: 3, Name: "unique_thread_roots", run: uniqueThreadRoots},
+	{Version: 4, Name: "accent_insensitive_search", run: accentInsensitiveSearch},
+	{Version: 5, Name: "thread_labels", run: threadLabels},
+	{Version: 6, Name: "thread_heat", run: threadHeat},
+	{Version: 7, Name: "message_archived_at", run: messageArchivedAt},
+	{Version: 8, Name: "patch_status_history", run: patchStatusHistory},
+	{Version: 9, Name: "thread_change_feed_index", run: threadChangeFeed},
+	{Version: 10, Name: "benchmark_flags", run: benchmarkFlags},
+	{Version: 11, Name: "current_patch_status", run: currentPatchStatus},
+	{Version: 12, Name: "message_tz_offset", run: messageTZOffset},
+	{Version: 13, Name: "message_sender_reply_to", run: messageSenderReplyTo},
+	{Version: 14, Name: "client_visits", run: clientVisits},
+	{Version: 15, Name: "thread_forked_from", run: threadForkedFrom},
+	{Version: 16, Name: "sync_run_ranges", run: syncRunRanges},
+	{Version: 17, Name: "thread_consensus", run: threadConsensus},
+	{Version: 18, Name: "message_lists", run: messageLists},
+	{Version: 19, Name: "message_thread_conversation", run: messageThreadConversation},
+	{Version: 20, Name: "normalize_message_ids", run: normalizeMessageIDs},
+	{Version: 21, Name: "backfill_ready_for_committer", run: backfillReadyForCommitter},
+}
+
+// SchemaVersion is the version this build expects the database to be at
+func SchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// MigrationStatus is a known migration and when it was first applied
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+func RunMigrations(db *sql.DB) error {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if err := applyMigration(db, m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+	}
+
+	// Runs still open at startup were cut short by a restart
+	_, err := db.Exec(`UPDATE sync_runs SET interrupted = TRUE WHERE finished_at IS NULL`)
+	return err
+}
+
+// applyMigration runs m unless schema_migrations already records it. The table
+// lock serializes instances starting together: the one that waited sees the
+// version recorded and skips it.
+func applyMigration(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("LOCK TABLE schema_migrations IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		return err
+	}
+	var applied bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)", m.Version).Scan(&applied); err != nil {
+		return err
+	}
+	if applied {
+		return nil
+	}
+
+	if err := m.run(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", m.Version, m.Name); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	slog.Info("Applied schema migration", "version", m.Version, "name", m.Name)
+	return nil
+}
+
+// MigrationStatuses returns every migration this build knows, split into those
+// recorded in schema_migrations and those still pending
+func MigrationStatuses(db *sql.DB) (applied, pending []MigrationStatus, err error) {
+	rows, err := db.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	appliedAt := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, nil, err
+		}
+		appliedAt[version] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	applied = make([]MigrationStatus, 0, len(migrations))
+	pending = make([]MigrationStatus, 0)
+	for _, m := range migrations {
+		status := MigrationStatus{Version: m.Version, Name: m.Name}
+		if at, ok := appliedAt[m.Version]; ok {
+			status.AppliedAt = &at
+			applied = append(applied, status)
+		} else {
+			pending = append(pending, status)
+		}
+	}
+	return applied, pending, nil
+}
@@ -0,0 +1,178 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pgsql-analyzer/backend/config"
+)
+
+// testDB connects to TEST_DATABASE_URL inside a throwaway schema that is
+// dropped when the test ends. Tests using it are skipped without the variable.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	database, err := InitDB(&config.Config{DatabaseURL: url, DBSchema: schema, DBMaxOpenConns: 4})
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() {
+		database.Exec("DROP SCHEMA " + schema + " CASCADE")
+		database.Close()
+	})
+	return database
+}
+
+func TestMigrationVersionsIncrease(t *testing.T) {
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %q has version %d, want %d", m.Name, m.Version, i+1)
+		}
+	}
+}
+
+func TestRunMigrationsAppliesEachStepOnce(t *testing.T) {
+	database := testDB(t)
+
+	if err := RunMigrations(database); err != nil {
+		t.Fatalf("first RunMigrations: %v", err)
+	}
+	applied, pending, err := MigrationStatuses(database)
+	if err != nil {
+		t.Fatalf("MigrationStatuses: %v", err)
+	}
+	if len(pending) != 0 || len(applied) != SchemaVersion() {
+		t.Fatalf("applied %d, pending %d after migrating, want %d and 0", len(applied), len(pending), SchemaVersion())
+	}
+
+	// A recorded step isn't run again: dropping what one created and migrating
+	// again must leave it dropped
+	if _, err := database.Exec("DROP TABLE thread_labels"); err != nil {
+		t.Fatalf("drop thread_labels: %v", err)
+	}
+	if err := RunMigrations(database); err != nil {
+		t.Fatalf("second RunMigrations: %v", err)
+	}
+	var exists bool
+	if err := database.QueryRow("SELECT to_regclass('thread_labels') IS NOT NULL").Scan(&exists); err != nil {
+		t.Fatalf("check thread_labels: %v", err)
+	}
+	if exists {
+		t.Error("second RunMigrations re-ran an applied step")
+	}
+}
+
+func TestNormalizeMessageIDsMergesCaseVariants(t *testing.T) {
+	database := testDB(t)
+	if err := RunMigrations(database); err != nil {
+		t.Fatalf("RunMigrations: %v", err)
+	}
+
+	// Rows as stored before ids were normalized: the same root under two
+	// domain casings, each with its own thread, and a reply in each
+	if _, err := database.Exec(`
+		INSERT INTO threads (id, subject, first_message_id, first_author, first_author_email, created_at, message_count) VALUES
+			('t1', 's', 'Root@Example.COM', 'a', 'a@example.com', '2024-01-01', 2),
+			('t2', 's', 'Root@example.com', 'a', 'a@example.com', '2024-01-02', 2);
+		INSERT INTO messages (id, thread_id, message_id, in_reply_to, reference_ids, subject, author, author_email, created_at) VALUES
+			('m1', 't1', 'Root@Example.COM', '', '{}', 's', 'a', 'a@example.com', '2024-01-01'),
+			('m2', 't1', 'reply1@EXAMPLE.org', 'Root@Example.COM', '{Root@Example.COM}', 's', 'b', 'b@example.org', '2024-01-03'),
+			('m3', 't2', 'Root@example.com', '', '{}', 's', 'a', 'a@example.com', '2024-01-01'),
+			('m4', 't2', 'reply2@example.org', 'Root@EXAMPLE.com', '{Root@EXAMPLE.com,Root@example.com}', 's', 'c', 'c@example.org', '2024-01-04');
+	`); err != nil {
+		t.Fatalf("insert fixtures: %v", err)
+	}
+
+	tx, err := database.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := normalizeMessageIDs(tx); err != nil {
+		tx.Rollback()
+		t.Fatalf("normalizeMessageIDs: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	var threads, messages, count int
+	var root string
+	database.QueryRow("SELECT COUNT(*) FROM threads").Scan(&threads)
+	database.QueryRow("SELECT COUNT(*) FROM messages WHERE thread_id = 't1'").Scan(&messages)
+	database.QueryRow("SELECT first_message_id, message_count FROM threads WHERE id = 't1'").Scan(&root, &count)
+	if threads != 1 || messages != 3 || count != 3 || root != "Root@example.com" {
+		t.Errorf("got %d threads, t1 root %q with %d messages (count %d); want 1 thread, Root@example.com, 3, 3",
+			threads, root, messages, count)
+	}
+
+	var unnormalized int
+	database.QueryRow(`
+		SELECT COUNT(*) FROM messages
+		WHERE message_id ~ '@[^@]*[A-Z][^@]*$'
+		   OR in_reply_to ~ '@[^@]*[A-Z][^@]*$'
+		   OR EXISTS (SELECT 1 FROM unnest(reference_ids) r WHERE r ~ '@[^@]*[A-Z][^@]*$')
+	`).Scan(&unnormalized)
+	if unnormalized != 0 {
+		t.Errorf("%d messages still have mixed-case domains", unnormalized)
+	}
+	var refs string
+	database.QueryRow("SELECT array_to_string(reference_ids, ' ') FROM messages WHERE id = 'm4'").Scan(&refs)
+	if refs != "Root@example.com" {
+		t.Errorf("m4 reference_ids = %q, want the one normalized id", refs)
+	}
+}
+
+func TestBackfillReadyForCommitter(t *testing.T) {
+	database := testDB(t)
+	if err := RunMigrations(database); err != nil {
+		t.Fatalf("RunMigrations: %v", err)
+	}
+
+	// Threads stored before ingest set the flag: one whose latest verdict is
+	// "accepted", one accepted and then committed, one with no verdict
+	_, err := database.Exec(`
+		INSERT INTO threads (id, subject, first_message_id, first_author, first_author_email, created_at, last_message_at) VALUES
+			('ready', 'a', 'a1@example.com', 'A', 'a@example.com', NOW(), NOW()),
+			('committed', 'b', 'b1@example.com', 'A', 'a@example.com', NOW(), NOW()),
+			('plain', 'c', 'c1@example.com', 'A', 'a@example.com', NOW(), NOW());
+		INSERT INTO messages (id, thread_id, message_id, subject, author, author_email, created_at, patch_status) VALUES
+			('m1', 'ready', 'a1@example.com', 'a', 'A', 'a@example.com', NOW() - INTERVAL '2 days', ''),
+			('m2', 'ready', 'a2@example.com', 'a', 'B', 'b@example.com', NOW() - INTERVAL '1 day', 'accepted'),
+			('m3', 'committed', 'b1@example.com', 'b', 'A', 'a@example.com', NOW() - INTERVAL '2 days', 'accepted'),
+			('m4', 'committed', 'b2@example.com', 'b', 'B', 'b@example.com', NOW() - INTERVAL '1 day', 'committed'),
+			('m5', 'plain', 'c1@example.com', 'c', 'A', 'a@example.com', NOW(), '');
+	`)
+	if err != nil {
+		t.Fatalf("seed threads: %v", err)
+	}
+
+	tx, err := database.Begin()
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	if err := backfillReadyForCommitter(tx); err != nil {
+		tx.Rollback()
+		t.Fatalf("backfillReadyForCommitter: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	want := map[string]bool{"ready": true, "committed": false, "plain": false}
+	for id, wantReady := range want {
+		var ready bool
+		if err := database.QueryRow("SELECT ready_for_committer FROM threads WHERE id = $1", id).Scan(&ready); err != nil {
+			t.Fatalf("query %s: %v", id, err)
+		}
+		if ready != wantReady {
+			t.Errorf("thread %s: ready_for_committer = %v, want %v", id, ready, wantReady)
+		}
+	}
+}
@@ -1,13 +1,21 @@
 package fetcher
 
 import (
+	"compress/gzip"
+	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"math/rand"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -16,28 +24,155 @@ const (
 	ArchiveBaseURL = "https://www.postgresql.org/list/pgsql-hackers/mbox"
 	// UserAgent identifies the client to the archive server.
 	UserAgent = "pgsql-hackers-viewer/1.0"
+	// DefaultListName is the mailing list synced by default.
+	DefaultListName = "pgsql-hackers"
 )
 
+// listNamePattern restricts list names to what postgresql.org uses (e.g. pgsql-hackers),
+// which also rules out path separators and dot segments.
+var listNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+
+// httpClient is used for all archive downloads. By default it honors
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY; SetProxy overrides that.
+var httpClient = newHTTPClient(http.ProxyFromEnvironment)
+
+// archiveBaseURL is where DownloadMonth fetches from; tests point it at a local server
+var archiveBaseURL = ArchiveBaseURL
+
+func newHTTPClient(proxy func(*http.Request) (*url.URL, error)) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	return &http.Client{Timeout: 5 * time.Minute, Transport: transport}
+}
+
+// SetProxy sends archive downloads through proxyURL (e.g. http://proxy:3128).
+// An empty proxyURL restores the environment-based proxy settings.
+// It should be called before any download starts.
+func SetProxy(proxyURL string) error {
+	if proxyURL == "" {
+		httpClient = newHTTPClient(http.ProxyFromEnvironment)
+		return nil
+	}
+	u, err := url.Parse(proxyURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("invalid proxy URL %q", proxyURL)
+	}
+	httpClient = newHTTPClient(http.ProxyURL(u))
+	return nil
+}
+
+// MonthFileName returns the archive file name for a list's month, e.g. pgsql-hackers.202512.
+// The list name and date are validated since they end up in both a URL and a local path.
+func MonthFileName(listName string, year, month int) (string, error) {
+	if !listNamePattern.MatchString(listName) {
+		return "", fmt.Errorf("invalid list name %q", listName)
+	}
+	if year < 1990 || year > 9999 {
+		return "", fmt.Errorf("invalid year %d", year)
+	}
+	if month < 1 || month > 12 {
+		return "", fmt.Errorf("invalid month %d", month)
+	}
+	return fmt.Sprintf("%s.%04d%02d", listName, year, month), nil
+}
+
+// MonthFilePath returns the local path for a list's month inside dataDir, refusing
+// any result that would escape dataDir.
+func MonthFilePath(dataDir, listName string, year, month int) (string, error) {
+	name, err := MonthFileName(listName, year, month)
+	if err != nil {
+		return "", err
+	}
+	base := filepath.Base(filepath.Clean(name))
+	if base != name {
+		return "", fmt.Errorf("unsafe file name %q", name)
+	}
+	return filepath.Join(dataDir, base), nil
+}
+
 // DownloadMonth downloads the monthly mbox file for the given year and month
 // from the PostgreSQL mailing list archive and saves it to dataDir.
 // username/password are used for HTTP Basic Auth (required by postgresql.org for raw mbox).
 // Filename format: pgsql-hackers.YYYYMM (e.g. pgsql-hackers.202512).
 // Returns the local file path, or error if download fails.
 // If skipIfExists is true and the file already exists, it will return the path without downloading.
-func DownloadMonth(dataDir, username, password string, year, month int, skipIfExists bool) (string, error) {
-	name := fmt.Sprintf("pgsql-hackers.%04d%02d", year, month)
-	url := ArchiveBaseURL + "/" + name
-	destPath := filepath.Join(dataDir, name)
+// Network errors and 429/5xx responses are retried with backoff, up to MaxAttempts tries in all.
+// Cancelling ctx aborts the request in flight and any wait before a retry.
+func DownloadMonth(ctx context.Context, dataDir, username, password string, year, month int, skipIfExists bool) (string, error) {
+	destPath, err := MonthFilePath(dataDir, DefaultListName, year, month)
+	if err != nil {
+		return "", err
+	}
+	name := filepath.Base(destPath)
+	archiveURL := archiveBaseURL + "/" + name
 
 	// Check if file already exists and we should skip download
 	if skipIfExists {
 		if _, err := os.Stat(destPath); err == nil {
-			log.Printf("Using cached mbox file: %s", destPath)
+			slog.Info("Using cached mbox file", "path", destPath)
 			return destPath, nil
 		}
 	}
 
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return "", fmt.Errorf("create data dir: %w", err)
+	}
+
+	for attempt := 1; ; attempt++ {
+		path, err := downloadAttempt(ctx, archiveURL, name, destPath, username, password)
+		var transient *transientError
+		if err == nil || attempt >= MaxAttempts || !errors.As(err, &transient) || ctx.Err() != nil {
+			return path, err
+		}
+		delay := retryDelay(attempt)
+		slog.Warn("Mbox download failed, retrying", "name", name, "attempt", attempt, "retry_in", delay, "error", err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return "", fmt.Errorf("download %s: %w", archiveURL, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// MaxAttempts is how many times DownloadMonth tries a month before returning
+// the last error. Only network errors and 429/5xx responses are retried.
+var MaxAttempts = 3
+
+// RetryBaseDelay is the wait before the first retry; it doubles with each
+// further attempt, with jitter so parallel downloads don't retry in lockstep
+var RetryBaseDelay = 2 * time.Second
+
+// retryDelay returns the wait after the given failed attempt: half the
+// exponential delay plus a random share of the other half
+func retryDelay(attempt int) time.Duration {
+	d := RetryBaseDelay << (attempt - 1)
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// transientError marks a download failure worth retrying
+type transientError struct{ err error }
+
+func (e *transientError) Error() string { return e.err.Error() }
+func (e *transientError) Unwrap() error { return e.err }
+
+// retryableStatus reports whether a response status may succeed on retry:
+// rate limiting and server errors, but never e.g. 401 or 404
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// downloadAttempt makes one request for the month, resuming a partial
+// download left by an earlier attempt when possible
+func downloadAttempt(ctx context.Context, archiveURL, name, destPath, username, password string) (string, error) {
+	// Download into a .part file next to the destination and only rename it into
+	// place once complete, so an interrupted transfer can be resumed
+	partPath := destPath + PartSuffix
+	validatorPath := partPath + ValidatorSuffix
+	offset, validator := resumeState(partPath, validatorPath)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, archiveURL, nil)
 	if err != nil {
 		return "", fmt.Errorf("create request: %w", err)
 	}
@@ -45,38 +180,133 @@ func DownloadMonth(dataDir, username, password string, year, month int, skipIfEx
 	if username != "" && password != "" {
 		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(username+":"+password)))
 	}
+	if offset > 0 {
+		// If-Range makes the server send the whole file instead if it changed since
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", validator)
+	} else {
+		// The .part file always holds the decoded mbox, so only a fresh download
+		// can take gzip: a range of the gzipped body would index compressed bytes.
+		// Setting the header ourselves turns off Go's transparent decompression.
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
 
-	client := &http.Client{Timeout: 5 * time.Minute}
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("download %s: %w", url, err)
+		return "", &transientError{fmt.Errorf("download %s: %w", archiveURL, err)}
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("download %s: status %s", url, resp.Status)
+	var f *os.File
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0 && contentRangeStart(resp) == offset:
+		slog.Info("Resuming mbox download", "name", name, "offset", offset)
+		f, err = os.OpenFile(partPath, os.O_WRONLY|os.O_APPEND, 0644)
+	case resp.StatusCode == http.StatusOK:
+		// Full body: either a fresh download, or the server ignored/refused the range
+		offset = 0
+		saveValidator(validatorPath, resp)
+		f, err = os.Create(partPath)
+	default:
+		if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+			// The partial file doesn't match the remote one; start over next time
+			os.Remove(partPath)
+			os.Remove(validatorPath)
+		}
+		err := fmt.Errorf("download %s: status %s", archiveURL, resp.Status)
+		if retryableStatus(resp.StatusCode) {
+			return "", &transientError{err}
+		}
+		return "", err
+	}
+	if err != nil {
+		return "", fmt.Errorf("create file %s: %w", partPath, err)
 	}
 
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		return "", fmt.Errorf("create data dir: %w", err)
+	// Servers may ignore Accept-Encoding and send plain text, so only a body
+	// that says it is gzipped is decompressed
+	body := io.Reader(resp.Body)
+	if strings.EqualFold(strings.TrimSpace(resp.Header.Get("Content-Encoding")), "gzip") {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			f.Close()
+			os.Remove(partPath)
+			os.Remove(validatorPath)
+			return "", fmt.Errorf("decompress %s: %w", archiveURL, err)
+		}
+		defer gz.Close()
+		body = gz
 	}
 
-	f, err := os.Create(destPath)
+	n, err := io.Copy(f, body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
 	if err != nil {
-		return "", fmt.Errorf("create file %s: %w", destPath, err)
+		// Keep the .part file so the next attempt can resume from it
+		return "", &transientError{fmt.Errorf("write %s: %w", partPath, err)}
 	}
-	defer f.Close()
 
-	n, err := io.Copy(f, resp.Body)
-	if err != nil {
-		os.Remove(destPath)
-		return "", fmt.Errorf("write %s: %w", destPath, err)
+	if err := os.Rename(partPath, destPath); err != nil {
+		return "", fmt.Errorf("rename %s: %w", partPath, err)
 	}
+	os.Remove(validatorPath)
 
-	log.Printf("Downloaded %s (%d bytes) to %s", name, n, destPath)
+	slog.Info("Downloaded mbox file", "name", name, "bytes", offset+n, "resumed_from", offset, "path", destPath)
 	return destPath, nil
 }
 
+// PartSuffix and ValidatorSuffix name the files of an in-progress download:
+// the partial body and the ETag/Last-Modified it was fetched against.
+const (
+	PartSuffix      = ".part"
+	ValidatorSuffix = ".validator"
+)
+
+// resumeState returns the size of a partial download and the validator needed to
+// resume it, or zero if there's nothing usable to resume
+func resumeState(partPath, validatorPath string) (int64, string) {
+	info, err := os.Stat(partPath)
+	if err != nil || info.Size() == 0 {
+		return 0, ""
+	}
+	validator, err := os.ReadFile(validatorPath)
+	if err != nil || len(validator) == 0 {
+		// Without a validator a resumed range could splice two different files
+		return 0, ""
+	}
+	return info.Size(), string(validator)
+}
+
+// saveValidator records the response's ETag (or Last-Modified) for a later If-Range.
+// Servers that send neither can't be resumed safely, so no validator is written.
+func saveValidator(validatorPath string, resp *http.Response) {
+	validator := resp.Header.Get("ETag")
+	if validator == "" || strings.HasPrefix(validator, "W/") {
+		// Weak ETags aren't allowed in If-Range
+		validator = resp.Header.Get("Last-Modified")
+	}
+	if validator == "" || resp.Header.Get("Accept-Ranges") == "none" {
+		os.Remove(validatorPath)
+		return
+	}
+	if err := os.WriteFile(validatorPath, []byte(validator), 0644); err != nil {
+		slog.Warn("Could not save download validator", "path", validatorPath, "error", err)
+	}
+}
+
+// contentRangeStart returns the first byte position of a 206 response, or -1
+func contentRangeStart(resp *http.Response) int64 {
+	var start, end, size int64
+	cr := resp.Header.Get("Content-Range")
+	if _, err := fmt.Sscanf(cr, "bytes %d-%d/%d", &start, &end, &size); err != nil {
+		if _, err := fmt.Sscanf(cr, "bytes %d-%d/*", &start, &end); err != nil {
+			return -1
+		}
+	}
+	return start
+}
+
 // MonthDownload represents a month to download and its result
 type MonthDownload struct {
 	Year  int
@@ -95,7 +325,19 @@ type MonthResult struct {
 // DownloadMonthsConcurrent downloads multiple months in parallel with a limited number of workers.
 // Returns a slice of results (one per month) in the order they complete.
 // If skipIfExists is true, existing files will not be re-downloaded.
-func DownloadMonthsConcurrent(dataDir, username, password string, months []MonthDownload, workers int, skipIfExists bool) []MonthResult {
+func DownloadMonthsConcurrent(ctx context.Context, dataDir, username, password string, months []MonthDownload, workers int, skipIfExists bool) []MonthResult {
+	var out []MonthResult
+	for result := range DownloadMonthsStream(ctx, dataDir, username, password, months, workers, skipIfExists) {
+		out = append(out, result)
+	}
+	return out
+}
+
+// DownloadMonthsStream downloads multiple months in parallel with a limited number of workers
+// and yields each result as soon as it completes, so callers can start processing early months
+// while later ones are still downloading. The channel is closed after every month is attempted,
+// or once ctx is cancelled: months not yet started then yield no result.
+func DownloadMonthsStream(ctx context.Context, dataDir, username, password string, months []MonthDownload, workers int, skipIfExists bool) <-chan MonthResult {
 	if workers <= 0 {
 		workers = 3 // default to 3 workers
 	}
@@ -104,8 +346,13 @@ func DownloadMonthsConcurrent(dataDir, username, password string, months []Month
 	results := make(chan MonthResult, len(months))
 
 	// Start worker pool
+	var wg sync.WaitGroup
 	for w := 0; w < workers; w++ {
-		go downloadWorker(jobs, results, dataDir, username, password, skipIfExists)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			downloadWorker(ctx, jobs, results, dataDir, username, password, skipIfExists)
+		}()
 	}
 
 	// Send jobs to workers
@@ -114,22 +361,23 @@ func DownloadMonthsConcurrent(dataDir, username, password string, months []Month
 	}
 	close(jobs)
 
-	// Collect results
-	var out []MonthResult
-	for i := 0; i < len(months); i++ {
-		result := <-results
-		out = append(out, result)
-	}
-	close(results)
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
 
-	return out
+	return results
 }
 
-// downloadWorker processes download jobs from the jobs channel
-func downloadWorker(jobs <-chan MonthDownload, results chan<- MonthResult, dataDir, username, password string, skipIfExists bool) {
+// downloadWorker processes download jobs from the jobs channel until it is
+// drained or ctx is cancelled
+func downloadWorker(ctx context.Context, jobs <-chan MonthDownload, results chan<- MonthResult, dataDir, username, password string, skipIfExists bool) {
 	for job := range jobs {
+		if ctx.Err() != nil {
+			return
+		}
 		start := time.Now()
-		path, err := DownloadMonth(dataDir, username, password, job.Year, job.Month, skipIfExists)
+		path, err := DownloadMonth(ctx, dataDir, username, password, job.Year, job.Month, skipIfExists)
 		results <- MonthResult{
 			Year:     job.Year,
 			Month:    job.Month,
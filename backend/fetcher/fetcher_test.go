@@ -0,0 +1,295 @@
+package fetcher
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSetProxyRoutesDownloads(t *testing.T) {
+	var proxied atomic.Int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A forward proxy receives the absolute URL of the origin request
+		if r.URL.Host != "archive.invalid" {
+			http.Error(w, "unexpected target "+r.URL.String(), http.StatusBadGateway)
+			return
+		}
+		proxied.Add(1)
+		w.Write([]byte("From a@example.com Fri Feb  2 12:00:00 2024\n"))
+	}))
+	defer proxy.Close()
+
+	if err := SetProxy(proxy.URL); err != nil {
+		t.Fatalf("SetProxy: %v", err)
+	}
+	defer SetProxy("")
+
+	dest := filepath.Join(t.TempDir(), "pgsql-hackers.202402")
+	path, err := downloadAttempt(context.Background(), "http://archive.invalid/mbox/pgsql-hackers.202402", "pgsql-hackers.202402", dest, "", "")
+	if err != nil {
+		t.Fatalf("download through proxy: %v", err)
+	}
+	if proxied.Load() != 1 {
+		t.Errorf("proxy saw %d requests, want 1", proxied.Load())
+	}
+	if data, err := os.ReadFile(path); err != nil || len(data) == 0 {
+		t.Errorf("downloaded file %s: %q, %v", path, data, err)
+	}
+}
+
+func TestSetProxyRejectsInvalidURL(t *testing.T) {
+	defer SetProxy("")
+	for _, proxyURL := range []string{"proxy:3128", "://bad", "/just/a/path"} {
+		if err := SetProxy(proxyURL); err == nil {
+			t.Errorf("SetProxy(%q) succeeded, want an error", proxyURL)
+		}
+	}
+}
+
+// serveArchive points DownloadMonth at a local server for the test's duration
+func serveArchive(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	old := archiveBaseURL
+	archiveBaseURL = srv.URL
+	t.Cleanup(func() { archiveBaseURL = old })
+}
+
+func TestDownloadMonthsStreamStopsWhenCancelled(t *testing.T) {
+	var started atomic.Int32
+	release := make(chan struct{})
+	defer close(release)
+	serveArchive(t, func(w http.ResponseWriter, r *http.Request) {
+		started.Add(1)
+		w.Write([]byte("From a@example.com Fri Feb  2 12:00:00 2024\n"))
+		w.(http.Flusher).Flush()
+		// Hold every download open until the client gives up
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	})
+
+	months := make([]MonthDownload, 12)
+	for i := range months {
+		months[i] = MonthDownload{Year: 2024, Month: i + 1}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	results := DownloadMonthsStream(ctx, t.TempDir(), "", "", months, 2, false)
+
+	// Cancel mid-way, once both workers are inside a download
+	for started.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+
+	done := make(chan []MonthResult)
+	go func() {
+		var got []MonthResult
+		for result := range results {
+			got = append(got, result)
+		}
+		done <- got
+	}()
+	select {
+	case got := <-done:
+		if len(got) != 2 {
+			t.Errorf("got %d results, want only the 2 in flight", len(got))
+		}
+		for _, result := range got {
+			if !errors.Is(result.Error, context.Canceled) {
+				t.Errorf("%d-%02d: error %v, want context.Canceled", result.Year, result.Month, result.Error)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("downloads did not stop after cancel")
+	}
+	if n := started.Load(); n != 2 {
+		t.Errorf("server saw %d downloads, want no month started after the cancel", n)
+	}
+}
+
+func TestMonthFilePathRejectsUnsafeInput(t *testing.T) {
+	dataDir := t.TempDir()
+	bad := []struct {
+		list        string
+		year, month int
+	}{
+		{"../../etc/passwd", 2024, 1},
+		{"..", 2024, 1},
+		{"/etc/passwd", 2024, 1},
+		{"pgsql-hackers/../../x", 2024, 1},
+		{`..\..\windows`, 2024, 1},
+		{"pgsql-hackers\x00", 2024, 1},
+		{"", 2024, 1},
+		{"Pgsql-Hackers", 2024, 1},
+		{"pgsql-hackers", 2024, 0},
+		{"pgsql-hackers", 2024, 13},
+		{"pgsql-hackers", -1, 1},
+		{"pgsql-hackers", 10000, 1},
+	}
+	for _, c := range bad {
+		if path, err := MonthFilePath(dataDir, c.list, c.year, c.month); err == nil {
+			t.Errorf("MonthFilePath(%q, %d, %d) = %q, want an error", c.list, c.year, c.month, path)
+		}
+	}
+
+	path, err := MonthFilePath(dataDir, "pgsql-hackers", 2024, 2)
+	if err != nil {
+		t.Fatalf("MonthFilePath for a valid month: %v", err)
+	}
+	if want := filepath.Join(dataDir, "pgsql-hackers.202402"); path != want {
+		t.Errorf("path = %q, want %q", path, want)
+	}
+	if filepath.Dir(path) != dataDir {
+		t.Errorf("path %q escapes %q", path, dataDir)
+	}
+}
+
+const testMbox = "From a@example.com Fri Feb  2 12:00:00 2024\nMessage-ID: <a@example.com>\n\nbody\n"
+
+func TestDownloadMonthDecompressesGzip(t *testing.T) {
+	serveArchive(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			t.Errorf("Accept-Encoding = %q, want gzip requested", r.Header.Get("Accept-Encoding"))
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		gz.Write([]byte(testMbox))
+		gz.Close()
+	})
+
+	path, err := DownloadMonth(context.Background(), t.TempDir(), "", "", 2024, 2, false)
+	if err != nil {
+		t.Fatalf("DownloadMonth: %v", err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != testMbox {
+		t.Errorf("saved %q, %v; want the decoded mbox", data, err)
+	}
+}
+
+func TestDownloadMonthPlainWhenGzipIgnored(t *testing.T) {
+	serveArchive(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testMbox))
+	})
+
+	path, err := DownloadMonth(context.Background(), t.TempDir(), "", "", 2024, 2, false)
+	if err != nil {
+		t.Fatalf("DownloadMonth: %v", err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != testMbox {
+		t.Errorf("saved %q, %v; want the mbox as served", data, err)
+	}
+}
+
+func TestDownloadMonthRejectsCorruptGzip(t *testing.T) {
+	serveArchive(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write([]byte(testMbox))
+	})
+
+	dataDir := t.TempDir()
+	if _, err := DownloadMonth(context.Background(), dataDir, "", "", 2024, 2, false); err == nil {
+		t.Fatal("DownloadMonth succeeded on a body that isn't gzip")
+	}
+	if entries, _ := os.ReadDir(dataDir); len(entries) != 0 {
+		t.Errorf("left %d files behind, want none", len(entries))
+	}
+}
+
+// fastRetries shrinks the retry delay for the test's duration
+func fastRetries(t *testing.T) {
+	t.Helper()
+	old := RetryBaseDelay
+	RetryBaseDelay = time.Millisecond
+	t.Cleanup(func() { RetryBaseDelay = old })
+}
+
+func TestDownloadMonthRetriesTransientFailures(t *testing.T) {
+	fastRetries(t)
+	var requests atomic.Int32
+	serveArchive(t, func(w http.ResponseWriter, r *http.Request) {
+		switch requests.Add(1) {
+		case 1:
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+		case 2:
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+		default:
+			w.Write([]byte(testMbox))
+		}
+	})
+
+	path, err := DownloadMonth(context.Background(), t.TempDir(), "", "", 2024, 2, false)
+	if err != nil {
+		t.Fatalf("DownloadMonth: %v", err)
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("server saw %d requests, want 3", n)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != testMbox {
+		t.Errorf("saved %q, %v", data, err)
+	}
+}
+
+func TestDownloadMonthGivesUpAfterMaxAttempts(t *testing.T) {
+	fastRetries(t)
+	var requests atomic.Int32
+	serveArchive(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "down", http.StatusBadGateway)
+	})
+
+	_, err := DownloadMonth(context.Background(), t.TempDir(), "", "", 2024, 2, false)
+	if err == nil || !strings.Contains(err.Error(), "502") {
+		t.Errorf("err = %v, want the last 502", err)
+	}
+	if n := requests.Load(); n != int32(MaxAttempts) {
+		t.Errorf("server saw %d requests, want MaxAttempts (%d)", n, MaxAttempts)
+	}
+
+	// With retries turned off a single failure is final
+	old := MaxAttempts
+	MaxAttempts = 1
+	defer func() { MaxAttempts = old }()
+	requests.Store(0)
+	if _, err := DownloadMonth(context.Background(), t.TempDir(), "", "", 2024, 2, false); err == nil || requests.Load() != 1 {
+		t.Errorf("MaxAttempts=1: err %v after %d requests, want a failure after one", err, requests.Load())
+	}
+}
+
+func TestDownloadMonthDoesNotRetryClientErrors(t *testing.T) {
+	fastRetries(t)
+	for _, status := range []int{http.StatusUnauthorized, http.StatusNotFound} {
+		var requests atomic.Int32
+		serveArchive(t, func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			http.Error(w, http.StatusText(status), status)
+		})
+		if _, err := DownloadMonth(context.Background(), t.TempDir(), "", "", 2024, 2, false); err == nil {
+			t.Errorf("%d: DownloadMonth succeeded", status)
+		}
+		if n := requests.Load(); n != 1 {
+			t.Errorf("%d: server saw %d requests, want no retry", status, n)
+		}
+	}
+}
+
+func TestRetryDelayBackoff(t *testing.T) {
+	for attempt := 1; attempt <= 4; attempt++ {
+		full := RetryBaseDelay << (attempt - 1)
+		for i := 0; i < 50; i++ {
+			if d := retryDelay(attempt); d < full/2 || d > full {
+				t.Fatalf("attempt %d: delay %s outside [%s, %s]", attempt, d, full/2, full)
+			}
+		}
+	}
+}
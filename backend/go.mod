@@ -8,9 +8,13 @@ require (
 	github.com/gorilla/mux v1.8.1
 	github.com/joho/godotenv v1.5.1
 	github.com/lib/pq v1.10.9
+	github.com/microcosm-cc/bluemonday v1.0.26
+	golang.org/x/net v0.17.0
 )
 
 require (
+	github.com/aymerick/douceur v0.2.0 // indirect
 	github.com/emersion/go-sasl v0.0.0-20200509203442-7bfe0ed36a21 // indirect
-	golang.org/x/text v0.12.0 // indirect
+	github.com/gorilla/css v1.0.0 // indirect
+	golang.org/x/text v0.13.0 // indirect
 )
@@ -3,13 +3,18 @@ package main
 import (
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
 
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
+	"github.com/pgsql-analyzer/backend/analyzer"
 	"github.com/pgsql-analyzer/backend/api"
 	"github.com/pgsql-analyzer/backend/config"
 	"github.com/pgsql-analyzer/backend/db"
+	"github.com/pgsql-analyzer/backend/fetcher"
+	"github.com/pgsql-analyzer/backend/parser"
 )
 
 func main() {
@@ -19,6 +24,49 @@ func main() {
 	// Initialize config
 	cfg := config.LoadConfig()
 
+	// Initialize logging; the standard log package is routed through slog at info level
+	initLogging(cfg.LogLevel)
+
+	// Validate the mbox separator pattern before accepting any sync requests
+	if _, err := parser.CompileSeparator(cfg.MboxSeparatorRegex); err != nil {
+		log.Fatalf("Invalid MBOX_SEPARATOR_REGEX: %v", err)
+	}
+
+	// Validate announcement detection patterns the same way
+	if _, err := analyzer.NewAnnouncementDetector(cfg.AnnouncementSubjectPatterns, cfg.AnnouncementSenders); err != nil {
+		log.Fatalf("Invalid ANNOUNCEMENT_SUBJECT_PATTERNS: %v", err)
+	}
+
+	switch cfg.EmptySubjectFallback {
+	case api.SubjectFallbackSnippet, api.SubjectFallbackPlaceholder:
+	default:
+		log.Fatalf("Invalid EMPTY_SUBJECT_FALLBACK %q: want %q or %q",
+			cfg.EmptySubjectFallback, api.SubjectFallbackSnippet, api.SubjectFallbackPlaceholder)
+	}
+
+	switch cfg.SyncOrder {
+	case config.SyncOrderOldest, config.SyncOrderNewest:
+	default:
+		log.Fatalf("Invalid SYNC_ORDER %q: want %q or %q", cfg.SyncOrder, config.SyncOrderOldest, config.SyncOrderNewest)
+	}
+
+	if _, err := analyzer.NewBenchmarkDetector(cfg.BenchmarkPatterns); err != nil {
+		log.Fatalf("Invalid BENCHMARK_PATTERNS: %v", err)
+	}
+	if _, err := analyzer.NewConsensusDetector(cfg.ConsensusPatterns); err != nil {
+		log.Fatalf("Invalid CONSENSUS_PATTERNS: %v", err)
+	}
+
+	if err := api.SetInvalidUTF8Mode(cfg.InvalidUTF8); err != nil {
+		log.Fatalf("Invalid INVALID_UTF8: %v", err)
+	}
+
+	// Route archive downloads through an explicit proxy if one is configured
+	if err := fetcher.SetProxy(cfg.FetchProxyURL); err != nil {
+		log.Fatalf("Invalid FETCH_PROXY_URL: %v", err)
+	}
+	fetcher.MaxAttempts = cfg.DownloadMaxAttempts
+
 	// Initialize database
 	database, err := db.InitDB(cfg)
 	if err != nil {
@@ -37,6 +85,9 @@ func main() {
 	// Set up API routes
 	api.RegisterRoutes(router, database, cfg)
 
+	// Periodic archive sync (disabled unless SYNC_INTERVAL is set)
+	api.StartSyncScheduler(database, cfg)
+
 	// Wrap router with CORS so preflight OPTIONS (unmatched by route) get CORS headers
 	handler := corsMiddleware(router)
 
@@ -48,11 +99,25 @@ func main() {
 	}
 }
 
+func initLogging(level string) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		log.Printf("Invalid LOG_LEVEL %q, defaulting to info", level)
+		lvl = slog.LevelInfo
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: lvl})))
+	// SetDefault routes the log package through slog at info level, where a
+	// higher LOG_LEVEL would drop the handlers' log.Printf errors; keep it direct
+	log.SetOutput(os.Stderr)
+	log.SetFlags(log.LstdFlags)
+}
+
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Client-Token, X-Timeout-Ms")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Page-Limit, X-Page-Offset, X-Next-Cursor")
 
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusOK)
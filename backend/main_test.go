@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"os"
+	"testing"
+)
+
+func TestInitLoggingKeepsStdLogDirect(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+
+	initLogging("error")
+	ctx := context.Background()
+	if !slog.Default().Enabled(ctx, slog.LevelError) || slog.Default().Enabled(ctx, slog.LevelWarn) {
+		t.Fatal("LOG_LEVEL=error not applied to slog")
+	}
+	// Routed through slog, log.Printf would be logged at info and dropped
+	if log.Writer() != os.Stderr {
+		t.Errorf("log package writes to %T, want os.Stderr", log.Writer())
+	}
+}
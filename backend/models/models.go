@@ -6,34 +6,91 @@ import (
 
 // Thread represents a mailing list thread
 type Thread struct {
-	ID               string     `json:"id"`
-	Subject          string     `json:"subject"`
-	FirstMessageID   string     `json:"first_message_id"`
-	FirstAuthor      string     `json:"first_author"`
-	FirstAuthorEmail string     `json:"first_author_email"`
-	CreatedAt        time.Time  `json:"created_at"`
-	UpdatedAt        time.Time  `json:"updated_at"`
-	LastMessageAt    *time.Time `json:"last_message_at,omitempty"`
-	MessageCount     int        `json:"message_count"`
-	UniqueAuthors    int        `json:"unique_authors"`
-	Status           string     `json:"status"` // in-progress, has-patch, stalled-patch, discussion, stalled, abandoned
+	ID                 string       `json:"id"`
+	Subject            string       `json:"subject"`
+	FirstMessageID     string       `json:"first_message_id"`
+	FirstAuthor        string       `json:"first_author"`
+	FirstAuthorEmail   string       `json:"first_author_email"`
+	CreatedAt          time.Time    `json:"created_at"`
+	UpdatedAt          time.Time    `json:"updated_at"`
+	LastMessageAt      *time.Time   `json:"last_message_at,omitempty"`
+	RevivedAt          *time.Time   `json:"revived_at,omitempty"` // last time new mail moved it out of stalled/abandoned
+	MessageCount       int          `json:"message_count"`
+	UniqueAuthors      int          `json:"unique_authors"`
+	PatchCount         int          `json:"patch_count"`
+	Kind               string       `json:"kind"`                           // thread or announcement
+	Status             string       `json:"status"`                         // in-progress, has-patch, stalled-patch, discussion, stalled, abandoned
+	Maturity           string       `json:"maturity"`                       // wip, rfc, review-ready
+	NeedsAuthorAction  bool         `json:"needs_author_action"`            // latest review requests changes
+	ReadyForCommitter  bool         `json:"ready_for_committer"`            // latest patch verdict is "accepted" and nothing is committed yet
+	CurrentPatchStatus string       `json:"current_patch_status,omitempty"` // patch_status of the latest message that has one
+	CommitterAttention bool         `json:"committer_attention"`            // ready for committer but idle past COMMITTER_ATTENTION_DAYS
+	CommitHash         string       `json:"commit_hash,omitempty"`
+	CommitURL          string       `json:"commit_url,omitempty"`
+	PatchSeries        *PatchSeries `json:"patch_series,omitempty"` // latest numbered patch series, if any
+	Heat               float64      `json:"heat"`                   // recency-weighted message volume; see analyzer.RefreshHeat
+	HasBenchmarks      bool         `json:"has_benchmarks"`         // some message contains performance results
+	Consensus          bool         `json:"consensus"`              // at least two participants besides the author voiced agreement
+	ForkedFrom         string       `json:"forked_from,omitempty"`  // thread named by a "(was: ...)" subject, if one matches
+	ForkedFromSubject  string       `json:"forked_from_subject,omitempty"`
+	Labels             []string     `json:"labels"`                 // user-defined triage labels
+	NewActivity        *bool        `json:"new_activity,omitempty"` // messages since the X-Client-Token's last visit; omitted without a marker
+}
+
+// PatchSeries describes how much of a thread's latest git-format-patch series
+// (0001-..., [PATCH n/m]) has been posted
+type PatchSeries struct {
+	Version  int     `json:"version"` // vN from the subject or file names; 1 when unversioned
+	Present  int     `json:"present"`
+	Total    int     `json:"total"`
+	Missing  []int64 `json:"missing,omitempty"` // part numbers not seen
+	Complete bool    `json:"complete"`
 }
 
 // Message represents an email message in a thread
 type Message struct {
-	ID           string    `json:"id"`
-	ThreadID     string    `json:"thread_id"`
-	MessageID    string    `json:"message_id"`
-	InReplyTo    string    `json:"in_reply_to,omitempty"`
-	RefersTo     string    `json:"refers_to,omitempty"`
-	Subject      string    `json:"subject"`
-	Author       string    `json:"author"`
-	AuthorEmail  string    `json:"author_email"`
-	Body         string    `json:"body"`
-	CreatedAt    time.Time `json:"created_at"`
-	HasPatch     bool      `json:"has_patch"`
-	PatchStatus  string    `json:"patch_status,omitempty"` // empty, "proposed", "accepted", "committed", "rejected"
-	CommitFestID string    `json:"commitfest_id,omitempty"`
+	ID              string    `json:"id"`
+	ThreadID        string    `json:"thread_id"`
+	MessageID       string    `json:"message_id"`
+	InReplyTo       string    `json:"in_reply_to,omitempty"`
+	RefersTo        string    `json:"refers_to,omitempty"`
+	Supersedes      string    `json:"supersedes,omitempty"`    // message-id from a Supersedes/Replaces header
+	SupersededBy    string    `json:"superseded_by,omitempty"` // message-id of the newer version of this message
+	Subject         string    `json:"subject"`
+	Author          string    `json:"author"`
+	AuthorEmail     string    `json:"author_email"`
+	Sender          string    `json:"sender,omitempty"`   // Sender header, when present
+	ReplyTo         string    `json:"reply_to,omitempty"` // Reply-To header, when present
+	Body            string    `json:"body"`
+	BodyHTML        string    `json:"body_html,omitempty"` // sanitized HTML part, only when STORE_HTML_BODY is enabled
+	CreatedAt       time.Time `json:"created_at"`
+	HasPatch        bool      `json:"has_patch"`
+	PatchStatus     string    `json:"patch_status,omitempty"` // empty, "proposed", "accepted", "committed", "rejected"
+	CommitFestID    string    `json:"commitfest_id,omitempty"`
+	SizeBytes       int       `json:"size_bytes"`                  // raw size in the mbox, headers included
+	EmptyBody       bool      `json:"empty_body,omitempty"`        // headers only, e.g. administrative notices
+	DecodeWarning   string    `json:"decode_warning,omitempty"`    // why the body fell back to its raw form, or other parse problems worth review
+	Attachments     []string  `json:"attachments,omitempty"`       // file names of named MIME parts
+	ArchiveURL      string    `json:"archive_url,omitempty"`       // Archived-At header if present, else the derived postgresql.org permalink
+	HasBenchmarks   bool      `json:"has_benchmarks,omitempty"`    // body contains benchmark results (pgbench output, tps, % changes)
+	TZOffsetMinutes *int      `json:"tz_offset_minutes,omitempty"` // sender's UTC offset from the Date header, when it stated one
+	Lists           []string  `json:"lists,omitempty"`             // mailing lists the message was seen on; a cross-post has several
+	ThreadIndex     string    `json:"-"`                           // Outlook Thread-Index header; only its conversation id is stored
+	ThreadTopic     string    `json:"-"`                           // Outlook Thread-Topic header, used when Subject is empty
+	OutlookParent   string    `json:"-"`                           // message-id the Thread-Index resolved as parent, for messages without References
+
+	// Position and MessageCount locate the message within its thread (1-based by created_at).
+	// Only populated for single-message responses.
+	Position     int `json:"position,omitempty"`
+	MessageCount int `json:"message_count,omitempty"`
+}
+
+// PatchTransition is a change in a thread's patch status, triggered by a message
+type PatchTransition struct {
+	From      string    `json:"from,omitempty"` // empty for the first status seen
+	To        string    `json:"to"`
+	MessageID string    `json:"message_id"`
+	ChangedAt time.Time `json:"changed_at"`
 }
 
 // ThreadActivity tracks activity metrics for a thread
@@ -67,4 +124,19 @@ type SyncProgress struct {
 	CurrentMonth      string     `json:"current_month"`
 	IsSyncing         bool       `json:"is_syncing"`
 	LastSyncedAt      *time.Time `json:"last_synced_at,omitempty"`
+	RemainingMonths   int        `json:"remaining_months"` // months the current or last sync left for the next run
+}
+
+// SyncRun records one mbox sync run
+type SyncRun struct {
+	ID              int        `json:"id"`
+	StartedAt       time.Time  `json:"started_at"`
+	FinishedAt      *time.Time `json:"finished_at,omitempty"`
+	DurationSeconds float64    `json:"duration_seconds,omitempty"`
+	MonthsTotal     int        `json:"months_total"`
+	MonthsProcessed int        `json:"months_processed"`
+	MessagesStored  int        `json:"messages_stored"`
+	Interrupted     bool       `json:"interrupted"`
+	RemainingFrom   string     `json:"remaining_from,omitempty"` // months MAX_MONTHS_PER_SYNC left for the next run (YYYY-MM)
+	RemainingTo     string     `json:"remaining_to,omitempty"`
 }
@@ -5,15 +5,22 @@ import (
 	"encoding/base64"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"mime"
 	"mime/quotedprintable"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
+	"github.com/microcosm-cc/bluemonday"
 	"github.com/pgsql-analyzer/backend/models"
+	"golang.org/x/net/html/charset"
 )
 
 // ParseStats tracks statistics from parsing mbox files
@@ -25,13 +32,60 @@ type ParseStats struct {
 	InvalidDate        int `json:"invalid_date"`
 	InvalidFrom        int `json:"invalid_from"`
 	MalformedMessageID int `json:"malformed_message_id"`
+
+	// DegenerateSeparators counts bare "From " lines (no envelope sender) taken as boundaries
+	DegenerateSeparators int `json:"degenerate_separators"`
+}
+
+// DefaultSeparatorPattern matches an mbox "From " separator line: the envelope
+// sender followed by an asctime-style date, e.g.
+// "From alice@example.com Fri Feb  2 12:00:00 2024". Requiring the date keeps
+// body lines that merely begin with "From " from starting a new message.
+const DefaultSeparatorPattern = `^From \S+\s+\w{3}\s+\w{3}\s+\d{1,2}\s+\d{1,2}:\d{2}(:\d{2})?\s+(\S+\s+)?\d{4}`
+
+var defaultSeparator = regexp.MustCompile(DefaultSeparatorPattern)
+
+// degenerateSeparator matches a "From " line some malformed archives emit with
+// no envelope sender: bare, or with only the date. Merging across one would
+// fuse distinct messages, so it is still a boundary, but the message after it
+// carries degenerateSeparatorWarning so it can be reviewed. Being so loose, it
+// is only trusted where a boundary can occur; see isDegenerateSeparator.
+var degenerateSeparator = regexp.MustCompile(`^From +$|^From +(?:Mon|Tue|Wed|Thu|Fri|Sat|Sun)\s+\w{3}\s+\d{1,2}\s+\d{1,2}:\d{2}(:\d{2})?\s+(\S+\s+)?\d{4}\s*$`)
+
+const degenerateSeparatorWarning = "mbox separator had no envelope sender"
+
+// CompileSeparator compiles an mbox separator pattern, falling back to
+// DefaultSeparatorPattern when pattern is empty
+func CompileSeparator(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return defaultSeparator, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mbox separator pattern: %w", err)
+	}
+	return re, nil
 }
 
 // MboxParser handles parsing mbox format files
 type MboxParser struct {
 	dataDir string
+
+	// Separator matches lines that start a new message; nil uses DefaultSeparatorPattern
+	Separator *regexp.Regexp
+
+	// KeepHTML stores sanitized text/html parts in Message.BodyHTML instead of
+	// mixing the raw HTML into Message.Body
+	KeepHTML bool
+
+	// MaxBodyBytes bounds a decoded body (and each decoded MIME part); longer
+	// ones are cut with a marker. 0 means no limit.
+	MaxBodyBytes int
 }
 
+// htmlPolicy is the safelist applied to HTML bodies before they are stored
+var htmlPolicy = bluemonday.UGCPolicy()
+
 // NewMboxParser creates a new mbox parser
 func NewMboxParser(dataDir string) *MboxParser {
 	// Ensure data directory exists
@@ -61,7 +115,18 @@ func cleanMessageID(msgid string) (string, error) {
 		return "", fmt.Errorf("invalid message-id format (no @): %s", msgid)
 	}
 
-	return msgid, nil
+	return NormalizeMessageID(msgid), nil
+}
+
+// NormalizeMessageID lowercases the domain part (after the last @) of a
+// Message-ID. Some clients rewrite its case when quoting references; the
+// local part is left alone since its case is significant.
+func NormalizeMessageID(msgid string) string {
+	at := strings.LastIndex(msgid, "@")
+	if at < 0 {
+		return msgid
+	}
+	return msgid[:at+1] + strings.ToLower(msgid[at+1:])
 }
 
 // generateFallbackMessageID creates a unique Message-ID for messages with missing/broken IDs
@@ -78,7 +143,7 @@ func processHeader(msg *models.Message, header, value string, contentTransferEnc
 		if err != nil {
 			// Generate fallback Message-ID for broken headers
 			cleaned = generateFallbackMessageID()
-			log.Printf("WARNING: Generated Message-ID for malformed header '%s': %v", value, err)
+			slog.Debug("Generated Message-ID for malformed header", "header", value, "error", err)
 			if stats != nil {
 				stats.MalformedMessageID++
 			}
@@ -90,15 +155,37 @@ func processHeader(msg *models.Message, header, value string, contentTransferEnc
 		if cleaned != "" {
 			msg.InReplyTo = cleaned
 		}
+	case "supersedes", "replaces":
+		// Message-ID of an earlier message this one replaces (e.g. a resent patch)
+		if cleaned, err := cleanMessageID(value); err == nil {
+			msg.Supersedes = cleaned
+		}
+	case "archived-at":
+		// Canonical archive URL (RFC 5064), e.g. <https://www.postgresql.org/message-id/...>
+		if archived := archivedAtURL(value); archived != "" && msg.ArchiveURL == "" {
+			msg.ArchiveURL = archived
+		}
 	case "references":
 		// Store references as-is (will be parsed by parseReferences in threading code)
 		msg.RefersTo = value
 	case "subject":
-		msg.Subject = normalizeSubject(value)
+		msg.Subject = normalizeSubject(decodeEncodedWord(value))
 	case "from":
-		msg.Author, msg.AuthorEmail = parseFromHeader(value)
+		msg.Author, msg.AuthorEmail = parseFromHeader(decodeEncodedWord(value))
+	case "sender":
+		msg.Sender = strings.TrimSpace(decodeEncodedWord(value))
+	case "reply-to":
+		msg.ReplyTo = strings.TrimSpace(decodeEncodedWord(value))
+	case "thread-index":
+		msg.ThreadIndex = strings.TrimSpace(value)
+	case "thread-topic":
+		msg.ThreadTopic = strings.TrimSpace(decodeEncodedWord(value))
+	case "list-id":
+		if list := listIDName(value); list != "" {
+			msg.Lists = []string{list}
+		}
 	case "date":
-		msg.CreatedAt = parseDate(value)
+		msg.CreatedAt, msg.TZOffsetMinutes = parseDateOffset(value)
 	case "content-transfer-encoding":
 		*contentTransferEncoding = strings.ToLower(strings.TrimSpace(value))
 	case "content-type":
@@ -107,15 +194,83 @@ func processHeader(msg *models.Message, header, value string, contentTransferEnc
 }
 
 // ParseMboxFile parses a single mbox file and returns messages with statistics
+// If filePath does not exist but numbered parts (filePath.001, filePath.002, ...)
+// do, the parts are read in order as one logical mbox.
 func (mp *MboxParser) ParseMboxFile(filePath string) ([]*models.Message, *ParseStats, error) {
-	file, err := os.Open(filePath)
+	var messages []*models.Message
+	stats, err := mp.ParseMboxFileStream(filePath, func(msg *models.Message) error {
+		messages = append(messages, msg)
+		return nil
+	})
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to open mbox file: %w", err)
+		return nil, stats, err
 	}
-	defer file.Close()
+	return messages, stats, nil
+}
 
-	stats := &ParseStats{}
+// ParseMboxFileStream is ParseMboxFile handing each valid message to fn as it
+// is parsed instead of collecting them, so memory use doesn't grow with the
+// file. An error from fn stops parsing and is returned.
+func (mp *MboxParser) ParseMboxFileStream(filePath string, fn func(*models.Message) error) (*ParseStats, error) {
+	parts, err := mboxParts(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	readers := make([]io.Reader, 0, len(parts))
+	for _, part := range parts {
+		file, err := os.Open(part)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open mbox file: %w", err)
+		}
+		defer file.Close()
+		readers = append(readers, file)
+	}
+
+	// Archive months are named after their list, which tags messages that
+	// carry no List-Id header
+	var fileList string
+	if m := monthFileList.FindStringSubmatch(filepath.Base(filePath)); m != nil {
+		fileList = m[1]
+	}
+
+	// Parts are plain byte splits, so a message (or even a line) may straddle a
+	// boundary; concatenating the readers lets the parser see it whole
+	stats, err := mp.ParseMboxStream(io.MultiReader(readers...), func(msg *models.Message) error {
+		if len(msg.Lists) == 0 && fileList != "" {
+			msg.Lists = []string{fileList}
+		}
+		return fn(msg)
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	slog.Info("Parse complete", "file", filePath, "parts", len(parts), "total", stats.Total, "parsed", stats.Parsed, "skipped", stats.Skipped,
+		"invalid_message_id", stats.InvalidMessageID, "invalid_date", stats.InvalidDate, "invalid_from", stats.InvalidFrom,
+		"malformed_message_id", stats.MalformedMessageID)
+
+	return stats, nil
+}
+
+// ParseMbox parses mbox content from r and returns messages with statistics
+func (mp *MboxParser) ParseMbox(r io.Reader) ([]*models.Message, *ParseStats, error) {
 	var messages []*models.Message
+	stats, err := mp.ParseMboxStream(r, func(msg *models.Message) error {
+		messages = append(messages, msg)
+		return nil
+	})
+	if err != nil {
+		return nil, stats, err
+	}
+	return messages, stats, nil
+}
+
+// ParseMboxStream parses mbox content from r, handing each valid message to fn
+// in file order. An error from fn stops parsing and is returned.
+func (mp *MboxParser) ParseMboxStream(r io.Reader, fn func(*models.Message) error) (*ParseStats, error) {
+	stats := &ParseStats{}
+	var fnErr error // first error returned by fn
 	var currentMessage *models.Message
 	var messageBody strings.Builder
 	var contentTransferEncoding string
@@ -123,51 +278,86 @@ func (mp *MboxParser) ParseMboxFile(filePath string) ([]*models.Message, *ParseS
 	inBody := false // Track if we've finished headers and are in body
 	var lastHeader string
 	var lastValue string
+	var separatorLen int // length of the current message's separator line
+	var degenerate bool  // current message started at a degenerate separator
+
+	// flush completes the current message (including a header still pending
+	// when input ends without a blank line or trailing newline) and keeps it if
+	// it passes validation
+	flush := func() {
+		if lastHeader != "" {
+			processHeader(currentMessage, lastHeader, lastValue, &contentTransferEncoding, &contentType, stats)
+		}
+		if strings.TrimSpace(currentMessage.Subject) == "" {
+			currentMessage.Subject = normalizeSubject(currentMessage.ThreadTopic)
+		}
+		mp.finishBody(currentMessage, messageBody.String(), contentTransferEncoding, contentType)
+		attributeAuthor(currentMessage)
+		if degenerate {
+			warn := decodeWarnings{}
+			if currentMessage.DecodeWarning != "" {
+				warn = strings.Split(currentMessage.DecodeWarning, "; ")
+			}
+			warn.add(degenerateSeparatorWarning)
+			currentMessage.DecodeWarning = warn.String()
+		}
+
+		// MANDATORY FIELD VALIDATION
+		if currentMessage.MessageID == "" {
+			slog.Debug("Skipped message missing Message-ID", "subject", currentMessage.Subject)
+			stats.Skipped++
+			stats.InvalidMessageID++
+		} else if currentMessage.Author == "" && currentMessage.AuthorEmail == "" {
+			slog.Debug("Skipped message missing From header", "message_id", currentMessage.MessageID)
+			stats.Skipped++
+			stats.InvalidFrom++
+		} else if currentMessage.CreatedAt.IsZero() || currentMessage.CreatedAt.Year() < 1990 {
+			slog.Debug("Skipped message with invalid date", "message_id", currentMessage.MessageID, "date", currentMessage.CreatedAt)
+			stats.Skipped++
+			stats.InvalidDate++
+		} else {
+			// All validations passed
+			stats.Parsed++
+			fnErr = fn(currentMessage)
+		}
+	}
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
+	// One line of lookahead lets a degenerate separator be checked against the
+	// line after it
+	scanner := bufio.NewScanner(r)
+	more := scanner.Scan()
+	afterBlank := true // the start of input is a boundary position too
+	for more {
 		line := scanner.Text()
+		more = scanner.Scan()
+		next := ""
+		if more {
+			next = scanner.Text()
+		}
+		atBoundary := afterBlank
+		afterBlank = strings.TrimSpace(line) == ""
 
-		// Check for start of new message (mbox format: "From " at line start)
-		if strings.HasPrefix(line, "From ") {
+		// Check for start of new message (mbox format: "From " separator line)
+		separator := mp.isSeparator(line)
+		isDegenerate := !separator && atBoundary && more && mp.isDegenerateSeparator(line, next)
+		if separator || isDegenerate {
 			stats.Total++
 
-			// Save any pending header
-			if lastHeader != "" && currentMessage != nil {
-				processHeader(currentMessage, lastHeader, lastValue, &contentTransferEncoding, &contentType, stats)
-			}
-
 			// Save previous message if it exists and passes validation
 			if currentMessage != nil {
-				currentMessage.Body = decodeMessageBody(messageBody.String(), contentTransferEncoding, contentType)
-				// Detect patches in message body
-				currentMessage.HasPatch = detectPatch(currentMessage.Body, currentMessage.Subject)
-				if currentMessage.HasPatch {
-					currentMessage.PatchStatus = detectPatchStatus(currentMessage.Body, currentMessage.Subject)
-				}
-
-				// MANDATORY FIELD VALIDATION
-				if currentMessage.MessageID == "" {
-					log.Printf("SKIPPED: Message missing Message-ID (Subject: %s)", currentMessage.Subject)
-					stats.Skipped++
-					stats.InvalidMessageID++
-				} else if currentMessage.Author == "" && currentMessage.AuthorEmail == "" {
-					log.Printf("SKIPPED: Message %s missing From header", currentMessage.MessageID)
-					stats.Skipped++
-					stats.InvalidFrom++
-				} else if currentMessage.CreatedAt.IsZero() || currentMessage.CreatedAt.Year() < 1990 {
-					log.Printf("SKIPPED: Message %s has invalid date: %v", currentMessage.MessageID, currentMessage.CreatedAt)
-					stats.Skipped++
-					stats.InvalidDate++
-				} else {
-					// All validations passed
-					messages = append(messages, currentMessage)
-					stats.Parsed++
+				flush()
+				if fnErr != nil {
+					return stats, fnErr
 				}
 			}
 
-			// Start new message
-			currentMessage = &models.Message{}
+			// Start new message; size counts raw bytes from this separator to the next
+			currentMessage = &models.Message{SizeBytes: len(line) + 1}
+			separatorLen = len(line) + 1
+			degenerate = isDegenerate
+			if degenerate {
+				stats.DegenerateSeparators++
+			}
 			messageBody.Reset()
 			contentTransferEncoding = ""
 			contentType = ""
@@ -180,6 +370,7 @@ func (mp *MboxParser) ParseMboxFile(filePath string) ([]*models.Message, *ParseS
 		if currentMessage == nil {
 			continue
 		}
+		currentMessage.SizeBytes += len(line) + 1
 
 		// Blank line separates headers from body
 		if !inBody && strings.TrimSpace(line) == "" {
@@ -199,18 +390,14 @@ func (mp *MboxParser) ParseMboxFile(filePath string) ([]*models.Message, *ParseS
 			if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') {
 				// Continuation of previous header
 				lastValue += " " + strings.TrimSpace(line)
-			} else if strings.Contains(line, ": ") {
+			} else if name, value, ok := splitHeader(line); ok {
 				// New header - process previous one first
 				if lastHeader != "" {
 					processHeader(currentMessage, lastHeader, lastValue, &contentTransferEncoding, &contentType, stats)
 				}
 
-				// Parse new header
-				parts := strings.SplitN(line, ": ", 2)
-				if len(parts) == 2 {
-					lastHeader = strings.ToLower(strings.TrimSpace(parts[0]))
-					lastValue = strings.TrimSpace(parts[1])
-				}
+				lastHeader = name
+				lastValue = value
 			}
 		} else if inBody {
 			// Body content (after blank line)
@@ -219,59 +406,145 @@ func (mp *MboxParser) ParseMboxFile(filePath string) ([]*models.Message, *ParseS
 		}
 	}
 
-	// Save last message with validation
+	// Save the last message. Scanner hands back a final line without its
+	// newline, so content cut off mid-line is kept; a separator that is the very
+	// last line has no message after it and isn't counted.
 	if currentMessage != nil {
-		currentMessage.Body = decodeMessageBody(messageBody.String(), contentTransferEncoding, contentType)
-		// Detect patches in message body
-		currentMessage.HasPatch = detectPatch(currentMessage.Body, currentMessage.Subject)
-		if currentMessage.HasPatch {
-			currentMessage.PatchStatus = detectPatchStatus(currentMessage.Body, currentMessage.Subject)
-		}
-
-		// MANDATORY FIELD VALIDATION
-		if currentMessage.MessageID == "" {
-			log.Printf("SKIPPED: Last message missing Message-ID (Subject: %s)", currentMessage.Subject)
-			stats.Skipped++
-			stats.InvalidMessageID++
-		} else if currentMessage.Author == "" && currentMessage.AuthorEmail == "" {
-			log.Printf("SKIPPED: Message %s missing From header", currentMessage.MessageID)
-			stats.Skipped++
-			stats.InvalidFrom++
-		} else if currentMessage.CreatedAt.IsZero() || currentMessage.CreatedAt.Year() < 1990 {
-			log.Printf("SKIPPED: Message %s has invalid date: %v", currentMessage.MessageID, currentMessage.CreatedAt)
-			stats.Skipped++
-			stats.InvalidDate++
+		if currentMessage.SizeBytes == separatorLen {
+			stats.Total--
+			if degenerate {
+				stats.DegenerateSeparators--
+			}
 		} else {
-			messages = append(messages, currentMessage)
-			stats.Parsed++
+			flush()
+			if fnErr != nil {
+				return stats, fnErr
+			}
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		return nil, stats, fmt.Errorf("error reading mbox file: %w", err)
+		return stats, fmt.Errorf("error reading mbox file: %w", err)
 	}
 
-	log.Printf("Parse complete: %d total, %d parsed, %d skipped (MessageID: %d, Date: %d, From: %d, Malformed: %d)",
-		stats.Total, stats.Parsed, stats.Skipped, stats.InvalidMessageID, stats.InvalidDate, stats.InvalidFrom, stats.MalformedMessageID)
+	return stats, nil
+}
 
-	return messages, stats, nil
+// headerName matches an RFC 5322 field name: printable ASCII other than ":"
+var headerName = regexp.MustCompile(`^[!-9;-~]+$`)
+
+// splitHeader splits a header line at its first colon. The space after the
+// colon is optional, and the value may itself contain colons (e.g. URLs).
+// The name is returned lowercased.
+func splitHeader(line string) (string, string, bool) {
+	name, value, ok := strings.Cut(line, ":")
+	if !ok || !headerName.MatchString(name) {
+		return "", "", false
+	}
+	return strings.ToLower(name), strings.TrimSpace(value), true
+}
+
+// isSeparator reports whether line starts a new message
+func (mp *MboxParser) isSeparator(line string) bool {
+	if !strings.HasPrefix(line, "From ") {
+		return false
+	}
+	if mp.Separator == nil {
+		return defaultSeparator.MatchString(line)
+	}
+	return mp.Separator.MatchString(line)
+}
+
+// isDegenerateSeparator reports whether line is a sender-less "From " line in
+// a boundary position, i.e. followed by a header line. The caller checks that
+// it starts the input or follows a blank line. A custom Separator is taken to
+// describe the archive fully, so this fallback only applies to the default.
+func (mp *MboxParser) isDegenerateSeparator(line, next string) bool {
+	if mp.Separator != nil && mp.Separator != defaultSeparator {
+		return false
+	}
+	if !degenerateSeparator.MatchString(line) {
+		return false
+	}
+	_, _, ok := splitHeader(next)
+	return ok
+}
+
+// finishBody decodes the collected body into msg and runs patch detection.
+// Header-only messages are kept but flagged EmptyBody and never marked as patches.
+func (mp *MboxParser) finishBody(msg *models.Message, rawBody, encoding, contentType string) {
+	msg.Body, msg.BodyHTML, msg.DecodeWarning, msg.Attachments = decodeMessageBody(rawBody, encoding, contentType, mp.KeepHTML, mp.MaxBodyBytes)
+	if strings.TrimSpace(msg.Body) == "" {
+		msg.EmptyBody = true
+		return
+	}
+
+	// Detect patches in message body
+	msg.HasPatch = DetectPatch(msg.Body, msg.Subject)
+	if msg.HasPatch {
+		msg.PatchStatus = DetectPatchStatus(msg.Body, msg.Subject)
+	}
+	msg.CommitFestID = DetectCommitFestID(msg.Body, msg.Subject)
 }
 
-// SaveMboxFile saves an mbox file to the data directory
-func (mp *MboxParser) SaveMboxFile(fileName string, content []byte) (string, error) {
-	// Sanitize filename
-	fileName = filepath.Base(fileName)
+// SaveMboxFile streams an mbox file into the data directory. The content is
+// written to name.part first and renamed into place once fully copied, so a
+// failed or interrupted upload never leaves a truncated mbox behind.
+func (mp *MboxParser) SaveMboxFile(fileName string, content io.Reader) (string, error) {
+	// Sanitize filename: strip any directory components, and reject names that
+	// would resolve to the data directory itself or its parent
+	fileName = filepath.Base(filepath.Clean(fileName))
+	if fileName == "." || fileName == ".." || fileName == string(filepath.Separator) {
+		return "", fmt.Errorf("invalid mbox file name")
+	}
 	filePath := filepath.Join(mp.dataDir, fileName)
+	partPath := filePath + ".part"
 
-	err := os.WriteFile(filePath, content, 0644)
+	f, err := os.OpenFile(partPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 	if err != nil {
 		return "", fmt.Errorf("failed to save mbox file: %w", err)
 	}
+	if _, err := io.Copy(f, content); err != nil {
+		f.Close()
+		os.Remove(partPath)
+		return "", fmt.Errorf("failed to save mbox file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(partPath)
+		return "", fmt.Errorf("failed to save mbox file: %w", err)
+	}
+	if err := os.Rename(partPath, filePath); err != nil {
+		os.Remove(partPath)
+		return "", fmt.Errorf("failed to save mbox file: %w", err)
+	}
 
 	return filePath, nil
 }
 
-// ListMboxFiles returns all mbox files in the data directory
+// partSuffix matches the numbered suffix of a split mbox part, e.g. ".001"
+var partSuffix = regexp.MustCompile(`\.\d{3}$`)
+
+// mboxParts returns the files making up the logical mbox at filePath: the file
+// itself if it exists, otherwise its numbered parts in order
+func mboxParts(filePath string) ([]string, error) {
+	if _, err := os.Stat(filePath); err == nil {
+		return []string{filePath}, nil
+	}
+
+	parts, err := filepath.Glob(filePath + ".[0-9][0-9][0-9]")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mbox parts: %w", err)
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("failed to open mbox file: %s does not exist", filePath)
+	}
+	// Zero-padded suffixes sort correctly as strings
+	sort.Strings(parts)
+	return parts, nil
+}
+
+// ListMboxFiles returns all mbox files in the data directory. Split archives
+// (name.mbox.001, name.mbox.002, ...) are listed once under their logical name.
 func (mp *MboxParser) ListMboxFiles() ([]string, error) {
 	entries, err := os.ReadDir(mp.dataDir)
 	if err != nil {
@@ -279,10 +552,17 @@ func (mp *MboxParser) ListMboxFiles() ([]string, error) {
 	}
 
 	var files []string
+	seen := make(map[string]bool)
 	for _, entry := range entries {
 		name := entry.Name()
+		// Skip directories and in-progress downloads (name.part, name.part.validator)
+		if entry.IsDir() || strings.HasSuffix(name, ".part") || strings.HasSuffix(name, ".part.validator") {
+			continue
+		}
+		name = partSuffix.ReplaceAllString(name, "")
 		// Match files ending in .mbox or starting with pgsql-hackers
-		if !entry.IsDir() && (strings.HasSuffix(name, ".mbox") || strings.HasPrefix(name, "pgsql-hackers")) {
+		if !seen[name] && (strings.HasSuffix(name, ".mbox") || strings.HasPrefix(name, "pgsql-hackers")) {
+			seen[name] = true
 			files = append(files, filepath.Join(mp.dataDir, name))
 		}
 	}
@@ -290,6 +570,35 @@ func (mp *MboxParser) ListMboxFiles() ([]string, error) {
 	return files, nil
 }
 
+// PurgeMboxFiles deletes the mbox files ListMboxFiles would find, including
+// split parts and in-progress downloads, and returns how many files were
+// removed. Only regular files directly in the data directory are touched;
+// subdirectories are left alone.
+func (mp *MboxParser) PurgeMboxFiles() (int, error) {
+	entries, err := os.ReadDir(mp.dataDir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read data directory: %w", err)
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), ".validator")
+		name = strings.TrimSuffix(name, ".part")
+		name = partSuffix.ReplaceAllString(name, "")
+		if !strings.HasSuffix(name, ".mbox") && !strings.HasPrefix(name, "pgsql-hackers") {
+			continue
+		}
+		if err := os.Remove(filepath.Join(mp.dataDir, entry.Name())); err != nil {
+			return removed, fmt.Errorf("failed to remove %s: %w", entry.Name(), err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
 // ParseAllMboxFiles parses all mbox files in the data directory
 func (mp *MboxParser) ParseAllMboxFiles() ([]*models.Message, *ParseStats, error) {
 	files, err := mp.ListMboxFiles()
@@ -300,11 +609,11 @@ func (mp *MboxParser) ParseAllMboxFiles() ([]*models.Message, *ParseStats, error
 	totalStats := &ParseStats{}
 	var allMessages []*models.Message
 	for _, filePath := range files {
-		log.Printf("Parsing file: %s", filePath)
+		slog.Info("Parsing file", "file", filePath)
 		messages, stats, err := mp.ParseMboxFile(filePath)
 		if err != nil {
 			// Log error but continue with other files
-			log.Printf("Error parsing %s: %v", filePath, err)
+			slog.Warn("Error parsing file", "file", filePath, "error", err)
 			continue
 		}
 		// Aggregate stats
@@ -316,22 +625,44 @@ func (mp *MboxParser) ParseAllMboxFiles() ([]*models.Message, *ParseStats, error
 			totalStats.InvalidDate += stats.InvalidDate
 			totalStats.InvalidFrom += stats.InvalidFrom
 			totalStats.MalformedMessageID += stats.MalformedMessageID
+			totalStats.DegenerateSeparators += stats.DegenerateSeparators
 		}
 		allMessages = append(allMessages, messages...)
 	}
 
-	log.Printf("\n=== TOTAL PARSING STATS ===")
-	log.Printf("Total messages found: %d", totalStats.Total)
-	log.Printf("Successfully parsed: %d (%.1f%%)", totalStats.Parsed, float64(totalStats.Parsed)/float64(totalStats.Total)*100)
-	log.Printf("Skipped: %d (%.1f%%)", totalStats.Skipped, float64(totalStats.Skipped)/float64(totalStats.Total)*100)
-	log.Printf("  - Missing/Invalid Message-ID: %d", totalStats.InvalidMessageID)
-	log.Printf("  - Malformed Message-ID (fixed): %d", totalStats.MalformedMessageID)
-	log.Printf("  - Invalid Date: %d", totalStats.InvalidDate)
-	log.Printf("  - Missing From: %d", totalStats.InvalidFrom)
+	slog.Info("Total parsing stats",
+		"total", totalStats.Total,
+		"parsed", totalStats.Parsed,
+		"skipped", totalStats.Skipped,
+		"invalid_message_id", totalStats.InvalidMessageID,
+		"malformed_message_id", totalStats.MalformedMessageID,
+		"invalid_date", totalStats.InvalidDate,
+		"invalid_from", totalStats.InvalidFrom,
+		"degenerate_separators", totalStats.DegenerateSeparators)
 
 	return allMessages, totalStats, nil
 }
 
+// headerDecoder decodes RFC 2047 encoded-words in any charset x/net knows,
+// e.g. windows-1252, iso-8859-2 and koi8-r besides UTF-8 and ISO-8859-1
+var headerDecoder = &mime.WordDecoder{CharsetReader: charset.NewReaderLabel}
+
+// decodeEncodedWord decodes the RFC 2047 encoded-words (=?UTF-8?B?...?=,
+// =?iso-8859-1?Q?...?=) in a header value. Adjacent encoded-words are joined
+// without the whitespace between them, and each may use its own charset. A
+// value that fails to decode, e.g. for an unknown charset, is returned as is.
+func decodeEncodedWord(s string) string {
+	if !strings.Contains(s, "=?") {
+		return s
+	}
+	decoded, err := headerDecoder.DecodeHeader(s)
+	if err != nil {
+		slog.Debug("Keeping undecodable header value", "value", s, "error", err)
+		return s
+	}
+	return decoded
+}
+
 // normalizeSubject removes Re:, Fwd: prefixes from subject
 func normalizeSubject(subject string) string {
 	subject = strings.TrimSpace(subject)
@@ -349,6 +680,64 @@ func normalizeSubject(subject string) string {
 	return subject
 }
 
+// listIDSuffix is the domain part of postgresql.org List-Id values, e.g.
+// <pgsql-hackers.lists.postgresql.org>
+var listIDSuffix = regexp.MustCompile(`\.(lists\.)?postgresql\.org$`)
+
+// monthFileList matches an archive month file name (pgsql-hackers.202512) and
+// captures the list name
+var monthFileList = regexp.MustCompile(`^([a-z0-9][a-z0-9-]*)\.\d{6}$`)
+
+// listIDName returns the list a List-Id header names: the bracketed id,
+// lowercased, with the postgresql.org domain dropped ("pgsql-hackers"). Ids
+// from other hosts are kept whole.
+func listIDName(value string) string {
+	id := strings.TrimSpace(value)
+	if start := strings.LastIndex(id, "<"); start >= 0 {
+		id = id[start+1:]
+		if end := strings.Index(id, ">"); end >= 0 {
+			id = id[:end]
+		}
+	}
+	id = strings.ToLower(strings.TrimSpace(id))
+	if strings.ContainsAny(id, " \t") {
+		return ""
+	}
+	return listIDSuffix.ReplaceAllString(id, "")
+}
+
+// listAddress matches the list's own addresses, which replace the author's in
+// From when the list rewrites it (e.g. for DMARC): pgsql-hackers@lists.postgresql.org
+var listAddress = regexp.MustCompile(`(?i)^pgsql-[a-z0-9-]+@(lists\.)?postgresql\.org$`)
+
+// listRewrittenName matches the " via pgsql-hackers" suffix a rewriting list
+// adds to the author's display name
+var listRewrittenName = regexp.MustCompile(`(?i)\s+via\s+pgsql-[a-z0-9-]+$`)
+
+// attributeAuthor keeps From as the author unless it is missing or a list
+// address, in which case the first of Sender and Reply-To that names a
+// person is used. A display name the list rewrote to "Name via list" keeps
+// the person's name when the fallback address has none.
+func attributeAuthor(msg *models.Message) {
+	if msg.AuthorEmail != "" && !listAddress.MatchString(msg.AuthorEmail) {
+		return
+	}
+	for _, header := range []string{msg.Sender, msg.ReplyTo} {
+		if header == "" {
+			continue
+		}
+		name, email := parseFromHeader(header)
+		if email == "" || listAddress.MatchString(email) {
+			continue
+		}
+		if rewritten := strings.Trim(msg.Author, `"`); name == email && listRewrittenName.MatchString(rewritten) {
+			name = listRewrittenName.ReplaceAllString(rewritten, "")
+		}
+		msg.Author, msg.AuthorEmail = name, email
+		return
+	}
+}
+
 // parseFromHeader extracts name and email from "From" header
 func parseFromHeader(from string) (string, string) {
 	// Handle "Name <email@example.com>" format
@@ -367,6 +756,15 @@ func parseFromHeader(from string) (string, string) {
 
 // parseDate parses RFC2822 date format
 func parseDate(dateStr string) time.Time {
+	t, _ := parseDateOffset(dateStr)
+	return t
+}
+
+// parseDateOffset is parseDate that also returns the sender's UTC offset in
+// minutes, or nil when the header didn't state one reliably: the date didn't
+// parse, or it named a zone abbreviation other than UT/UTC/GMT, which Go
+// can't resolve to an offset
+func parseDateOffset(dateStr string) (time.Time, *int) {
 	// Try common formats
 	formats := []string{
 		time.RFC1123Z,
@@ -378,49 +776,165 @@ func parseDate(dateStr string) time.Time {
 	}
 
 	for _, format := range formats {
-		if t, err := time.Parse(format, dateStr); err == nil {
-			return t
+		t, err := time.Parse(format, dateStr)
+		if err != nil {
+			continue
+		}
+		name, offset := t.Zone()
+		if strings.HasSuffix(format, "-0700") || name == "UT" || name == "UTC" || name == "GMT" {
+			minutes := offset / 60
+			return t, &minutes
 		}
+		return t, nil
 	}
 
 	// Default to now if parsing fails
-	return time.Now()
+	return time.Now(), nil
 }
 
 // decodeMessageBody decodes the message body based on Content-Transfer-Encoding
-// Also handles MIME multipart messages by extracting and decoding each part
-func decodeMessageBody(body, encoding, contentType string) string {
+// Also handles MIME multipart messages by extracting and decoding each part.
+// When splitHTML is set, HTML content is returned sanitized as the second value.
+// The last value lists the file names of any named MIME parts. Decoded text
+// and HTML longer than limit bytes (when limit > 0) are truncated.
+func decodeMessageBody(body, encoding, contentType string, splitHTML bool, limit int) (string, string, string, []string) {
 	body = strings.TrimSpace(body)
+	var warn decodeWarnings
 
 	// Check if this is a multipart MIME message
 	if strings.Contains(strings.ToLower(contentType), "multipart") && strings.Contains(contentType, "boundary=") {
-		return decodeMimeMultipart(body, contentType)
+		text, html, attachments := decodeMimeMultipart(body, contentType, splitHTML, limit, &warn)
+		text = truncateBody(text, limit, &warn)
+		html = truncateBody(html, limit, &warn)
+		return text, sanitizeHTML(html), warn.String(), attachments
+	}
+
+	text := truncateBody(decodeSinglePart(body, encoding, bodyCharset(contentType), limit, &warn), limit, &warn)
+	if splitHTML && strings.Contains(strings.ToLower(contentType), "text/html") {
+		return text, sanitizeHTML(text), warn.String(), nil
+	}
+	return text, "", warn.String(), nil
+}
+
+// truncatedMarker ends a body cut short by truncateBody
+const truncatedMarker = "\n\n[truncated: decoded body exceeded %d bytes]"
+
+// truncateBody cuts s to at most limit bytes, on a rune boundary, and appends
+// truncatedMarker. s is returned unchanged when it fits or limit is 0.
+func truncateBody(s string, limit int, warn *decodeWarnings) string {
+	if limit <= 0 || len(s) <= limit {
+		return s
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	warn.add("decoded body truncated")
+	return s[:cut] + fmt.Sprintf(truncatedMarker, limit)
+}
+
+// readBounded reads r to the end or, when limit > 0, stops after limit+1
+// bytes: enough for truncateBody to see the result is too long without
+// decoding an arbitrarily large input into memory
+func readBounded(r io.Reader, limit int) ([]byte, error) {
+	if limit <= 0 {
+		return io.ReadAll(r)
+	}
+	return io.ReadAll(io.LimitReader(r, int64(limit)+1))
+}
+
+// decodeWarnings collects reasons a body fell back to its undecoded form
+type decodeWarnings []string
+
+func (w *decodeWarnings) add(warning string) {
+	for _, existing := range *w {
+		if existing == warning {
+			return
+		}
+	}
+	*w = append(*w, warning)
+}
+
+func (w decodeWarnings) String() string {
+	return strings.Join(w, "; ")
+}
+
+// sanitizeHTML strips scripts, event handlers and other unsafe markup
+func sanitizeHTML(html string) string {
+	if html == "" {
+		return ""
+	}
+	return strings.TrimSpace(htmlPolicy.Sanitize(html))
+}
+
+// charsetParam matches the charset parameter of a Content-Type value
+var charsetParam = regexp.MustCompile(`(?i)\bcharset\s*=\s*"?([^";\s]+)`)
+
+// bodyCharset returns the lowercased charset declared in a Content-Type, or ""
+func bodyCharset(contentType string) string {
+	if m := charsetParam.FindStringSubmatch(contentType); m != nil {
+		return strings.ToLower(m[1])
+	}
+	return ""
+}
+
+// looksBinary reports whether more than a quarter of b is control characters
+// or, for text declared as UTF-8 or with no charset, invalid UTF-8. A text
+// body mislabeled as base64 can still "decode" if it happens to use only
+// base64 characters, and the result looks like this. Text in Shift_JIS,
+// EUC-JP, KOI8-R and the like is rarely valid UTF-8, so for those only
+// control characters count; ESC is allowed for ISO-2022-JP.
+func looksBinary(b []byte, charset string) bool {
+	if len(b) == 0 {
+		return false
+	}
+	bad := 0
+	if charset != "" && charset != "utf-8" && charset != "us-ascii" {
+		for _, c := range b {
+			if c < 0x20 && c != '\t' && c != '\n' && c != '\r' && c != 0x1b || c == 0x7f {
+				bad++
+			}
+		}
+		return bad*4 > len(b)
 	}
+	for i := 0; i < len(b); {
+		r, size := utf8.DecodeRune(b[i:])
+		if r == utf8.RuneError && size == 1 {
+			bad++
+		} else if r < 0x20 && r != '\t' && r != '\n' && r != '\r' || r == 0x7f {
+			bad += size
+		}
+		i += size
+	}
+	return bad*4 > len(b)
+}
 
+// decodeSinglePart decodes a non-multipart body based on Content-Transfer-Encoding.
+// charset is the declared one, used to judge whether decoded base64 is text.
+func decodeSinglePart(body, encoding, charset string, limit int, warn *decodeWarnings) string {
 	switch encoding {
 	case "base64":
-		// Decode base64 content
-		decoded, err := base64.StdEncoding.DecodeString(body)
+		// Decode base64 content; the decoder skips line breaks
+		original := body
+		decoded, err := readBounded(base64.NewDecoder(base64.StdEncoding, strings.NewReader(body)), limit)
 		if err != nil {
-			// Try with padding if it fails
-			body = strings.ReplaceAll(body, "\n", "")
-			body = strings.ReplaceAll(body, "\r", "")
-			decoded, err = base64.StdEncoding.DecodeString(body)
-			if err != nil {
-				// Return original if decoding fails
-				return body
-			}
+			// Return the undecoded content if decoding fails; it is most likely
+			// text with a wrong Content-Transfer-Encoding
+			warn.add("base64 decode failed")
+			return original
+		}
+		if looksBinary(decoded, charset) {
+			warn.add("base64 decoded to binary, likely mislabeled text")
+			return original
 		}
 		return string(decoded)
 
 	case "quoted-printable":
-		// Decode quoted-printable content
-		reader := quotedprintable.NewReader(strings.NewReader(body))
-		decoded, err := io.ReadAll(reader)
-		if err != nil {
-			return body
+		decoded, strict := decodeQuotedPrintable(body, limit)
+		if !strict {
+			warn.add("quoted-printable decoded leniently")
 		}
-		return string(decoded)
+		return decoded
 
 	case "7bit", "8bit", "binary", "":
 		// No decoding needed
@@ -428,21 +942,32 @@ func decodeMessageBody(body, encoding, contentType string) string {
 
 	default:
 		// Unknown encoding, return as-is
+		warn.add("unknown content-transfer-encoding " + encoding)
 		return body
 	}
 }
 
 // decodeMimeMultipart extracts and decodes text parts from a MIME multipart message
-// This function only extracts text/plain and text/html parts, skipping attachments
-func decodeMimeMultipart(body, contentType string) string {
+// This function only extracts text/plain and text/html parts, skipping attachments.
+// With splitHTML, text/html parts are returned separately instead of in the text result.
+// File names of named parts (usually attachments) are returned as the third value.
+func decodeMimeMultipart(body, contentType string, splitHTML bool, limit int, warn *decodeWarnings) (string, string, []string) {
 	// Extract boundary from Content-Type header
 	boundary := extractBoundary(contentType)
 	if boundary == "" {
 		// No valid boundary found, return original
-		return body
+		warn.add("multipart boundary not found")
+		return body, "", nil
 	}
 
+	var attachments []string
+	// Raw Content-Type/Content-Disposition values of the current part, with
+	// folded continuation lines, for picking out a file name
+	var partNameHeaders string
+	var lastPartHeader string
+
 	var result strings.Builder
+	var htmlResult strings.Builder
 	lines := strings.Split(body, "\n")
 
 	// Track whether we're inside a part
@@ -458,16 +983,15 @@ func decodeMimeMultipart(body, contentType string) string {
 		if strings.HasPrefix(line, "--"+boundary) {
 			// Save previous part only if it was text and not an attachment
 			if inPart && strings.Contains(partContentType, "text/") && !isAttachment {
-				decoded := decodePartBody(partBody.String(), partEncoding)
-				if result.Len() > 0 && len(decoded) > 0 {
-					result.WriteString("\n\n---\n\n")
-				}
-				if len(decoded) > 0 {
-					result.WriteString(decoded)
-				}
+				appendPart(&result, &htmlResult, partBody.String(), partEncoding, partContentType, splitHTML, limit, warn)
+			}
+			if name := partFileName(partNameHeaders); inPart && name != "" {
+				attachments = append(attachments, name)
 			}
 
 			// Reset for new part
+			partNameHeaders = ""
+			lastPartHeader = ""
 			inPart = true
 			partEncoding = ""
 			partContentType = ""
@@ -490,6 +1014,23 @@ func decodeMimeMultipart(body, contentType string) string {
 		// Parse part headers (before empty line)
 		if !headersDone {
 			lineLower := strings.ToLower(line)
+			if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') {
+				// Folded header; file names are often on the continuation line
+				if lastPartHeader == "content-type" || lastPartHeader == "content-disposition" {
+					partNameHeaders += " " + strings.TrimSpace(line)
+				}
+				if lastPartHeader == "content-disposition" && strings.Contains(lineLower, "attachment") {
+					isAttachment = true
+				}
+				continue
+			}
+			lastPartHeader = ""
+			if name, _, ok := strings.Cut(lineLower, ":"); ok {
+				lastPartHeader = strings.TrimSpace(name)
+			}
+			if lastPartHeader == "content-type" || lastPartHeader == "content-disposition" {
+				partNameHeaders += " " + line
+			}
 			if strings.HasPrefix(lineLower, "content-type:") {
 				partContentType = lineLower
 			} else if strings.HasPrefix(lineLower, "content-transfer-encoding:") {
@@ -511,21 +1052,58 @@ func decodeMimeMultipart(body, contentType string) string {
 
 	// Save last part only if it was text and not an attachment
 	if inPart && strings.Contains(partContentType, "text/") && !isAttachment {
-		decoded := decodePartBody(partBody.String(), partEncoding)
-		if result.Len() > 0 && len(decoded) > 0 {
-			result.WriteString("\n\n---\n\n")
-		}
-		if len(decoded) > 0 {
-			result.WriteString(decoded)
-		}
+		appendPart(&result, &htmlResult, partBody.String(), partEncoding, partContentType, splitHTML, limit, warn)
+	}
+	if name := partFileName(partNameHeaders); inPart && name != "" {
+		attachments = append(attachments, name)
 	}
 
+	html := strings.TrimSpace(htmlResult.String())
 	if result.Len() > 0 {
-		return strings.TrimSpace(result.String())
+		return strings.TrimSpace(result.String()), html, attachments
+	}
+
+	// HTML-only message: keep the HTML as the text body too so it isn't lost
+	if html != "" {
+		return html, html, attachments
 	}
 
 	// If no text parts found, return original
-	return body
+	warn.add("no text parts in multipart body")
+	return body, "", attachments
+}
+
+// partFileNamePattern finds filename= (Content-Disposition) or name= (Content-Type)
+var partFileNamePattern = regexp.MustCompile(`(?i)(?:^|[;\s])(?:file)?name="?([^";]+)"?`)
+
+// partFileName returns the file name declared in a part's headers, if any.
+// Any directory components are dropped.
+func partFileName(headers string) string {
+	m := partFileNamePattern.FindStringSubmatch(headers)
+	if m == nil {
+		return ""
+	}
+	name := strings.TrimSpace(m[1])
+	if i := strings.LastIndexAny(name, "/\\"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// appendPart decodes a text part and appends it to the text result, or to the
+// HTML result when splitHTML is set and the part is text/html
+func appendPart(result, htmlResult *strings.Builder, partBody, partEncoding, partContentType string, splitHTML bool, limit int, warn *decodeWarnings) {
+	decoded := truncateBody(decodePartBody(partBody, partEncoding, bodyCharset(partContentType), limit, warn), limit, warn)
+	if len(decoded) == 0 {
+		return
+	}
+	if splitHTML && strings.Contains(partContentType, "text/html") {
+		result = htmlResult
+	}
+	if result.Len() > 0 {
+		result.WriteString("\n\n---\n\n")
+	}
+	result.WriteString(decoded)
 }
 
 // extractBoundary extracts the MIME boundary from Content-Type header
@@ -560,36 +1138,249 @@ func extractBoundary(body string) string {
 	return ""
 }
 
-// decodePartBody decodes a MIME part body based on its encoding
-func decodePartBody(body, encoding string) string {
+// decodeQuotedPrintable decodes a quoted-printable body. Line endings are
+// normalized to CRLF first, as RFC 2045 expects; if the strict decoder still
+// rejects the input, the body is decoded line by line, leaving malformed escapes
+// as-is, so one bad sequence doesn't leave =20 and =3D artifacts everywhere.
+// The result uses LF line endings; strict reports whether the strict decoder succeeded.
+// When limit > 0, decoding stops soon after limit bytes (see readBounded).
+func decodeQuotedPrintable(body string, limit int) (decoded string, strict bool) {
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+	crlf := strings.ReplaceAll(body, "\n", "\r\n")
+
+	out, err := readBounded(quotedprintable.NewReader(strings.NewReader(crlf)), limit)
+	if err == nil {
+		return strings.ReplaceAll(string(out), "\r\n", "\n"), true
+	}
+
+	var b strings.Builder
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		if limit > 0 && b.Len() > limit {
+			break
+		}
+		line = strings.TrimRight(line, " \t\r")
+		soft := strings.HasSuffix(line, "=")
+		if soft {
+			line = line[:len(line)-1]
+		}
+		for j := 0; j < len(line); j++ {
+			if line[j] == '=' && j+2 < len(line) && isHexDigit(line[j+1]) && isHexDigit(line[j+2]) {
+				b.WriteByte(unhex(line[j+1])<<4 | unhex(line[j+2]))
+				j += 2
+				continue
+			}
+			b.WriteByte(line[j])
+		}
+		if !soft && i < len(lines)-1 {
+			b.WriteByte('\n')
+		}
+	}
+	return b.String(), false
+}
+
+func isHexDigit(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+// decodePartBody decodes a MIME part body based on its encoding; charset is
+// the part's declared one, as for decodeSinglePart
+func decodePartBody(body, encoding, charset string, limit int, warn *decodeWarnings) string {
 	body = strings.TrimSpace(body)
 
 	switch encoding {
 	case "base64":
-		// Remove newlines for base64 decoding
-		body = strings.ReplaceAll(body, "\n", "")
-		body = strings.ReplaceAll(body, "\r", "")
-		decoded, err := base64.StdEncoding.DecodeString(body)
+		// The decoder skips line breaks
+		original := body
+		decoded, err := readBounded(base64.NewDecoder(base64.StdEncoding, strings.NewReader(body)), limit)
 		if err != nil {
-			return body
+			warn.add("base64 part decode failed")
+			return original
+		}
+		if looksBinary(decoded, charset) {
+			warn.add("base64 part decoded to binary, likely mislabeled text")
+			return original
 		}
 		return string(decoded)
 
 	case "quoted-printable":
-		reader := quotedprintable.NewReader(strings.NewReader(body))
-		decoded, err := io.ReadAll(reader)
-		if err != nil {
-			return body
+		decoded, strict := decodeQuotedPrintable(body, limit)
+		if !strict {
+			warn.add("quoted-printable part decoded leniently")
 		}
-		return string(decoded)
+		return decoded
 
 	default:
 		return body
 	}
 }
 
-// detectPatch checks if a message contains a patch
-func detectPatch(body, subject string) bool {
+// forwardMarkers are lowercased lines that introduce a forwarded or
+// inline-quoted message from a mail client
+var forwardMarkers = []string{
+	"-------- forwarded message --------",
+	"---------- forwarded message ---------",
+	"begin forwarded message:",
+	"-----original message-----",
+}
+
+// isForwardMarker reports whether a line starts a forwarded message block
+func isForwardMarker(line string) bool {
+	line = strings.ToLower(strings.TrimSpace(line))
+	for _, marker := range forwardMarkers {
+		if line == marker {
+			return true
+		}
+	}
+	return false
+}
+
+// StripForwarded returns the part of body written by the sender, dropping
+// everything from the first forward marker onward. The forwarded chain is
+// quoted context and should not count toward keyword detection or previews.
+func StripForwarded(body string) string {
+	offset := 0
+	for _, line := range strings.SplitAfter(body, "\n") {
+		if isForwardMarker(line) {
+			return strings.TrimSpace(body[:offset])
+		}
+		offset += len(line)
+	}
+	return body
+}
+
+// diffStartPrefixes begin a unified or context diff block
+var diffStartPrefixes = []string{"diff --git ", "diff -", "--- a/", "*** a/", "Index: "}
+
+// diffLinePrefixes continue a diff block once it has started
+var diffLinePrefixes = []string{
+	" ", "+", "-", "!", "@@", "***", "diff ", "index ", "Index: ", "=====",
+	"new file mode", "deleted file mode", "old mode", "new mode",
+	"similarity index", "rename from", "rename to", "Binary files", "\\ No newline",
+}
+
+func hasAnyPrefix(line string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// archiveURLFormat is the postgresql.org permalink for a message-id
+const archiveURLFormat = "https://www.postgresql.org/message-id/%s"
+
+// archivedAtURL returns an Archived-At value as an absolute http(s) URL, or ""
+// when it is anything else. The value ends up in a link, so e.g. javascript:
+// URLs must never get through.
+func archivedAtURL(value string) string {
+	u, err := url.Parse(strings.Trim(strings.TrimSpace(value), "<>"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	return u.String()
+}
+
+// ArchiveURL returns the message's Archived-At URL when it had a usable one,
+// otherwise the permalink derived from its message-id. Stored values are
+// checked again since rows from before the check may hold anything.
+func ArchiveURL(messageID, archivedAt string) string {
+	if archived := archivedAtURL(archivedAt); archived != "" {
+		return archived
+	}
+	return fmt.Sprintf(archiveURLFormat, url.PathEscape(messageID))
+}
+
+// diffBlockEnd returns the index just past the diff block starting at lines[i].
+// Blank lines stay inside it only when more diff follows, since mail clients
+// often strip the leading space of empty context lines.
+func diffBlockEnd(lines []string, i int) int {
+	j := i + 1
+	for j < len(lines) {
+		if hasAnyPrefix(lines[j], diffLinePrefixes) {
+			j++
+			continue
+		}
+		if lines[j] == "" && j+1 < len(lines) && hasAnyPrefix(lines[j+1], diffLinePrefixes) {
+			j++
+			continue
+		}
+		break
+	}
+	return j
+}
+
+// StripDiffs replaces inline unified/context diff blocks in body with a
+// "[patch: N lines]" placeholder, leaving the surrounding prose for reading
+func StripDiffs(body string) string {
+	lines := strings.Split(body, "\n")
+	var out []string
+	for i := 0; i < len(lines); {
+		if !hasAnyPrefix(lines[i], diffStartPrefixes) {
+			out = append(out, lines[i])
+			i++
+			continue
+		}
+		j := diffBlockEnd(lines, i)
+		out = append(out, fmt.Sprintf("[patch: %d lines]", j-i))
+		i = j
+	}
+	return strings.Join(out, "\n")
+}
+
+// DiffStat summarizes the inline unified diffs in a message body
+type DiffStat struct {
+	Files      int `json:"files"`
+	Insertions int `json:"insertions"`
+	Deletions  int `json:"deletions"`
+}
+
+// ComputeDiffStat counts files, added and removed lines across the inline
+// unified diffs in body, like git's --shortstat. Returns nil when body has no
+// inline diff; attached patches aren't seen since only their names are kept.
+func ComputeDiffStat(body string) *DiffStat {
+	lines := strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n")
+	var stat *DiffStat
+	for i := 0; i < len(lines); {
+		if !hasAnyPrefix(lines[i], diffStartPrefixes) {
+			i++
+			continue
+		}
+		if stat == nil {
+			stat = &DiffStat{}
+		}
+		j := diffBlockEnd(lines, i)
+		for _, line := range lines[i:j] {
+			switch {
+			case strings.HasPrefix(line, "+++ "):
+				stat.Files++
+			case strings.HasPrefix(line, "--- "):
+			case strings.HasPrefix(line, "+"):
+				stat.Insertions++
+			case strings.HasPrefix(line, "-"):
+				stat.Deletions++
+			}
+		}
+		i = j
+	}
+	return stat
+}
+
+// DetectPatch checks if a message contains a patch
+func DetectPatch(body, subject string) bool {
+	body = StripForwarded(body)
 	bodyLower := strings.ToLower(body)
 	subjectLower := strings.ToLower(subject)
 
@@ -625,8 +1416,9 @@ func detectPatch(body, subject string) bool {
 	return false
 }
 
-// detectPatchStatus analyzes the message to determine patch status
-func detectPatchStatus(body, subject string) string {
+// DetectPatchStatus analyzes the message to determine patch status
+func DetectPatchStatus(body, subject string) string {
+	body = StripForwarded(body)
 	bodyLower := strings.ToLower(body)
 	subjectLower := strings.ToLower(subject)
 
@@ -656,10 +1448,36 @@ func detectPatchStatus(body, subject string) string {
 	if strings.Contains(bodyLower, "commitfest") ||
 		strings.Contains(bodyLower, "cf entry") ||
 		strings.Contains(subjectLower, "commitfest") {
-		// Extract commitfest ID if possible - for now just mark as proposed
 		return "proposed"
 	}
 
 	// Default status for patches
 	return "proposed"
 }
+
+// CommitFest entry references, each capturing the entry (patch) id
+var (
+	// A link, either the per-commitfest form (/47/4321/) or the stable one (/patch/4321/)
+	commitFestURL = regexp.MustCompile(`(?i)commitfest\.postgresql\.org/(?:patch|\d+)/(\d+)`)
+	// Shorthand naming the commitfest and the entry: "CF 47/4321", "CF#47/4321"
+	commitFestShorthand = regexp.MustCompile(`(?i)\bCF\s*#?\d+/(\d+)\b`)
+	// Shorthand naming only the entry: "CF entry 4321", "commitfest patch #4321"
+	commitFestEntry = regexp.MustCompile(`(?i)\b(?:CF|commitfest)\s+(?:entry|patch)\s*#?(\d+)\b`)
+)
+
+// DetectCommitFestID returns the id of the CommitFest entry a message refers
+// to, or "" when there is none. Links are trusted over shorthand, and within
+// each form the body over the subject. The id is always the entry's: in
+// "/47/4321/" or "CF 47/4321" that is 4321, not the commitfest number 47, and
+// a commitfest number on its own ("CF 47") yields nothing.
+func DetectCommitFestID(body, subject string) string {
+	body = StripForwarded(body)
+	for _, re := range []*regexp.Regexp{commitFestURL, commitFestShorthand, commitFestEntry} {
+		for _, text := range []string{body, subject} {
+			if m := re.FindStringSubmatch(text); m != nil {
+				return m[1]
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,863 @@
+package parser
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/pgsql-analyzer/backend/models"
+)
+
+// parseString parses an mbox given as lines joined with "\n"
+func parseString(t *testing.T, mp *MboxParser, lines ...string) ([]*models.Message, *ParseStats) {
+	t.Helper()
+	messages, stats, err := mp.ParseMbox(strings.NewReader(strings.Join(lines, "\n")))
+	if err != nil {
+		t.Fatalf("ParseMbox: %v", err)
+	}
+	return messages, stats
+}
+
+func TestDegenerateSeparatorIsBoundary(t *testing.T) {
+	messages, stats := parseString(t, &MboxParser{},
+		"From alice@example.com Fri Feb  2 12:00:00 2024",
+		"Message-ID: <one@example.com>",
+		"From: Alice <alice@example.com>",
+		"Date: Fri, 2 Feb 2024 12:00:00 +0000",
+		"Subject: first",
+		"",
+		"first body",
+		"",
+		"From ",
+		"Message-ID: <two@example.com>",
+		"From: Bob <bob@example.com>",
+		"Date: Fri, 2 Feb 2024 13:00:00 +0000",
+		"Subject: second",
+		"",
+		"second body",
+		"",
+	)
+
+	if stats.Total != 2 || stats.Parsed != 2 || stats.DegenerateSeparators != 1 {
+		t.Fatalf("stats = %+v, want 2 total, 2 parsed, 1 degenerate", stats)
+	}
+	if strings.Contains(messages[0].Body, "second body") {
+		t.Errorf("first message swallowed the second: %q", messages[0].Body)
+	}
+	if messages[0].DecodeWarning != "" {
+		t.Errorf("first message flagged: %q", messages[0].DecodeWarning)
+	}
+	if !strings.Contains(messages[1].DecodeWarning, degenerateSeparatorWarning) {
+		t.Errorf("second message warning = %q, want %q", messages[1].DecodeWarning, degenerateSeparatorWarning)
+	}
+}
+
+func TestBodyLineFromStaysInBody(t *testing.T) {
+	for _, line := range []string{"From", "From "} {
+		messages, stats := parseString(t, &MboxParser{},
+			"From alice@example.com Fri Feb  2 12:00:00 2024",
+			"Message-ID: <one@example.com>",
+			"From: Alice <alice@example.com>",
+			"Date: Fri, 2 Feb 2024 12:00:00 +0000",
+			"Subject: schema",
+			"",
+			"Columns:",
+			line,
+			"To",
+			"",
+		)
+		if stats.Total != 1 || stats.Parsed != 1 || stats.DegenerateSeparators != 0 {
+			t.Fatalf("%q: stats = %+v, want a single message", line, stats)
+		}
+		if want := "Columns:\n" + line + "\nTo"; !strings.Contains(messages[0].Body, want) {
+			t.Errorf("%q: body = %q, want it to contain %q", line, messages[0].Body, want)
+		}
+	}
+}
+
+func TestDegenerateSeparatorIgnoredWithCustomSeparator(t *testing.T) {
+	mp := &MboxParser{Separator: regexp.MustCompile(`^From \S+@\S+ `)}
+	_, stats := parseString(t, mp,
+		"From alice@example.com Fri Feb  2 12:00:00 2024",
+		"Message-ID: <one@example.com>",
+		"From: Alice <alice@example.com>",
+		"Date: Fri, 2 Feb 2024 12:00:00 +0000",
+		"",
+		"body",
+		"",
+		"From ",
+		"Subject: not a header",
+		"",
+	)
+	if stats.Total != 1 || stats.DegenerateSeparators != 0 {
+		t.Errorf("stats = %+v, want one message and no degenerate separators", stats)
+	}
+}
+
+func TestArchivedAt(t *testing.T) {
+	const derived = "https://www.postgresql.org/message-id/one@example.com"
+	cases := []struct {
+		header string
+		want   string
+	}{
+		{"<https://www.postgresql.org/message-id/one%40example.com>", "https://www.postgresql.org/message-id/one%40example.com"},
+		{"http://archives.example.org/m/1", "http://archives.example.org/m/1"},
+		{"<javascript:alert(1)>", derived},
+		{"JavaScript:alert(1)", derived},
+		{"data:text/html,<script>alert(1)</script>", derived},
+		{"/message-id/one@example.com", derived},
+		{"", derived},
+	}
+	for _, c := range cases {
+		messages, _ := parseString(t, &MboxParser{},
+			"From alice@example.com Fri Feb  2 12:00:00 2024",
+			"Message-ID: <one@example.com>",
+			"From: Alice <alice@example.com>",
+			"Date: Fri, 2 Feb 2024 12:00:00 +0000",
+			"Archived-At: "+c.header,
+			"",
+			"body",
+			"",
+		)
+		if len(messages) != 1 {
+			t.Fatalf("%q: parsed %d messages", c.header, len(messages))
+		}
+		msg := messages[0]
+		if got := ArchiveURL(msg.MessageID, msg.ArchiveURL); got != c.want {
+			t.Errorf("%q: ArchiveURL = %q, want %q", c.header, got, c.want)
+		}
+	}
+
+	// Rows stored before the header was validated are checked when served
+	if got := ArchiveURL("one@example.com", "javascript:alert(1)"); got != derived {
+		t.Errorf("stored javascript: URL served as %q", got)
+	}
+}
+
+func TestCleanMessageIDLowercasesOnlyDomain(t *testing.T) {
+	cases := map[string]string{
+		"<CAB+x9Yz@Mail.Example.COM>": "CAB+x9Yz@mail.example.com",
+		"<a@b@Example.com>":           "a@b@example.com",
+		" <Abc@example.com> ":         "Abc@example.com",
+	}
+	for in, want := range cases {
+		got, err := cleanMessageID(in)
+		if err != nil || got != want {
+			t.Errorf("cleanMessageID(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+}
+
+func TestDecodeSinglePartBase64(t *testing.T) {
+	cases := []struct {
+		name, body, charset string
+		want                string
+		warned              bool
+	}{
+		{
+			name:   "valid base64",
+			body:   "SGVsbG8sIHdvcmxkIQo=",
+			want:   "Hello, world!\n",
+			warned: false,
+		},
+		{
+			// Decodes without error, but to bytes that aren't text
+			name:   "text mislabeled as base64",
+			body:   "Thanks\nforthe\nreview\nIwillfixit\n",
+			want:   "Thanks\nforthe\nreview\nIwillfixit\n",
+			warned: true,
+		},
+		{
+			// Stray '=' mid-text makes the decoder fail; lines must survive
+			name:   "text with stray equals signs",
+			body:   "Set work_mem = 64MB\nand retry.\n",
+			want:   "Set work_mem = 64MB\nand retry.\n",
+			warned: true,
+		},
+		{
+			// "こんにちは" in Shift_JIS: valid text, but not valid UTF-8
+			name:    "shift_jis",
+			body:    "grGC8YLJgr+CzQ==",
+			charset: "shift_jis",
+			want:    "\x82\xb1\x82\xf1\x82\xc9\x82\xbf\x82\xcd",
+			warned:  false,
+		},
+		{
+			// "Привет" in KOI8-R
+			name:    "koi8-r",
+			body:    "8NLJ18XU",
+			charset: "koi8-r",
+			want:    "\xf0\xd2\xc9\xd7\xc5\xd4",
+			warned:  false,
+		},
+	}
+	for _, c := range cases {
+		var warn decodeWarnings
+		got := decodeSinglePart(c.body, "base64", c.charset, 0, &warn)
+		if got != c.want {
+			t.Errorf("%s: decoded %q, want %q", c.name, got, c.want)
+		}
+		if warned := warn.String() != ""; warned != c.warned {
+			t.Errorf("%s: warnings %q, want warned=%v", c.name, warn.String(), c.warned)
+		}
+	}
+}
+
+func TestBodyCharset(t *testing.T) {
+	cases := map[string]string{
+		`text/plain; charset="Shift_JIS"`:           "shift_jis",
+		"text/plain; format=flowed; charset=KOI8-R": "koi8-r",
+		"text/plain": "",
+	}
+	for contentType, want := range cases {
+		if got := bodyCharset(contentType); got != want {
+			t.Errorf("bodyCharset(%q) = %q, want %q", contentType, got, want)
+		}
+	}
+}
+
+func TestDecodeEncodedWord(t *testing.T) {
+	cases := map[string]string{
+		// B and Q encodings, UTF-8 and Latin-1
+		"=?UTF-8?B?R3LDvMOfZQ==?=":              "Grüße",
+		"=?UTF-8?Q?Gr=C3=BC=C3=9Fe?=":           "Grüße",
+		"=?ISO-8859-1?B?R3L832U=?=":             "Grüße",
+		"=?iso-8859-1?q?Gr=FC=DFe?=":            "Grüße",
+		"Re: =?UTF-8?Q?Gr=C3=BC=C3=9Fe?= again": "Re: Grüße again",
+		// Adjacent encoded-words join without the space between them
+		"=?UTF-8?Q?Gr=C3=BC?= =?ISO-8859-1?Q?=DFe?=": "Grüße",
+		// Charsets that need a CharsetReader
+		"=?windows-1252?Q?=93quoted=94?=": "“quoted”",
+		"=?iso-8859-2?Q?=B3=F3d=BC?=":     "łódź",
+		"=?koi8-r?B?8NLJ18XU?=":           "Привет",
+		// Left alone: plain text and unknown charsets
+		"plain subject":       "plain subject",
+		"=?x-unknown?Q?abc?=": "=?x-unknown?Q?abc?=",
+	}
+	for in, want := range cases {
+		if got := decodeEncodedWord(in); got != want {
+			t.Errorf("decodeEncodedWord(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestEncodedHeadersDecodedWhenParsing(t *testing.T) {
+	messages, _ := parseString(t, &MboxParser{},
+		"From alice@example.com Fri Feb  2 12:00:00 2024",
+		"Message-ID: <one@example.com>",
+		"From: =?ISO-8859-1?Q?Andr=E9?= <andre@example.com>",
+		"Date: Fri, 2 Feb 2024 12:00:00 +0000",
+		"Subject: =?UTF-8?B?UmU6IEdyw7zDn2U=?=",
+		"",
+		"body",
+		"",
+	)
+	if len(messages) != 1 {
+		t.Fatalf("parsed %d messages, want 1", len(messages))
+	}
+	// The Re: hidden inside the encoded-word is stripped like a plain one
+	if messages[0].Subject != "Grüße" || messages[0].Author != "André" {
+		t.Errorf("subject %q, author %q; want decoded values", messages[0].Subject, messages[0].Author)
+	}
+}
+
+func TestDetectCommitFestID(t *testing.T) {
+	cases := []struct {
+		name, body, subject, want string
+	}{
+		{"stable link", "CF entry: https://commitfest.postgresql.org/patch/4567/", "", "4567"},
+		{"per-commitfest link", "See https://commitfest.postgresql.org/47/4567/ for details", "", "4567"},
+		{"link without scheme", "registered at commitfest.postgresql.org/patch/4567", "", "4567"},
+		{"shorthand", "Added as CF 47/4567.", "", "4567"},
+		{"shorthand with hash", "This is CF#47/4567", "", "4567"},
+		{"entry shorthand", "Updated CF entry 4567 to the new version", "", "4567"},
+		{"commitfest patch shorthand", "commitfest patch #4567 needs review", "", "4567"},
+		{"in subject", "body", "Re: [PATCH] foo (CF 47/4567)", "4567"},
+		{"commitfest number alone", "Moved to CF 47.", "", ""},
+		{"no reference", "Looks good to me.", "Re: [PATCH] foo", ""},
+		// Links win over shorthand, and the body over the subject
+		{"link over shorthand", "CF 46/1111 was replaced by https://commitfest.postgresql.org/patch/4567/", "", "4567"},
+		{"body over subject", "CF 47/4567", "CF 47/9999", "4567"},
+		{"subject link over body shorthand", "CF 47/1111", "commitfest.postgresql.org/patch/4567", "4567"},
+		// A forwarded message's references belong to the original
+		{"forwarded", "FYI\n\n---------- Forwarded message ---------\nhttps://commitfest.postgresql.org/patch/4567/", "", ""},
+	}
+	for _, c := range cases {
+		if got := DetectCommitFestID(c.body, c.subject); got != c.want {
+			t.Errorf("%s: DetectCommitFestID = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestCommitFestIDSetWhenParsing(t *testing.T) {
+	messages, _ := parseString(t, &MboxParser{},
+		"From alice@example.com Fri Feb  2 12:00:00 2024",
+		"Message-ID: <one@example.com>",
+		"From: Alice <alice@example.com>",
+		"Date: Fri, 2 Feb 2024 12:00:00 +0000",
+		"Subject: Re: [PATCH] faster sorting",
+		"",
+		"Registered as https://commitfest.postgresql.org/47/4567/",
+		"",
+	)
+	if len(messages) != 1 || messages[0].CommitFestID != "4567" {
+		t.Fatalf("messages %+v, want one with commitfest id 4567", messages)
+	}
+}
+
+func TestHeaderOnlyMessageFlagged(t *testing.T) {
+	messages, stats := parseString(t, &MboxParser{},
+		"From alice@example.com Fri Feb  2 12:00:00 2024",
+		"Message-ID: <one@example.com>",
+		"From: Alice <alice@example.com>",
+		"Date: Fri, 2 Feb 2024 12:00:00 +0000",
+		"Subject: [PATCH] Committed: ready for committer",
+		"",
+		"",
+		"From bob@example.com Fri Feb  2 13:00:00 2024",
+		"Message-ID: <two@example.com>",
+		"From: Bob <bob@example.com>",
+		"Date: Fri, 2 Feb 2024 13:00:00 +0000",
+		"Subject: [PATCH] v2",
+		"",
+		"diff --git a/x.c b/x.c",
+		"",
+	)
+	if stats.Parsed != 2 || len(messages) != 2 {
+		t.Fatalf("parsed %d messages (stats %+v), want both kept", len(messages), stats)
+	}
+	empty := messages[0]
+	if !empty.EmptyBody {
+		t.Error("header-only message not flagged EmptyBody")
+	}
+	if empty.HasPatch || empty.PatchStatus != "" || empty.CommitFestID != "" {
+		t.Errorf("header-only message classified: has_patch %v, status %q, commitfest %q", empty.HasPatch, empty.PatchStatus, empty.CommitFestID)
+	}
+	if messages[1].EmptyBody || !messages[1].HasPatch {
+		t.Errorf("message with a body: empty %v, has_patch %v", messages[1].EmptyBody, messages[1].HasPatch)
+	}
+}
+
+func TestSaveMboxFileStaysInDataDir(t *testing.T) {
+	dataDir := t.TempDir()
+	mp := NewMboxParser(dataDir)
+	for _, name := range []string{"../escape.mbox", "../../etc/cron.d/x", "/etc/passwd", "sub/dir/file.mbox"} {
+		path, err := mp.SaveMboxFile(name, strings.NewReader("From a@example.com Fri Feb  2 12:00:00 2024\n"))
+		if err != nil {
+			t.Errorf("SaveMboxFile(%q): %v", name, err)
+			continue
+		}
+		if filepath.Dir(path) != dataDir {
+			t.Errorf("SaveMboxFile(%q) wrote %q, outside %q", name, path, dataDir)
+		}
+	}
+	for _, name := range []string{"..", ".", "/", ""} {
+		if path, err := mp.SaveMboxFile(name, strings.NewReader("")); err == nil {
+			t.Errorf("SaveMboxFile(%q) = %q, want an error", name, path)
+		}
+	}
+}
+
+func TestMultipartFallbacksWarn(t *testing.T) {
+	const textPart = "--XYZ\nContent-Type: text/plain; charset=utf-8\n\nhello\n--XYZ--\n"
+	cases := []struct {
+		name, contentType, body string
+		wantBody, wantWarning   string
+	}{
+		{
+			name:        "clean",
+			contentType: `multipart/mixed; boundary="XYZ"`,
+			body:        textPart,
+			wantBody:    "hello",
+		},
+		{
+			name:        "empty boundary",
+			contentType: `multipart/mixed; boundary=""`,
+			body:        textPart,
+			wantBody:    strings.TrimSpace(textPart),
+			wantWarning: "multipart boundary not found",
+		},
+		{
+			name:        "attachments only",
+			contentType: `multipart/mixed; boundary="XYZ"`,
+			body:        "--XYZ\nContent-Type: application/octet-stream\nContent-Disposition: attachment; filename=\"v1.patch\"\n\nAAAA\n--XYZ--\n",
+			wantBody:    "--XYZ\nContent-Type: application/octet-stream\nContent-Disposition: attachment; filename=\"v1.patch\"\n\nAAAA\n--XYZ--",
+			wantWarning: "no text parts in multipart body",
+		},
+		{
+			name:        "undecodable base64 part",
+			contentType: `multipart/mixed; boundary="XYZ"`,
+			body:        "--XYZ\nContent-Type: text/plain\nContent-Transfer-Encoding: base64\n\nSet work_mem = 64MB\n--XYZ--\n",
+			wantBody:    "Set work_mem = 64MB",
+			wantWarning: "base64 part decode failed",
+		},
+	}
+	for _, c := range cases {
+		body, _, warning, _ := decodeMessageBody(c.body, "", c.contentType, false, 0)
+		if body != c.wantBody {
+			t.Errorf("%s: body %q, want %q", c.name, body, c.wantBody)
+		}
+		if warning != c.wantWarning {
+			t.Errorf("%s: warning %q, want %q", c.name, warning, c.wantWarning)
+		}
+	}
+}
+
+func TestDecodeWarningRecordedWhenParsing(t *testing.T) {
+	messages, _ := parseString(t, &MboxParser{},
+		"From alice@example.com Fri Feb  2 12:00:00 2024",
+		"Message-ID: <one@example.com>",
+		"From: Alice <alice@example.com>",
+		"Date: Fri, 2 Feb 2024 12:00:00 +0000",
+		`Content-Type: multipart/mixed; boundary="XYZ"`,
+		"",
+		"--XYZ",
+		"Content-Type: image/png",
+		"",
+		"iVBORw0KGgo=",
+		"--XYZ--",
+		"",
+	)
+	if len(messages) != 1 || messages[0].DecodeWarning != "no text parts in multipart body" {
+		t.Fatalf("messages %+v, want one carrying the fallback warning", messages)
+	}
+}
+
+func TestSupersedesHeaders(t *testing.T) {
+	for _, header := range []string{"Supersedes", "Replaces", "SUPERSEDES"} {
+		messages, _ := parseString(t, &MboxParser{},
+			"From alice@example.com Fri Feb  2 12:00:00 2024",
+			"Message-ID: <v2@example.com>",
+			"From: Alice <alice@example.com>",
+			"Date: Fri, 2 Feb 2024 12:00:00 +0000",
+			header+": <v1@Mail.Example.COM>",
+			"",
+			"v2 of the patch",
+			"",
+		)
+		if len(messages) != 1 || messages[0].Supersedes != "v1@mail.example.com" {
+			t.Errorf("%s: messages %+v, want Supersedes v1@mail.example.com", header, messages)
+		}
+	}
+}
+
+func TestSplitMboxPartsParseAsOne(t *testing.T) {
+	dataDir := t.TempDir()
+	mbox := strings.Join([]string{
+		"From alice@example.com Fri Feb  2 12:00:00 2024",
+		"Message-ID: <one@example.com>",
+		"From: Alice <alice@example.com>",
+		"Date: Fri, 2 Feb 2024 12:00:00 +0000",
+		"Subject: first",
+		"",
+		"first body",
+		"",
+		"From bob@example.com Fri Feb  2 13:00:00 2024",
+		"Message-ID: <two@example.com>",
+		"From: Bob <bob@example.com>",
+		"Date: Fri, 2 Feb 2024 13:00:00 +0000",
+		"Subject: straddles the split",
+		"",
+		"second body, split mid-line",
+		"",
+	}, "\n")
+	// Split inside the second message's body, in the middle of a line
+	cut := strings.Index(mbox, "split mid-line") + len("split")
+	for i, chunk := range []string{mbox[:cut], mbox[cut:]} {
+		name := filepath.Join(dataDir, fmt.Sprintf("archive.mbox.%03d", i+1))
+		if err := os.WriteFile(name, []byte(chunk), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mp := NewMboxParser(dataDir)
+	files, err := mp.ListMboxFiles()
+	if err != nil {
+		t.Fatalf("ListMboxFiles: %v", err)
+	}
+	if want := filepath.Join(dataDir, "archive.mbox"); len(files) != 1 || files[0] != want {
+		t.Fatalf("ListMboxFiles = %v, want just %s", files, want)
+	}
+
+	messages, stats, err := mp.ParseMboxFile(files[0])
+	if err != nil {
+		t.Fatalf("ParseMboxFile: %v", err)
+	}
+	if stats.Parsed != 2 || len(messages) != 2 {
+		t.Fatalf("parsed %d messages (stats %+v), want 2", len(messages), stats)
+	}
+	if messages[1].MessageID != "two@example.com" || messages[1].Body != "second body, split mid-line" {
+		t.Errorf("straddling message: id %q, body %q", messages[1].MessageID, messages[1].Body)
+	}
+}
+
+func TestSplitHeader(t *testing.T) {
+	cases := []struct {
+		line, name, value string
+		ok                bool
+	}{
+		{"Subject: hello", "subject", "hello", true},
+		{"Subject:hello", "subject", "hello", true},
+		{"SUBJECT:\thello  ", "subject", "hello", true},
+		{"Archived-At:<https://www.postgresql.org/message-id/x@y>", "archived-at", "<https://www.postgresql.org/message-id/x@y>", true},
+		{"Subject: Re: ratio 1:2", "subject", "Re: ratio 1:2", true},
+		{"X-Empty:", "x-empty", "", true},
+		{"not a header", "", "", false},
+		{"two words: value", "", "", false},
+		{": no name", "", "", false},
+	}
+	for _, c := range cases {
+		name, value, ok := splitHeader(c.line)
+		if name != c.name || value != c.value || ok != c.ok {
+			t.Errorf("splitHeader(%q) = %q, %q, %v; want %q, %q, %v", c.line, name, value, ok, c.name, c.value, c.ok)
+		}
+	}
+}
+
+func TestSpacelessHeadersParsed(t *testing.T) {
+	messages, _ := parseString(t, &MboxParser{},
+		"From alice@example.com Fri Feb  2 12:00:00 2024",
+		"Message-ID:<one@example.com>",
+		"From:Alice <alice@example.com>",
+		"Date:Fri, 2 Feb 2024 12:00:00 +0000",
+		"Subject:no space after the colon",
+		"References:<root@example.com>",
+		"",
+		"body",
+		"",
+	)
+	if len(messages) != 1 {
+		t.Fatalf("parsed %d messages, want 1", len(messages))
+	}
+	msg := messages[0]
+	if msg.MessageID != "one@example.com" || msg.Author != "Alice" || msg.Subject != "no space after the colon" || msg.RefersTo != "<root@example.com>" {
+		t.Errorf("message %+v, want every space-less header captured", msg)
+	}
+}
+
+func TestDecodeSinglePartQuotedPrintable(t *testing.T) {
+	cases := []struct {
+		name, body string
+		want       string
+		warned     bool
+	}{
+		{
+			name: "LF line endings with soft breaks",
+			body: "Set work_mem =3D 64MB and re=\ntry the query.=20\nThanks",
+			want: "Set work_mem = 64MB and retry the query. \nThanks",
+		},
+		{
+			name: "mixed CRLF and LF",
+			body: "first =3D line\r\nsecond=20=\nline\nthird",
+			want: "first = line\nsecond line\nthird",
+		},
+		{
+			name: "soft break at EOF",
+			body: "no trailing newline=",
+			want: "no trailing newline",
+		},
+		{
+			// =ZZ is not an escape, so it is kept literally
+			name: "equals sign without hex digits",
+			body: "cost =ZZ is wrong,=20see=\n below =3D fixed",
+			want: "cost =ZZ is wrong, see below = fixed",
+		},
+		{
+			// The strict decoder rejects the raw form feed; the rest must
+			// still decode rather than come back with =20 and =3D in it
+			name:   "unescaped control byte",
+			body:   "page one\x0c=20page=\n two =3D done",
+			want:   "page one\x0c page two = done",
+			warned: true,
+		},
+	}
+	for _, c := range cases {
+		var warn decodeWarnings
+		got := decodeSinglePart(c.body, "quoted-printable", "", 0, &warn)
+		if got != c.want {
+			t.Errorf("%s: decoded %q, want %q", c.name, got, c.want)
+		}
+		if warned := warn.String() != ""; warned != c.warned {
+			t.Errorf("%s: warnings %q, want warned=%v", c.name, warn.String(), c.warned)
+		}
+	}
+}
+
+func TestQuotedPrintablePartDecoded(t *testing.T) {
+	var warn decodeWarnings
+	got := decodePartBody("a =3D b=\r\n c=20\nd \x0c e", "quoted-printable", "", 0, &warn)
+	if want := "a = b c \nd \x0c e"; got != want {
+		t.Errorf("decoded %q, want %q", got, want)
+	}
+	if warn.String() == "" {
+		t.Error("lenient decode not flagged")
+	}
+}
+
+func TestMissingFinalNewline(t *testing.T) {
+	first := []string{
+		"From alice@example.com Fri Feb  2 12:00:00 2024",
+		"Message-ID: <one@example.com>",
+		"From: Alice <alice@example.com>",
+		"Date: Fri, 2 Feb 2024 12:00:00 +0000",
+		"Subject: first",
+		"",
+		"first body",
+	}
+	second := []string{
+		"From bob@example.com Fri Feb  2 13:00:00 2024",
+		"Message-ID: <two@example.com>",
+		"From: Bob <bob@example.com>",
+		"Date: Fri, 2 Feb 2024 13:00:00 +0000",
+		"Subject: second",
+		"",
+		"second body, last line",
+	}
+
+	cases := []struct {
+		name               string
+		lines              []string
+		parsed             int
+		lastBody, lastSubj string
+	}{
+		{"ends mid-line", append(append([]string{}, first...), second...), 2, "second body, last line", "second"},
+		// The last header is still pending when input ends
+		{"ends inside the headers", append(append([]string{}, first...), second[:5]...), 2, "", "second"},
+		{"ends at a separator", append(append([]string{}, first...), second[0]), 1, "first body", "first"},
+	}
+	for _, c := range cases {
+		messages, stats := parseString(t, &MboxParser{}, c.lines...)
+		if stats.Parsed != c.parsed || len(messages) != c.parsed {
+			t.Errorf("%s: stats = %+v, want %d parsed", c.name, stats, c.parsed)
+			continue
+		}
+		last := messages[len(messages)-1]
+		if last.Body != c.lastBody || last.Subject != c.lastSubj {
+			t.Errorf("%s: last message %q / %q, want %q / %q", c.name, last.Subject, last.Body, c.lastSubj, c.lastBody)
+		}
+	}
+}
+
+func TestListRewrittenFromAttributed(t *testing.T) {
+	message := func(id string, headers ...string) []string {
+		lines := []string{
+			"From list@example.com Fri Feb  2 12:00:00 2024",
+			"Message-ID: <" + id + ">",
+			"Date: Fri, 2 Feb 2024 12:00:00 +0000",
+			"Subject: attribution",
+		}
+		return append(append(lines, headers...), "", "body", "")
+	}
+	var lines []string
+	lines = append(lines, message("sender@example.com",
+		`From: "Alice Smith via pgsql-hackers" <pgsql-hackers@lists.postgresql.org>`,
+		"Sender: alice@example.com")...)
+	lines = append(lines, message("replyto@example.com",
+		`From: "Bob Jones via pgsql-hackers" <pgsql-hackers@lists.postgresql.org>`,
+		"Sender: pgsql-hackers@postgresql.org",
+		"Reply-To: Bob Jones <bob@example.com>")...)
+	lines = append(lines, message("onbehalf@example.com",
+		"From: Carol <carol@example.com>",
+		"Sender: Assistant <assistant@example.com>",
+		"Reply-To: pgsql-hackers@lists.postgresql.org")...)
+	lines = append(lines, message("unresolved@example.com",
+		"From: pgsql-hackers@lists.postgresql.org")...)
+
+	messages, stats := parseString(t, &MboxParser{}, lines...)
+	if stats.Parsed != 4 {
+		t.Fatalf("stats = %+v, want 4 parsed", stats)
+	}
+	cases := []struct {
+		name, author, email, sender, replyTo string
+	}{
+		{"sender fallback keeps the rewritten name", "Alice Smith", "alice@example.com", "alice@example.com", ""},
+		{"reply-to fallback past a list sender", "Bob Jones", "bob@example.com", "pgsql-hackers@postgresql.org", "Bob Jones <bob@example.com>"},
+		{"personal From is kept", "Carol", "carol@example.com", "Assistant <assistant@example.com>", "pgsql-hackers@lists.postgresql.org"},
+		{"nothing better than the list", "pgsql-hackers@lists.postgresql.org", "pgsql-hackers@lists.postgresql.org", "", ""},
+	}
+	for i, c := range cases {
+		msg := messages[i]
+		if msg.Author != c.author || msg.AuthorEmail != c.email {
+			t.Errorf("%s: author %q <%s>, want %q <%s>", c.name, msg.Author, msg.AuthorEmail, c.author, c.email)
+		}
+		if msg.Sender != c.sender || msg.ReplyTo != c.replyTo {
+			t.Errorf("%s: sender %q, reply-to %q; want %q, %q", c.name, msg.Sender, msg.ReplyTo, c.sender, c.replyTo)
+		}
+	}
+}
+
+// endless yields 'a' forever, like a decoder expanding a hostile input
+type endless struct{}
+
+func (endless) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 'a'
+	}
+	return len(p), nil
+}
+
+func TestReadBoundedStopsPastLimit(t *testing.T) {
+	out, err := readBounded(endless{}, 1024)
+	if err != nil {
+		t.Fatalf("readBounded: %v", err)
+	}
+	if len(out) != 1025 {
+		t.Errorf("read %d bytes, want limit+1", len(out))
+	}
+}
+
+func TestOversizedBodyTruncated(t *testing.T) {
+	const limit = 4096
+	plain := strings.Repeat("All work and no play. ", 2000)
+	marker := fmt.Sprintf(truncatedMarker, limit)
+
+	cases := []struct {
+		name, body, encoding, contentType string
+	}{
+		{"base64", base64.StdEncoding.EncodeToString([]byte(plain)), "base64", "text/plain"},
+		{"quoted-printable", strings.ReplaceAll(plain, " ", "=20"), "quoted-printable", "text/plain"},
+		{"multipart part", strings.Join([]string{
+			"--b1",
+			"Content-Type: text/plain",
+			"Content-Transfer-Encoding: base64",
+			"",
+			base64.StdEncoding.EncodeToString([]byte(plain)),
+			"--b1--",
+		}, "\n"), "", `multipart/mixed; boundary="b1"`},
+	}
+	for _, c := range cases {
+		text, _, warning, _ := decodeMessageBody(c.body, c.encoding, c.contentType, false, limit)
+		if !strings.HasSuffix(text, marker) {
+			t.Errorf("%s: body not cut with the marker (%d bytes)", c.name, len(text))
+			continue
+		}
+		if got := len(text) - len(marker); got > limit {
+			t.Errorf("%s: kept %d bytes, want at most %d", c.name, got, limit)
+		}
+		if !strings.HasPrefix(plain, strings.TrimSuffix(text, marker)) {
+			t.Errorf("%s: kept text isn't a prefix of the decoded body", c.name)
+		}
+		if !strings.Contains(warning, "truncated") {
+			t.Errorf("%s: warning %q doesn't mention truncation", c.name, warning)
+		}
+	}
+
+	// Bodies under the cap are untouched
+	short := base64.StdEncoding.EncodeToString([]byte("short"))
+	if text, _, warning, _ := decodeMessageBody(short, "base64", "text/plain", false, limit); text != "short" || warning != "" {
+		t.Errorf("short body decoded to %q with warning %q", text, warning)
+	}
+}
+
+// chunkedReader returns at most n bytes per Read, as network readers may
+type chunkedReader struct {
+	r io.Reader
+	n int
+}
+
+func (c chunkedReader) Read(p []byte) (int, error) {
+	if len(p) > c.n {
+		p = p[:c.n]
+	}
+	return c.r.Read(p)
+}
+
+// largeMbox builds an mbox of at least size bytes and returns it with its
+// message count
+func largeMbox(size int) (string, int) {
+	var b strings.Builder
+	filler := strings.Repeat("Lorem ipsum dolor sit amet, consectetur adipiscing elit.\n", 60)
+	n := 0
+	for b.Len() < size {
+		fmt.Fprintf(&b, "From author%d@example.com Fri Feb  2 12:00:00 2024\n", n)
+		fmt.Fprintf(&b, "Message-ID: <m%d@example.com>\n", n)
+		fmt.Fprintf(&b, "From: Author %d <author%d@example.com>\n", n, n)
+		fmt.Fprintf(&b, "Date: Fri, 2 Feb 2024 12:%02d:%02d +0000\n", n/60%60, n%60)
+		fmt.Fprintf(&b, "Subject: message %d\n\n%s\n", n, filler)
+		n++
+	}
+	return b.String(), n
+}
+
+func TestSaveMboxFileShortReads(t *testing.T) {
+	mbox, count := largeMbox(5 << 20)
+	mp := NewMboxParser(t.TempDir())
+
+	path, err := mp.SaveMboxFile("upload.mbox", chunkedReader{strings.NewReader(mbox), 512})
+	if err != nil {
+		t.Fatalf("SaveMboxFile: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() != int64(len(mbox)) {
+		t.Fatalf("saved file: %v, %v; want %d bytes", info, err, len(mbox))
+	}
+	messages, stats, err := mp.ParseMboxFile(path)
+	if err != nil {
+		t.Fatalf("ParseMboxFile: %v", err)
+	}
+	if len(messages) != count || stats.Parsed != count {
+		t.Errorf("parsed %d of %d messages (stats %+v)", len(messages), count, stats)
+	}
+}
+
+func TestListIDName(t *testing.T) {
+	cases := map[string]string{
+		"<pgsql-hackers.lists.postgresql.org>":                      "pgsql-hackers",
+		`"PostgreSQL General" <pgsql-general.lists.postgresql.org>`: "pgsql-general",
+		"<PGSQL-Bugs.PostgreSQL.org>":                               "pgsql-bugs",
+		"Other list <dev.example.org>":                              "dev.example.org",
+		"":                                                          "",
+		"not an id":                                                 "",
+	}
+	for value, want := range cases {
+		if got := listIDName(value); got != want {
+			t.Errorf("listIDName(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestMessagesTaggedWithList(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pgsql-general.202401")
+	mbox := strings.Join([]string{
+		"From alice@example.com Fri Feb  2 12:00:00 2024",
+		"Message-ID: <crosspost@example.com>",
+		"From: Alice <alice@example.com>",
+		"Date: Fri, 2 Feb 2024 12:00:00 +0000",
+		"Subject: cross-posted",
+		"List-Id: <pgsql-hackers.lists.postgresql.org>",
+		"",
+		"body",
+		"",
+		"From bob@example.com Fri Feb  2 13:00:00 2024",
+		"Message-ID: <plain@example.com>",
+		"From: Bob <bob@example.com>",
+		"Date: Fri, 2 Feb 2024 13:00:00 +0000",
+		"Subject: no list header",
+		"",
+		"body",
+		"",
+	}, "\n")
+	if err := os.WriteFile(path, []byte(mbox), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	messages, _, err := NewMboxParser(dir).ParseMboxFile(path)
+	if err != nil {
+		t.Fatalf("ParseMboxFile: %v", err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("parsed %d messages, want 2", len(messages))
+	}
+	// List-Id wins over the file name; the file name tags the rest
+	if got := messages[0].Lists; len(got) != 1 || got[0] != "pgsql-hackers" {
+		t.Errorf("List-Id message tagged %v, want [pgsql-hackers]", got)
+	}
+	if got := messages[1].Lists; len(got) != 1 || got[0] != "pgsql-general" {
+		t.Errorf("untagged message tagged %v, want [pgsql-general]", got)
+	}
+}
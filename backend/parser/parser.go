@@ -2,7 +2,7 @@ package parser
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"strings"
 	"time"
 
@@ -31,25 +31,25 @@ func (mp *MailParser) FetchMessages(mailingListEmail string, since time.Time) ([
 	addr := fmt.Sprintf("%s:%s", mp.host, mp.port)
 	c, err := client.DialTLS(addr, nil)
 	if err != nil {
-		log.Printf("Error connecting to IMAP server: %v", err)
+		slog.Error("Error connecting to IMAP server", "error", err)
 		return nil, err
 	}
 	defer c.Logout()
 
 	if err := c.Login(mp.username, mp.password); err != nil {
-		log.Printf("Error logging in: %v", err)
+		slog.Error("Error logging in", "error", err)
 		return nil, err
 	}
 
 	// Select INBOX
 	mbox, err := c.Select("INBOX", false)
 	if err != nil {
-		log.Printf("Error selecting inbox: %v", err)
+		slog.Error("Error selecting inbox", "error", err)
 		return nil, err
 	}
 
 	if mbox.Messages == 0 {
-		log.Println("No messages in mailbox")
+		slog.Info("No messages in mailbox")
 		return nil, nil
 	}
 
@@ -61,12 +61,12 @@ func (mp *MailParser) FetchMessages(mailingListEmail string, since time.Time) ([
 	// Search for messages
 	ids, err := c.Search(criteria)
 	if err != nil {
-		log.Printf("Error searching messages: %v", err)
+		slog.Error("Error searching messages", "error", err)
 		return nil, err
 	}
 
 	if len(ids) == 0 {
-		log.Println("No messages found matching criteria")
+		slog.Info("No messages found matching criteria")
 		return nil, nil
 	}
 
@@ -90,7 +90,7 @@ func (mp *MailParser) FetchMessages(mailingListEmail string, since time.Time) ([
 	}
 
 	if err := <-done; err != nil {
-		log.Printf("Error fetching messages: %v", err)
+		slog.Error("Error fetching messages", "error", err)
 		return nil, err
 	}
 
@@ -0,0 +1,24 @@
+package parser
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// threadIndexHeaderLen is the size of the block that starts every Outlook
+// Thread-Index: a reserved byte, the conversation's FILETIME and its GUID.
+// Each reply appends a 5-byte child block.
+const threadIndexHeaderLen = 22
+
+// ThreadConversation decodes an Outlook Thread-Index header. It returns the
+// conversation id shared by every message of the conversation, hex encoded,
+// and how many replies deep the message is (0 for the message that started
+// it). An empty or undecodable header yields "".
+func ThreadConversation(threadIndex string) (string, int) {
+	raw, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(threadIndex), ""))
+	if err != nil || len(raw) < threadIndexHeaderLen {
+		return "", 0
+	}
+	return hex.EncodeToString(raw[:threadIndexHeaderLen]), (len(raw) - threadIndexHeaderLen) / 5
+}
@@ -0,0 +1,69 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// threadIndex builds an Outlook Thread-Index for conversation seed, replies
+// deep
+func threadIndex(seed byte, replies int) string {
+	raw := append(bytes.Repeat([]byte{seed}, threadIndexHeaderLen), bytes.Repeat([]byte{0x80}, 5*replies)...)
+	return base64.StdEncoding.EncodeToString(raw)
+}
+
+func TestThreadConversation(t *testing.T) {
+	root, rootDepth := ThreadConversation(threadIndex(1, 0))
+	reply, replyDepth := ThreadConversation(threadIndex(1, 2))
+	other, _ := ThreadConversation(threadIndex(2, 1))
+
+	if root == "" || root != reply {
+		t.Errorf("conversation ids %q and %q, want the same non-empty id", root, reply)
+	}
+	if rootDepth != 0 || replyDepth != 2 {
+		t.Errorf("depths %d and %d, want 0 and 2", rootDepth, replyDepth)
+	}
+	if other == root {
+		t.Error("different conversations share an id")
+	}
+
+	// Long headers are folded across lines
+	folded := threadIndex(1, 2)
+	folded = folded[:20] + "\n\t" + folded[20:]
+	if conv, depth := ThreadConversation(folded); conv != root || depth != 2 {
+		t.Errorf("folded header: %q depth %d, want %q depth 2", conv, depth, root)
+	}
+
+	for _, bad := range []string{"", "not base64!", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		if conv, _ := ThreadConversation(bad); conv != "" {
+			t.Errorf("ThreadConversation(%q) = %q, want none", bad, conv)
+		}
+	}
+}
+
+func TestOutlookHeadersParsed(t *testing.T) {
+	messages, _ := parseString(t, &MboxParser{},
+		"From alice@example.com Fri Feb  2 12:00:00 2024",
+		"Message-ID: <outlook@example.com>",
+		"From: Alice <alice@example.com>",
+		"Date: Fri, 2 Feb 2024 12:00:00 +0000",
+		"Thread-Topic: Planner regression in 16.2",
+		"Thread-Index: "+threadIndex(1, 1),
+		"",
+		"body",
+		"",
+	)
+	if len(messages) != 1 {
+		t.Fatalf("parsed %d messages, want 1", len(messages))
+	}
+	msg := messages[0]
+	if msg.ThreadIndex != threadIndex(1, 1) {
+		t.Errorf("ThreadIndex = %q", msg.ThreadIndex)
+	}
+	// Thread-Topic stands in for the missing Subject
+	if msg.ThreadTopic != "Planner regression in 16.2" || !strings.Contains(msg.Subject, "Planner regression") {
+		t.Errorf("topic %q, subject %q", msg.ThreadTopic, msg.Subject)
+	}
+}
@@ -19,6 +19,8 @@ export interface Thread {
   message_count: number;
   unique_authors: number;
   status: 'in-progress' | 'discussion' | 'stalled' | 'abandoned';
+  forked_from?: string;
+  forked_from_subject?: string;
 }
 
 export interface Message {
@@ -33,6 +35,10 @@ export interface Message {
   has_patch: boolean;
   patch_status?: 'proposed' | 'accepted' | 'committed' | 'rejected' | '';
   commitfest_id?: string;
+  archive_url?: string;
+  sender?: string;
+  reply_to?: string;
+  lists?: string[];
 }
 
 export interface Stats {
@@ -170,7 +170,7 @@ export const MessageThread: React.FC<MessageThreadProps> = ({
                       </button>
                     )}
                     <a
-                      href={getArchiveLink(msg.message_id)}
+                      href={msg.archive_url || getArchiveLink(msg.message_id)}
                       target="_blank"
                       rel="noopener noreferrer"
                       className={styles.archiveLink}
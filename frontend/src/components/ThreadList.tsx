@@ -90,6 +90,9 @@ export const ThreadList: React.FC<ThreadListProps> = ({
                   {thread.status}
                 </span>
               </div>
+              {thread.forked_from_subject && (
+                <div className={styles.forkedFrom}>Forked from: {thread.forked_from_subject}</div>
+              )}
               <div className={styles.meta}>
                 <span>{thread.message_count} messages</span>
                 <span>{thread.unique_authors} authors</span>